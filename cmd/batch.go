@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
+)
+
+// Batch options
+var (
+	batchConcurrency      int
+	batchOutputDir        string
+	batchReportFile       string
+	discoverSubscriptions []string
+	// batchAccounts are only available through the config file
+	batchAccounts []migrate.BatchAccount
+)
+
+// batchDiscovery is an Azure subscription whose Media Services accounts are all migrated, into
+// MediakindSubscription or, if it is empty, into the mk.io subscriptions named like the accounts
+type batchDiscovery struct {
+	Subscription          string `yaml:"subscription"`
+	MediakindSubscription string `yaml:"mediakindSubscription"`
+}
+
+// batchCmd migrates several AMS accounts in a single run
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Migrate several AMS accounts, each into its own mk.io subscription",
+	Long: `Migrate several AMS accounts into mk.io in a single run.
+
+The accounts and the mk.io subscription of each are listed in the batch section of the config file, or discovered
+in Azure subscriptions with --discover-subscription. Each account is exported to its own migration file in
+--output-dir and imported like migrate does, and a report of all accounts is written to --report.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		accounts := append([]migrate.BatchAccount{}, batchAccounts...)
+		discoveries, err := batchDiscoveries()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, d := range discoveries {
+			discovered, err := migrate.DiscoverAccounts(ctx, d.Subscription, d.MediakindSubscription, azureCredentials())
+			if err != nil {
+				log.Fatalf("unable to discover the Media Services accounts of subscription %v: %v", d.Subscription, err)
+			}
+			log.Infof("Discovered %d Media Services accounts in subscription %v", len(discovered), d.Subscription)
+			accounts = append(accounts, discovered...)
+		}
+		if errs := checkBatchAccounts(accounts); len(errs) > 0 {
+			log.Fatalf("invalid batch accounts:\n\t%v", strings.Join(errs, "\n\t"))
+		}
+		if len(accounts) == 0 {
+			log.Fatal("no Media Services accounts to migrate")
+		}
+
+		if batchOutputDir == "" {
+			batchOutputDir = fmt.Sprintf("batch-%v", time.Now().Unix())
+		}
+		if err := os.MkdirAll(batchOutputDir, 0700); err != nil {
+			log.Fatalf("unable to create output directory: %v", err)
+		}
+		if batchReportFile == "" {
+			batchReportFile = filepath.Join(batchOutputDir, "report.json")
+		}
+
+		// All accounts use the same token, so it is only refreshed once when it expires
+		auth, err := mkioAuth()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		limiters := batchLimiters(accounts)
+
+		log.Infof("Migrating %d accounts, %d at a time", len(accounts), batchConcurrency)
+		results := migrate.RunBatch(ctx, accounts, batchConcurrency, func(ctx context.Context, account migrate.BatchAccount) migrate.BatchResult {
+			return migrateAccount(ctx, account, auth, limiters[account.MediakindSubscription])
+		})
+
+		report := migrate.NewBatchReport(results)
+		printBatchReport(report)
+		if err := report.WriteFile(batchReportFile); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("Batch report written to %v", batchReportFile)
+		if report.Failed() {
+			log.Warnf("Some accounts or resources failed to migrate, see %v and the failure manifests in %v", batchReportFile, batchOutputDir)
+		}
+	},
+}
+
+// migrateAccount exports an account of a batch to its migration file and imports it into its mk.io subscription.
+// The requests are limited by the limiter of the subscription. Errors are returned in the result, so they don't stop
+// the other accounts
+func migrateAccount(ctx context.Context, account migrate.BatchAccount, auth mkiosdk.TokenProvider, limiter *mkiosdk.RateLimiter) migrate.BatchResult {
+	fileName := filepath.Join(batchOutputDir, account.AccountName+".json")
+	if migrationFormat == migrate.FormatNDJSON {
+		fileName = filepath.Join(batchOutputDir, account.AccountName+".jsonl")
+	}
+	result := migrate.BatchResult{MigrationFile: fileName}
+	p := newPipeline()
+
+	// Log into mk.io first so we know if it fails before we do any work
+	options, err := clientOptions()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	options.TokenProvider = auth
+	options.RateLimiter = limiter
+	destination, err := migrate.NewMkioServiceProvider(ctx, account.MediakindSubscription, "", apiEndpoint, options)
+	if err != nil {
+		result.Err = fmt.Errorf("import Error: %v", err)
+		return result
+	}
+	p.Destination = destination
+
+	source, err := migrate.NewAzureServiceProvider(account.Subscription, account.ResourceGroup, account.AccountName, azureCredentials())
+	if err != nil {
+		result.Err = fmt.Errorf("unable to log into Azure: %v", err)
+		return result
+	}
+	p.Source = source
+
+	contents, timings, err := p.Export(ctx)
+	result.Results = timings
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	// Half an export would look like a complete one to import
+	if ctx.Err() != nil {
+		result.Err = fmt.Errorf("export interrupted, the migration file was not written: %v", ctx.Err())
+		return result
+	}
+	// What was exported is still imported, like migrate does, but the account is reported as failed
+	for _, t := range timings {
+		if t.Err != nil && result.Err == nil {
+			result.Err = fmt.Errorf("export of %v failed: %v", t.Resource, t.Err)
+		}
+	}
+
+	header := exportHeader(p)
+	header.Source = account.Source()
+	if err := contents.SetHeader(header); err != nil {
+		result.Err = fmt.Errorf("unable to write migration export file header: %v", err)
+		return result
+	}
+	fileContents := contents
+	if sealer := newSecretSealer(); sealer != nil {
+		fileContents, err = contents.SealSecrets(sealer)
+		if err != nil {
+			result.Err = fmt.Errorf("unable to protect migration file secrets: %v", err)
+			return result
+		}
+	}
+	if err := fileContents.WriteMigrationFile(ctx, fileName, migrationFormat); err != nil {
+		result.Err = fmt.Errorf("unable to write migration export file contents: %v", err)
+		return result
+	}
+	log.Infof("Exported %v to %v", account, fileName)
+
+	// Track the status of each resource so an interrupted batch can be resumed with the same --output-dir
+	checkpoint, err := migrate.OpenCheckpointStore(fileName+".checkpoint", resume, account.MediakindSubscription)
+	if err != nil {
+		result.Err = fmt.Errorf("could not open checkpoint file: %v", err)
+		return result
+	}
+	p.Checkpoint = checkpoint
+	defer p.Checkpoint.Close()
+	if p.Overwrite {
+		snapshots, err := migrate.OpenSnapshotStore(fileName+".snapshots", newSnapshotSealer())
+		if err != nil {
+			result.Err = fmt.Errorf("could not open snapshot file: %v", err)
+			return result
+		}
+		p.Snapshots = snapshots
+		defer p.Snapshots.Close()
+	}
+
+	timings, err = p.Import(ctx, contents)
+	result.Results = append(result.Results, timings...)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	failures := migrate.Failures(timings)
+	if err := migrate.WriteFailureManifest(fileName+".failures.json", fileName, failures); err != nil {
+		log.Errorf("unable to write failure manifest of %v: %v", account, err)
+	}
+	return result
+}
+
+// batchDiscoveries are the subscriptions to discover accounts in, from --discover-subscription or the config file.
+// The flag takes <subscription>[:<mk.io subscription>]
+func batchDiscoveries() ([]batchDiscovery, error) {
+	discoveries := []batchDiscovery{}
+	for _, value := range discoverSubscriptions {
+		subscription, mediakindSubscription, _ := strings.Cut(value, ":")
+		if subscription == "" {
+			return nil, fmt.Errorf("discover-subscription %q is not <subscription>[:<mk.io subscription>]", value)
+		}
+		discoveries = append(discoveries, batchDiscovery{Subscription: subscription, MediakindSubscription: mediakindSubscription})
+	}
+	return discoveries, nil
+}
+
+// batchLimiters returns the rate limiter of each mk.io subscription of accounts. mk.io limits the requests of a
+// subscription, so the accounts migrated into the same one share a limiter
+func batchLimiters(accounts []migrate.BatchAccount) map[string]*mkiosdk.RateLimiter {
+	// The limits were checked by validateOptions
+	limits, _ := rateLimits()
+	limiters := map[string]*mkiosdk.RateLimiter{}
+	for _, account := range accounts {
+		if _, ok := limiters[account.MediakindSubscription]; !ok {
+			limiters[account.MediakindSubscription] = mkiosdk.NewRateLimiter(limits)
+		}
+	}
+	return limiters
+}
+
+// checkBatchAccounts checks every account can be migrated and returns what is wrong with them. Account names are
+// globally unique in Azure, so the same name twice is the same account, and they would share a migration file
+func checkBatchAccounts(accounts []migrate.BatchAccount) []string {
+	errs := []string{}
+	seen := map[string]bool{}
+	for _, account := range accounts {
+		if err := account.Validate(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		name := strings.ToLower(account.AccountName)
+		if seen[name] {
+			errs = append(errs, fmt.Sprintf("account %v is listed more than once", account.AccountName))
+		}
+		seen[name] = true
+	}
+	return errs
+}
+
+// printBatchReport writes out the outcome of each account and the totals of the batch
+func printBatchReport(report migrate.BatchReport) {
+	fmt.Println("Accounts:")
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Account\tmk.io Subscription\tExported\tImported\tSkipped\tFailed\tDuration\tError\n")
+	for _, a := range report.Accounts {
+		exported, imported, skipped, failed := 0, 0, 0, 0
+		for _, t := range a.Totals {
+			if t.Operation == migrate.EXPORT {
+				exported += t.Migrated
+			} else {
+				imported += t.Migrated
+				skipped += t.Skipped
+				failed += t.Failed
+			}
+		}
+		// The whole error is in the report
+		firstLine, _, _ := strings.Cut(a.Error, "\n")
+		_, _ = fmt.Fprintf(w, "%v\t%v\t%d\t%d\t%d\t%d\t%v\t%v\n", a.BatchAccount, a.MediakindSubscription, exported, imported, skipped, failed, a.Duration, firstLine)
+	}
+	w.Flush()
+
+	fmt.Println("\nTotals:")
+	w = tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Operation\tResource\tMigrated\tSkipped\tFailed\tRetries\n")
+	for _, t := range report.Totals {
+		if t.Operation == migrate.EXPORT {
+			_, _ = fmt.Fprintf(w, "%v\t%v\t%d\t-\t-\t%d\n", t.Operation, t.Resource, t.Migrated, t.Retries)
+		} else {
+			_, _ = fmt.Fprintf(w, "%v\t%v\t%d\t%d\t%d\t%d\n", t.Operation, t.Resource, t.Migrated, t.Skipped, t.Failed, t.Retries)
+		}
+	}
+	w.Flush()
+}
+
+func init() {
+	addAzureCredentialFlags(batchCmd)
+	addCreatedFlags(batchCmd)
+	addResourceFlags(batchCmd)
+	addWorkerFlags(batchCmd)
+	addImportOptionFlags(batchCmd)
+	addFormatFlag(batchCmd)
+	addSecretFlags(batchCmd)
+	batchCmd.Flags().StringSliceVar(&discoverSubscriptions, "discover-subscription", nil, "migrate every Media Services account of this Azure subscription, as <subscription>[:<mk.io subscription>]. Without an mk.io subscription each account goes to the mk.io subscription named like it. Can be repeated")
+	batchCmd.Flags().IntVar(&batchConcurrency, "accounts-concurrency", 2, "number of accounts to migrate in parallel")
+	batchCmd.Flags().StringVar(&batchOutputDir, "output-dir", "", "directory of the migration, checkpoint and failure files of the accounts (default: batch-<timestamp>)")
+	batchCmd.Flags().StringVar(&batchReportFile, "report", "", "file the JSON report of the batch is written to (default: <output-dir>/report.json)")
+
+	rootCmd.AddCommand(batchCmd)
+}
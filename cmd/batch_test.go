@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"testing"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+func TestBatchLimiters(t *testing.T) {
+	accounts := []migrate.BatchAccount{
+		{AccountName: "ams1", MediakindSubscription: "sub1"},
+		{AccountName: "ams2", MediakindSubscription: "sub2"},
+		{AccountName: "ams3", MediakindSubscription: "sub1"},
+	}
+	limiters := batchLimiters(accounts)
+	if len(limiters) != 2 {
+		t.Errorf("%d limiters, want one per mk.io subscription", len(limiters))
+	}
+	if limiters["sub1"] == nil || limiters["sub1"] == limiters["sub2"] {
+		t.Errorf("limiters %v, want a different one for each subscription", limiters)
+	}
+}
+
+func TestBatchDiscoveries(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    []batchDiscovery
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "named like the accounts", values: []string{"s1"}, want: []batchDiscovery{{Subscription: "s1"}}},
+		{name: "into a subscription", values: []string{"s1:sub1", "s2"}, want: []batchDiscovery{{Subscription: "s1", MediakindSubscription: "sub1"}, {Subscription: "s2"}}},
+		{name: "no subscription", values: []string{"s1", ":sub1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			discoverSubscriptions = tt.values
+			defer func() { discoverSubscriptions = nil }()
+
+			got, err := batchDiscoveries()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("batchDiscoveries() = %v, want error %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("batchDiscoveries() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("discovery %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+var configFile string
+
+// migrationConfig is the contents of the --config file. JSON files are read as YAML
+type migrationConfig struct {
+	Source struct {
+		Azure struct {
+			Subscription  string `yaml:"subscription"`
+			ResourceGroup string `yaml:"resourceGroup"`
+			AccountName   string `yaml:"accountName"`
+			// Credential selects how to log into Azure. Secrets are only read from the environment
+			Credential struct {
+				Type              string `yaml:"type"`
+				TenantID          string `yaml:"tenantId"`
+				ClientID          string `yaml:"clientId"`
+				ClientCertificate string `yaml:"clientCertificate"`
+				Cloud             string `yaml:"cloud"`
+			} `yaml:"credential"`
+		} `yaml:"azure"`
+		MediakindSubscription string `yaml:"mediakindSubscription"`
+		CreatedBefore         string `yaml:"createdBefore"`
+		CreatedAfter          string `yaml:"createdAfter"`
+	} `yaml:"source"`
+
+	Destination struct {
+		MediakindSubscription string `yaml:"mediakindSubscription"`
+		ApiEndpoint           string `yaml:"apiEndpoint"`
+	} `yaml:"destination"`
+
+	// Resources lists the resource types to migrate, e.g. assets, assetFilters, streamingLocators
+	Resources []string `yaml:"resources"`
+
+	Workers                  int   `yaml:"workers"`
+	Overwrite                *bool `yaml:"overwrite"`
+	FairplayAmsCompatibility *bool `yaml:"fairplayAmsCompatibility"`
+	// UnsupportedCdn is what import does with streaming endpoints whose CDN provider mk.io doesn't support
+	UnsupportedCdn string `yaml:"unsupportedCdn"`
+	// Stream imports resources as they are exported. Only used by migrate
+	Stream *bool `yaml:"stream"`
+	// Incremental only migrates what changed since the last incremental run. Only used by migrate
+	Incremental *bool `yaml:"incremental"`
+
+	Transformations migrate.Transformations `yaml:"transformations"`
+
+	// Retry is the retry policy of the mk.io requests. Delays are durations like 500ms or 1m
+	Retry struct {
+		MaxAttempts int           `yaml:"maxAttempts"`
+		BaseDelay   time.Duration `yaml:"baseDelay"`
+		MaxDelay    time.Duration `yaml:"maxDelay"`
+	} `yaml:"retry"`
+
+	// RateLimit limits the mk.io requests. Methods are the limits of HTTP methods as <rate>[:<burst>], e.g. PUT: "5:10"
+	RateLimit struct {
+		RequestsPerSecond *float64          `yaml:"requestsPerSecond"`
+		Burst             int               `yaml:"burst"`
+		Methods           map[string]string `yaml:"methods"`
+		Adaptive          *bool             `yaml:"adaptive"`
+	} `yaml:"rateLimit"`
+
+	// Auth is where the mk.io token comes from, instead of MKIO_TOKEN. Use one of TokenFile, KeyringService or
+	// TokenCommand. TokenWait is how long requests wait for a new token in the file or keyring, e.g. 5m
+	Auth struct {
+		TokenFile      string        `yaml:"tokenFile"`
+		KeyringService string        `yaml:"keyringService"`
+		KeyringAccount string        `yaml:"keyringAccount"`
+		TokenCommand   string        `yaml:"tokenCommand"`
+		TokenWait      time.Duration `yaml:"tokenWait"`
+	} `yaml:"auth"`
+
+	Output struct {
+		MigrationFile string `yaml:"migrationFile"`
+		// Format is json or ndjson
+		Format          string `yaml:"format"`
+		CheckpointFile  string `yaml:"checkpointFile"`
+		FailureManifest string `yaml:"failureManifest"`
+		WatermarkFile   string `yaml:"watermarkFile"`
+		// EncryptSecrets encrypts the secrets with MIGRATION_PASSPHRASE, or for the Recipients
+		EncryptSecrets *bool    `yaml:"encryptSecrets"`
+		Recipients     []string `yaml:"recipients"`
+		RedactSecrets  *bool    `yaml:"redactSecrets"`
+	} `yaml:"output"`
+
+	// Batch lists the AMS accounts migrated by the batch command and their mk.io subscriptions, and the Azure
+	// subscriptions whose accounts are all migrated. Only used by batch
+	Batch struct {
+		Accounts    []migrate.BatchAccount `yaml:"accounts"`
+		Discover    []batchDiscovery       `yaml:"discover"`
+		Concurrency int                    `yaml:"concurrency"`
+		OutputDir   string                 `yaml:"outputDir"`
+		Report      string                 `yaml:"report"`
+	} `yaml:"batch"`
+}
+
+// transformations loaded from the config file. Only available through the config file
+var transformations *migrate.Transformations
+
+// readConfig reads a YAML or JSON config file. Unknown keys are an error so typos don't go unnoticed
+func readConfig(fileName string) (migrationConfig, error) {
+	cfg := migrationConfig{}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return cfg, fmt.Errorf("unable to read config file %v: %v", fileName, err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("unable to parse config file %v: %v", fileName, err)
+	}
+	return cfg, nil
+}
+
+// configString sets a string option from the config file, unless the flag was given on the command line
+func configString(cmd *cobra.Command, name string, target *string, value string) {
+	f := cmd.Flag(name)
+	if f == nil || f.Changed || value == "" {
+		return
+	}
+	*target = value
+}
+
+// configBool sets a bool option from the config file, unless the flag was given on the command line. nil leaves the
+// default, so false in the config file can switch off an option that is on by default
+func configBool(cmd *cobra.Command, name string, target *bool, value *bool) {
+	f := cmd.Flag(name)
+	if f == nil || f.Changed || value == nil {
+		return
+	}
+	*target = *value
+}
+
+// applyConfig loads the --config file into the options of cmd. Flags given on the command line take precedence
+func applyConfig(cmd *cobra.Command) error {
+	if configFile == "" {
+		return nil
+	}
+	cfg, err := readConfig(configFile)
+	if err != nil {
+		return err
+	}
+
+	configString(cmd, "azure-subscription", &azSubscription, cfg.Source.Azure.Subscription)
+	configString(cmd, "azure-resource-group", &azResourceGroup, cfg.Source.Azure.ResourceGroup)
+	configString(cmd, "azure-account-name", &azAccountName, cfg.Source.Azure.AccountName)
+	configString(cmd, "azure-credential", &azCredential, cfg.Source.Azure.Credential.Type)
+	configString(cmd, "azure-tenant-id", &azTenantID, cfg.Source.Azure.Credential.TenantID)
+	configString(cmd, "azure-client-id", &azClientID, cfg.Source.Azure.Credential.ClientID)
+	configString(cmd, "azure-client-certificate", &azClientCertificate, cfg.Source.Azure.Credential.ClientCertificate)
+	configString(cmd, "azure-cloud", &azCloud, cfg.Source.Azure.Credential.Cloud)
+	configString(cmd, "mediakind-export-subscription", &mkExportSubscription, cfg.Source.MediakindSubscription)
+	configString(cmd, "created-before", &createdBefore, cfg.Source.CreatedBefore)
+	configString(cmd, "created-after", &createdAfter, cfg.Source.CreatedAfter)
+
+	configString(cmd, "mediakind-import-subscription", &mkImportSubscription, cfg.Destination.MediakindSubscription)
+	configString(cmd, "api-endpoint", &apiEndpoint, cfg.Destination.ApiEndpoint)
+
+	configBool(cmd, "overwrite", &overwrite, cfg.Overwrite)
+	configBool(cmd, "fairplay-ams-compatibility", &fairplayAmsCompatibility, cfg.FairplayAmsCompatibility)
+	configString(cmd, "unsupported-cdn", &unsupportedCdn, cfg.UnsupportedCdn)
+	configBool(cmd, "stream", &stream, cfg.Stream)
+	configBool(cmd, "incremental", &incremental, cfg.Incremental)
+	if f := cmd.Flag("workers"); f != nil && !f.Changed && cfg.Workers != 0 {
+		workers = cfg.Workers
+	}
+
+	if f := cmd.Flag("max-attempts"); f != nil && !f.Changed && cfg.Retry.MaxAttempts != 0 {
+		maxAttempts = cfg.Retry.MaxAttempts
+	}
+	if f := cmd.Flag("retry-base-delay"); f != nil && !f.Changed && cfg.Retry.BaseDelay != 0 {
+		retryBaseDelay = cfg.Retry.BaseDelay
+	}
+	if f := cmd.Flag("retry-max-delay"); f != nil && !f.Changed && cfg.Retry.MaxDelay != 0 {
+		retryMaxDelay = cfg.Retry.MaxDelay
+	}
+
+	if f := cmd.Flag("rate-limit"); f != nil && !f.Changed && cfg.RateLimit.RequestsPerSecond != nil {
+		rateLimit = *cfg.RateLimit.RequestsPerSecond
+	}
+	if f := cmd.Flag("rate-burst"); f != nil && !f.Changed && cfg.RateLimit.Burst != 0 {
+		rateBurst = cfg.RateLimit.Burst
+	}
+	if f := cmd.Flag("method-rate-limit"); f != nil && !f.Changed && len(cfg.RateLimit.Methods) > 0 {
+		methodRateLimits = cfg.RateLimit.Methods
+	}
+	configBool(cmd, "adaptive-rate-limit", &adaptiveRateLimit, cfg.RateLimit.Adaptive)
+
+	configString(cmd, "mkio-token-file", &mkioTokenFile, cfg.Auth.TokenFile)
+	configString(cmd, "mkio-keyring-service", &mkioKeyringService, cfg.Auth.KeyringService)
+	configString(cmd, "mkio-keyring-account", &mkioKeyringAccount, cfg.Auth.KeyringAccount)
+	configString(cmd, "mkio-token-command", &mkioTokenCommand, cfg.Auth.TokenCommand)
+	if f := cmd.Flag("mkio-token-wait"); f != nil && !f.Changed && cfg.Auth.TokenWait != 0 {
+		mkioTokenWait = cfg.Auth.TokenWait
+	}
+
+	configString(cmd, "migration-file", &migrationFile, cfg.Output.MigrationFile)
+	configString(cmd, "format", &migrationFormat, cfg.Output.Format)
+	configString(cmd, "checkpoint-file", &checkpointFile, cfg.Output.CheckpointFile)
+	configString(cmd, "failure-manifest", &failureManifestFile, cfg.Output.FailureManifest)
+	configString(cmd, "watermark-file", &watermarkFile, cfg.Output.WatermarkFile)
+	configBool(cmd, "encrypt-secrets", &encryptSecrets, cfg.Output.EncryptSecrets)
+	configBool(cmd, "redact-secrets", &redactSecrets, cfg.Output.RedactSecrets)
+	if f := cmd.Flag("recipient"); f != nil && !f.Changed && len(cfg.Output.Recipients) > 0 {
+		secretRecipients = cfg.Output.Recipients
+	}
+
+	if cmd.Flag("accounts-concurrency") != nil {
+		batchAccounts = cfg.Batch.Accounts
+		// Subscriptions on the command line replace the ones of the config file
+		if !cmd.Flag("discover-subscription").Changed {
+			for _, d := range cfg.Batch.Discover {
+				discoverSubscriptions = append(discoverSubscriptions, d.Subscription+":"+d.MediakindSubscription)
+			}
+		}
+		if !cmd.Flag("accounts-concurrency").Changed && cfg.Batch.Concurrency != 0 {
+			batchConcurrency = cfg.Batch.Concurrency
+		}
+		configString(cmd, "output-dir", &batchOutputDir, cfg.Batch.OutputDir)
+		configString(cmd, "report", &batchReportFile, cfg.Batch.Report)
+	}
+
+	// Any resource flag on the command line replaces the resource list of the config file
+	if cmd.Flag("assets") != nil && !resourceFlagsChanged(cmd) && len(cfg.Resources) > 0 {
+		for _, r := range cfg.Resources {
+			switch r {
+			case migrate.ASSETS:
+				assets = true
+			case migrate.ASSETFILTERS:
+				assetFilters = true
+			case migrate.CONTENTKEYPOLICIES:
+				contentKeyPolicies = true
+			case migrate.STREAMINGLOCATORS:
+				streamingLocators = true
+			case migrate.STREAMINGENDPOINTS:
+				streamingEndpoints = true
+			case migrate.STREAMINGPOLICIES:
+				streamingPolicies = true
+			default:
+				return fmt.Errorf("config file %v: unknown resource %q. Use one of %v", configFile, r, strings.Join(resourceNames, ", "))
+			}
+		}
+	}
+
+	if len(cfg.Transformations.StorageAccounts) > 0 || len(cfg.Transformations.Locations) > 0 {
+		transformations = &cfg.Transformations
+	}
+
+	return nil
+}
+
+// resourceNames are the names accepted in the resources list of the config file
+var resourceNames = []string{migrate.ASSETS, migrate.ASSETFILTERS, migrate.CONTENTKEYPOLICIES, migrate.STREAMINGLOCATORS, migrate.STREAMINGENDPOINTS, migrate.STREAMINGPOLICIES}
+
+// resourceFlagsChanged returns true if any resource type was selected on the command line
+func resourceFlagsChanged(cmd *cobra.Command) bool {
+	for _, name := range []string{"assets", "asset-filters", "content-key-policies", "streaming-locators", "streaming-endpoints", "streaming-policies"} {
+		if f := cmd.Flag(name); f != nil && f.Changed {
+			return true
+		}
+	}
+	return false
+}
+
+// validateOptions checks the options of cmd, so mistakes are reported before logging into Azure or mk.io
+func validateOptions(cmd *cobra.Command) error {
+	errs := []string{}
+
+	// Source options only exist on commands that export
+	if cmd.Flag("azure-subscription") != nil {
+		azure := azSubscription != "" || azResourceGroup != "" || azAccountName != ""
+		if azure && mkExportSubscription != "" {
+			errs = append(errs, "cannot export from both Azure and mk.io subscription")
+		} else if azure && (azSubscription == "" || azResourceGroup == "" || azAccountName == "") {
+			errs = append(errs, "Azure export needs subscription, resource group and account name")
+		} else if !azure && mkExportSubscription == "" {
+			errs = append(errs, "cannot export without Azure or mk.io subscription information")
+		}
+		if azure {
+			if err := azureCredentials().Validate(); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if cmd.Flag("created-before") != nil {
+		for name, value := range map[string]string{"created-before": createdBefore, "created-after": createdAfter} {
+			if value != "" && !validDate(value) {
+				errs = append(errs, fmt.Sprintf("%v %q is not a date (2006-01-02) or RFC3339 time", name, value))
+			}
+		}
+	}
+
+	// Batch options only exist on the batch command. Discovered accounts are checked once they are known
+	batch := cmd.Flag("accounts-concurrency") != nil
+	if batch {
+		discoveries, err := batchDiscoveries()
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		if len(batchAccounts) == 0 && len(discoveries) == 0 {
+			errs = append(errs, "no accounts to migrate. List them in the batch section of the config file or use --discover-subscription")
+		}
+		errs = append(errs, checkBatchAccounts(batchAccounts)...)
+		if err := azureCredentials().Validate(); err != nil {
+			errs = append(errs, err.Error())
+		}
+		if batchConcurrency < 1 {
+			errs = append(errs, fmt.Sprintf("accounts-concurrency must be at least 1, got %d", batchConcurrency))
+		}
+	}
+
+	// Destination options only exist on commands that write to or read from mk.io
+	if cmd.Flag("mediakind-import-subscription") != nil && mkImportSubscription == "" {
+		errs = append(errs, "missing --mediakind-import-subscription")
+	}
+	if cmd.Flag("mediakind-import-subscription") != nil || mkExportSubscription != "" || diffSubscription != "" || batch {
+		if _, err := mkioAuth(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if cmd.Flag("format") != nil && migrationFormat != migrate.FormatJSON && migrationFormat != migrate.FormatNDJSON {
+		errs = append(errs, fmt.Sprintf("format must be %v or %v, got %q", migrate.FormatJSON, migrate.FormatNDJSON, migrationFormat))
+	}
+	if cmd.Flag("encrypt-secrets") != nil {
+		if encryptSecrets && redactSecrets {
+			errs = append(errs, "secrets can be encrypted or redacted, not both")
+		} else if encryptSecrets && len(secretRecipients) == 0 && os.Getenv("MIGRATION_PASSPHRASE") == "" {
+			errs = append(errs, "encrypting secrets needs the MIGRATION_PASSPHRASE environment variable or a --recipient")
+		} else if len(secretRecipients) > 0 && !encryptSecrets {
+			errs = append(errs, "--recipient is only used with --encrypt-secrets")
+		}
+	}
+	if cmd.Flag("unsupported-cdn") != nil {
+		known := unsupportedCdn == ""
+		for _, v := range migrate.CdnPolicies {
+			known = known || v == unsupportedCdn
+		}
+		if !known {
+			errs = append(errs, fmt.Sprintf("unsupported-cdn must be one of %v, got %q", strings.Join(migrate.CdnPolicies, ", "), unsupportedCdn))
+		}
+	}
+	if cmd.Flag("on-conflict") != nil {
+		known := false
+		for _, v := range migrate.MergePolicies {
+			known = known || v == conflictPolicy
+		}
+		if !known {
+			errs = append(errs, fmt.Sprintf("on-conflict must be one of %v, got %q", strings.Join(migrate.MergePolicies, ", "), conflictPolicy))
+		}
+	}
+	if cmd.Flag("resources") != nil {
+		for _, v := range diffResources {
+			known := false
+			for _, name := range resourceNames {
+				known = known || v == name
+			}
+			if !known {
+				errs = append(errs, fmt.Sprintf("unknown resource %q. Use one of %v", v, strings.Join(resourceNames, ", ")))
+			}
+		}
+	}
+	if cmd.Flag("workers") != nil && workers < 1 {
+		errs = append(errs, fmt.Sprintf("workers must be at least 1, got %d", workers))
+	}
+	if err := retryPolicy().Validate(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if _, err := rateLimits(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if cmd.Flag("assets") != nil {
+		kinds := selectedKinds()
+		if kinds == (migrate.ResourceKinds{}) && retryFailuresFile == "" {
+			errs = append(errs, "no resources selected. Use the resource flags or the resources list of the config file")
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid options:\n\t%v", strings.Join(errs, "\n\t"))
+	}
+	return nil
+}
+
+// validDate checks created-before/created-after values
+func validDate(value string) bool {
+	_, err := parseDate(value)
+	return err == nil
+}
+
+// parseDate parses a date (2006-01-02) or RFC3339 time
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestReadConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		workers  int
+		wantErr  bool
+	}{
+		{name: "yaml", contents: "workers: 4\ndestination:\n  mediakindSubscription: sub\n", workers: 4},
+		{name: "json", contents: `{"workers": 2, "destination": {"mediakindSubscription": "sub"}}`, workers: 2},
+		{name: "unknown key", contents: "worker: 4\n", wantErr: true},
+		{name: "invalid", contents: "workers: [\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "config")
+			if err := os.WriteFile(fileName, []byte(tt.contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := readConfig(fileName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readConfig() = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && (cfg.Workers != tt.workers || cfg.Destination.MediakindSubscription != "sub") {
+				t.Errorf("readConfig() = %+v, want %d workers and subscription sub", cfg, tt.workers)
+			}
+		})
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name string
+		args []string
+		// config is the value in the config file, nil if it isn't set
+		config *bool
+		want   bool
+	}{
+		{name: "default", want: true},
+		{name: "config file switches a default off", config: &no, want: false},
+		{name: "flag beats the config file", args: []string{"--option=true"}, config: &no, want: true},
+		{name: "flag switches a default off", args: []string{"--option=false"}, config: &yes, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var option bool
+			var name string
+			cmd := &cobra.Command{Use: "test", Run: func(*cobra.Command, []string) {}}
+			cmd.Flags().BoolVar(&option, "option", true, "")
+			cmd.Flags().StringVar(&name, "name", "flag default", "")
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			configBool(cmd, "option", &option, tt.config)
+			configString(cmd, "name", &name, "from config")
+			configString(cmd, "missing", &name, "ignored")
+			if option != tt.want {
+				t.Errorf("option = %v, want %v", option, tt.want)
+			}
+			if name != "from config" {
+				t.Errorf("name = %q, want the config file value", name)
+			}
+		})
+	}
+}
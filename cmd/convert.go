@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+var convertOutput string
+
+// convertCmd rewrites a migration file in the other format
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert a migration file between the json and ndjson formats",
+	Long: `Convert a migration file between the json and ndjson formats.
+
+The format of --migration-file is detected from its contents. Without --format the file is converted
+to the other format. Without --output the result is written next to the input, with a .json or .jsonl
+extension.
+
+Secrets are copied as they are in the file. With --encrypt-secrets or --redact-secrets they are
+decrypted first if needed, then protected in the converted file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		if migrationFile == "" {
+			log.Fatal("convert needs the --migration-file to convert")
+		}
+		inputFormat, err := migrate.ReadMigrationFileFormat(migrationFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !cmd.Flag("format").Changed {
+			migrationFormat = migrate.FormatNDJSON
+			if inputFormat == migrate.FormatNDJSON {
+				migrationFormat = migrate.FormatJSON
+			}
+		}
+
+		if convertOutput == "" {
+			convertOutput = strings.TrimSuffix(migrationFile, filepath.Ext(migrationFile)) + formatExt(migrationFormat)
+		}
+		if convertOutput == migrationFile {
+			log.Fatal("--output must be different from --migration-file")
+		}
+
+		contents := migrate.MigrationFileContents{}
+		sealer := newSecretSealer()
+		if sealer != nil {
+			contents = readMigrationFile(ctx)
+			contents, err = contents.SealSecrets(sealer)
+			if err != nil {
+				log.Fatalf("unable to protect migration file secrets: %v", err)
+			}
+		} else {
+			err = contents.ReadMigrationFile(ctx, migrationFile, nil)
+			if err != nil {
+				log.Fatalf("could not read migration file: %v", err)
+			}
+		}
+		err = contents.WriteMigrationFile(ctx, convertOutput, migrationFormat)
+		if err != nil {
+			log.Fatalf("unable to write migration file: %v", err)
+		}
+		log.Infof("Converted %v (%v) to %v (%v)", migrationFile, inputFormat, convertOutput, migrationFormat)
+	},
+}
+
+// formatExt returns the file extension of a migration file format
+func formatExt(format string) string {
+	if format == migrate.FormatNDJSON {
+		return ".jsonl"
+	}
+	return ".json"
+}
+
+func init() {
+	addFormatFlag(convertCmd)
+	addSecretFlags(convertCmd)
+	convertCmd.Flags().StringVar(&convertOutput, "output", "", "file to write the converted migration file to")
+
+	rootCmd.AddCommand(convertCmd)
+}
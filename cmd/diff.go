@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+// Diff options
+var (
+	diffSubscription string
+	diffResources    []string
+	diffIgnore       []string
+	diffJSON         bool
+)
+
+// diffCmd compares a migration file with another one or with mk.io
+var diffCmd = &cobra.Command{
+	Use:   "diff <old-file> [<new-file>]",
+	Short: "Compare two migration files, or a migration file with an mk.io subscription",
+	Long: `Compare two migration files, or a migration file with an mk.io subscription.
+
+Resources are matched by type and name and reported as added, removed or changed, with the fields
+that changed. With --mediakind-subscription the resources in mk.io are the new side, so "added" are
+resources mk.io has that the file doesn't. Fields that change on their own (` + strings.Join(migrate.DefaultDiffIgnore, ", ") + `)
+are ignored, use --ignore to ignore more, e.g. id when comparing an AMS export with mk.io.
+
+Secret values are compared by hash and never printed. Encrypted files are decrypted with
+MIGRATION_PASSPHRASE or --identity if given, otherwise their secrets aren't compared.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		if (len(args) == 2) == (diffSubscription != "") {
+			log.Fatal("diff needs a second migration file or --mediakind-subscription, not both")
+		}
+		kinds := append([]string{}, diffResources...)
+
+		// Compare what can be decrypted, the secrets of files that can't aren't compared
+		var keys *migrate.SecretKeys
+		if k := secretKeys(); k.Passphrase != "" || len(k.IdentityFiles) > 0 {
+			keys = &k
+		}
+		old := readUpgradedMigrationFile(ctx, args[0], keys)
+
+		var updated migrate.MigrationFileContents
+		if len(args) == 2 {
+			updated = readUpgradedMigrationFile(ctx, args[1], keys)
+		} else {
+			// Without a selection, compare the resource types in the file
+			if len(kinds) == 0 {
+				for kind, count := range old.Counts() {
+					if count > 0 {
+						kinds = append(kinds, kind)
+					}
+				}
+			}
+			updated = lookupLive(ctx, kinds)
+		}
+
+		report, err := migrate.Diff(old, updated, kinds, append(migrate.DefaultDiffIgnore, diffIgnore...))
+		if err != nil {
+			log.Fatalf("unable to compare: %v", err)
+		}
+		if diffJSON {
+			err = report.WriteJSON(os.Stdout)
+		} else {
+			err = report.WriteText(os.Stdout)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+// lookupLive reads the resources of kinds from the mk.io subscription given to diff
+func lookupLive(ctx context.Context, kinds []string) migrate.MigrationFileContents {
+	options, err := clientOptions()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The token comes from the TokenProvider of the options
+	source, err := migrate.NewMkioServiceProvider(ctx, diffSubscription, "", apiEndpoint, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	p := newPipeline()
+	p.Source = source
+	p.Kinds = migrate.ResourceKinds{}
+	for _, v := range kinds {
+		switch v {
+		case migrate.ASSETS:
+			p.Kinds.Assets = true
+		case migrate.ASSETFILTERS:
+			// Filters are looked up per asset
+			p.Kinds.Assets = true
+			p.Kinds.AssetFilters = true
+		case migrate.CONTENTKEYPOLICIES:
+			p.Kinds.ContentKeyPolicies = true
+		case migrate.STREAMINGENDPOINTS:
+			p.Kinds.StreamingEndpoints = true
+		case migrate.STREAMINGLOCATORS:
+			p.Kinds.StreamingLocators = true
+		case migrate.STREAMINGPOLICIES:
+			p.Kinds.StreamingPolicies = true
+		}
+	}
+	contents, timings, err := p.Export(ctx)
+	if err != nil {
+		log.Fatal(fmt.Errorf("unable to read mk.io subscription %v: %v", diffSubscription, err))
+	}
+	if ctx.Err() != nil {
+		log.Fatal("interrupted")
+	}
+	// Whatever failed to export would show up as removed
+	if err := exportError(timings); err != nil {
+		log.Fatal(fmt.Errorf("unable to read mk.io subscription %v: %v", diffSubscription, err))
+	}
+	return contents
+}
+
+func init() {
+	diffCmd.Flags().StringVar(&diffSubscription, "mediakind-subscription", "", "mk.io subscription to compare the migration file with")
+	diffCmd.Flags().StringSliceVar(&diffResources, "resources", nil, "resource types to compare, e.g. assets,streamingLocators (default: all, or those in the file when comparing with mk.io)")
+	diffCmd.Flags().StringSliceVar(&diffIgnore, "ignore", nil, "more fields to ignore, at any depth")
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "write the differences as JSON")
+
+	rootCmd.AddCommand(diffCmd)
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+// exportCmd exports resources into a migration file
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export resources from AMS or mk.io into a migration file",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		p := newPipeline()
+		source, err := newSource(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p.Source = source
+
+		_, timings := runExport(ctx, p)
+		printResults(timings)
+	},
+}
+
+// runExport exports the selected resources from the pipeline source and writes them to the migration file.
+// Returns the exported resources with their secrets in plaintext. Nothing is written if ctx is cancelled
+func runExport(ctx context.Context, p *migrate.Pipeline) (migrate.MigrationFileContents, []migrate.Result) {
+	// Set a timestamp on our migraiton file
+	if migrationFile == "" {
+		migrationFile = fmt.Sprintf("migration-%v.json", time.Now().Unix())
+	}
+
+	log.Info("Starting Export")
+	migrationContents, timings, err := p.Export(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Half an export would look like a complete one to import
+	if ctx.Err() != nil {
+		log.Warn("Export interrupted, the migration file was not written")
+		return migrationContents, timings
+	}
+
+	err = migrationContents.SetHeader(exportHeader(p))
+	if err != nil {
+		log.Fatalf("unable to write migration export file header: %v", err)
+	}
+
+	fileContents := migrationContents
+	if sealer := newSecretSealer(); sealer != nil {
+		fileContents, err = migrationContents.SealSecrets(sealer)
+		if err != nil {
+			log.Fatalf("unable to protect migration file secrets: %v", err)
+		}
+	}
+
+	err = fileContents.WriteMigrationFile(ctx, migrationFile, migrationFormat)
+	if err != nil {
+		// No point continuing w/o this file... Exit
+		log.Fatalf("unable to write migration export file contents: %v", err)
+	}
+	log.Infof("Done exporting. Exported content written to file: %s", migrationFile)
+
+	return migrationContents, timings
+}
+
+// exportSource identifies the account selected for export on the command line
+func exportSource() migrate.MigrationSource {
+	if mkExportSubscription != "" {
+		return migrate.MigrationSource{Kind: migrate.SourceMkio, Subscription: mkExportSubscription}
+	}
+	return migrate.MigrationSource{Kind: migrate.SourceAzure, Subscription: azSubscription, ResourceGroup: azResourceGroup, AccountName: azAccountName}
+}
+
+// exportHeader describes the export of p selected on the command line, for the header of the migration file
+func exportHeader(p *migrate.Pipeline) migrate.MigrationFileHeader {
+
+	resources := []string{}
+	selected := map[string]bool{
+		migrate.ASSETS:             assets,
+		migrate.ASSETFILTERS:       assetFilters,
+		migrate.CONTENTKEYPOLICIES: contentKeyPolicies,
+		migrate.STREAMINGENDPOINTS: streamingEndpoints,
+		migrate.STREAMINGLOCATORS:  streamingLocators,
+		migrate.STREAMINGPOLICIES:  streamingPolicies,
+	}
+	for _, kind := range migrate.ImportOrder {
+		if selected[kind] {
+			resources = append(resources, kind)
+		}
+	}
+
+	return migrate.MigrationFileHeader{
+		SchemaVersion: migrate.SchemaVersion,
+		ToolVersion:   Version,
+		Source:        exportSource(),
+		ExportTime:    time.Now().UTC(),
+		Filters:       migrate.ExportFilters{CreatedBefore: createdBefore, CreatedAfter: createdAfter, Resources: resources, ChangedSince: p.ChangedSince},
+	}
+}
+
+func init() {
+	addSourceFlags(exportCmd)
+	addResourceFlags(exportCmd)
+	addWorkerFlags(exportCmd)
+	addFormatFlag(exportCmd)
+	addSecretFlags(exportCmd)
+
+	rootCmd.AddCommand(exportCmd)
+}
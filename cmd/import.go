@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+// importCmd imports a migration file into mk.io
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import a migration file into mk.io",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		// Retry only the resources listed in a failure manifest from a previous import
+		var retryManifest *migrate.FailureManifest
+		if retryFailuresFile != "" {
+			retryManifest = loadRetryManifest()
+			// Keep the journal of the original run so it can still be rolled back
+			resume = true
+		}
+
+		// Resuming only makes sense against the migration file of the interrupted run
+		if resume && migrationFile == "" {
+			log.Fatal("--resume requires --migration-file of the interrupted import")
+		}
+
+		// Log into mk.io first so we know if it fails before we do any work
+		p := newPipeline()
+		destination, err := newDestination(ctx)
+		if err != nil {
+			log.Fatalf("import Error: %v", err)
+		}
+		p.Destination = destination
+
+		// Only look up what would happen
+		if dryRun {
+			runPlan(ctx, p, retryManifest)
+			return
+		}
+
+		timings := runImport(ctx, p, nil, retryManifest)
+		printResults(timings)
+	},
+}
+
+// loadRetryManifest reads the failure manifest given to --retry-failures and selects the resource types that failed
+func loadRetryManifest() *migrate.FailureManifest {
+	manifest, err := migrate.ReadFailureManifest(retryFailuresFile)
+	if err != nil {
+		log.Fatalf("could not read failure manifest: %v", err)
+	}
+	if migrationFile == "" {
+		migrationFile = manifest.MigrationFile
+	}
+
+	// Only the resource types that failed need to be imported again
+	assets, assetFilters, contentKeyPolicies = false, false, false
+	streamingLocators, streamingEndpoints, streamingPolicies = false, false, false
+	for _, f := range manifest.Failures {
+		switch f.Kind {
+		case migrate.ASSETS:
+			assets = true
+		case migrate.ASSETFILTERS:
+			assetFilters = true
+		case migrate.CONTENTKEYPOLICIES:
+			contentKeyPolicies = true
+		case migrate.STREAMINGLOCATORS:
+			streamingLocators = true
+		case migrate.STREAMINGENDPOINTS:
+			streamingEndpoints = true
+		case migrate.STREAMINGPOLICIES:
+			streamingPolicies = true
+		}
+	}
+	log.Infof("Retrying %d failed resources from %v", len(manifest.Failures), migrationFile)
+
+	return &manifest
+}
+
+// runImport imports the migration file into the pipeline destination and writes the failure manifest. exported are
+// the resources just exported by migrate, nil to read them from the migration file
+func runImport(ctx context.Context, p *migrate.Pipeline, exported *migrate.MigrationFileContents, retryManifest *migrate.FailureManifest) []migrate.Result {
+	log.Info("Starting Import to mk.io")
+
+	// Read migration file & populate migration contents from it
+	var contents migrate.MigrationFileContents
+	if exported != nil {
+		contents = *exported
+	} else {
+		contents = readMigrationFile(ctx)
+	}
+
+	// Reduce the contents to the resources that failed last time
+	if retryManifest != nil {
+		contents = contents.FilterFailures(retryManifest.Failures)
+	}
+
+	closeStores := openImportStores(p)
+	defer closeStores()
+
+	timings, err := p.Import(ctx, contents)
+	if err != nil {
+		log.Fatal(err)
+	}
+	writeFailureManifest(timings)
+
+	return timings
+}
+
+// openImportStores opens the checkpoint file and, when resources can be overwritten, the snapshot file of an import. The returned func closes them
+func openImportStores(p *migrate.Pipeline) func() {
+	// Track the status of each resource so an interrupted import can be resumed
+	if checkpointFile == "" {
+		checkpointFile = migrationFile + ".checkpoint"
+	}
+	checkpoint, err := migrate.OpenCheckpointStore(checkpointFile, resume, mkImportSubscription)
+	if err != nil {
+		log.Fatalf("could not open checkpoint file: %v", err)
+	}
+	p.Checkpoint = checkpoint
+
+	// Keep a copy of everything we overwrite so it can be put back with restore-snapshot
+	if p.Overwrite || p.OverwriteChanged {
+		if snapshotFile == "" {
+			snapshotFile = migrationFile + ".snapshots"
+		}
+		snapshots, err := migrate.OpenSnapshotStore(snapshotFile, newSnapshotSealer())
+		if err != nil {
+			log.Fatalf("could not open snapshot file: %v", err)
+		}
+		p.Snapshots = snapshots
+	}
+
+	return func() {
+		p.Snapshots.Close()
+		p.Checkpoint.Close()
+	}
+}
+
+// writeFailureManifest writes out everything that failed so it can be retried with --retry-failures
+func writeFailureManifest(timings []migrate.Result) {
+	failures := migrate.Failures(timings)
+	if failureManifestFile == "" {
+		failureManifestFile = migrationFile + ".failures.json"
+	}
+	err := migrate.WriteFailureManifest(failureManifestFile, migrationFile, failures)
+	if err != nil {
+		log.Errorf("unable to write failure manifest: %v", err)
+	} else if len(failures) > 0 {
+		log.Infof("%d failures written to %v. Use --retry-failures to import them again", len(failures), failureManifestFile)
+	}
+}
+
+func init() {
+	addDestinationFlags(importCmd)
+	addResourceFlags(importCmd)
+	addWorkerFlags(importCmd)
+	addImportFlags(importCmd)
+	addEncryptFlags(importCmd)
+	importCmd.Flags().StringVar(&retryFailuresFile, "retry-failures", "", "Import only the resources listed in this failure manifest")
+	importCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only show what would be created, skipped, updated or replaced. Same as the plan command")
+
+	rootCmd.AddCommand(importCmd)
+}
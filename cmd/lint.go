@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+var printSchema bool
+
+// lintCmd checks a migration file before it is imported
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check a migration file against the migration file schema without importing it",
+	Long: `Check a migration file against the migration file schema without importing it.
+
+The file is validated against the JSON Schema of the current schema version, the counts and checksums
+of its header are compared with its resources, and resources using an asset missing from the file are
+reported. Nothing is read from or written to mk.io. Exits with status 1 if any error is found.
+
+Use --print-schema to print the JSON Schema instead.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		if printSchema {
+			os.Stdout.Write(migrate.MigrationFileSchema)
+			return
+		}
+		if migrationFile == "" {
+			log.Fatal("lint needs the --migration-file to check")
+		}
+
+		issues, err := migrate.LintMigrationFile(ctx, migrationFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		errors := 0
+		for _, v := range issues {
+			fmt.Println(v)
+			if v.Severity == migrate.LintError {
+				errors++
+			}
+		}
+		if errors > 0 {
+			log.Errorf("%v has %d errors and %d warnings", migrationFile, errors, len(issues)-errors)
+			os.Exit(1)
+		}
+		log.Infof("%v is valid, %d warnings", migrationFile, len(issues))
+	},
+}
+
+func init() {
+	lintCmd.Flags().BoolVar(&printSchema, "print-schema", false, "print the JSON Schema of migration files and exit")
+
+	rootCmd.AddCommand(lintCmd)
+}
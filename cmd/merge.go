@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+// Merge options
+var (
+	mergeOutput    string
+	conflictPolicy string
+	mergePlaintext bool
+)
+
+// mergeCmd combines migration files
+var mergeCmd = &cobra.Command{
+	Use:   "merge <migration-file>...",
+	Short: "Merge migration files into one",
+	Long: `Merge migration files into one.
+
+Resources are deduplicated by name, Asset Filters by asset and name. Resources that appear with
+different contents in more than one file are resolved with --on-conflict:
+
+  fail    stop without writing anything (default)
+  first   keep the resource from the first file it appears in
+  last    keep the resource from the last file it appears in
+  newest  keep the resource modified last
+
+Encrypted secrets are decrypted with MIGRATION_PASSPHRASE or --identity. When any file has encrypted
+secrets, the merged file needs --encrypt-secrets to encrypt them again, --redact-secrets to leave them
+out or --plaintext to write them decrypted. Without --format the merged file has the format of the
+first file.`,
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		if mergeOutput == "" {
+			log.Fatal("merge needs the --output file to write")
+		}
+		if !cmd.Flag("format").Changed {
+			format, err := migrate.ReadMigrationFileFormat(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			migrationFormat = format
+		}
+
+		// Secrets are decrypted once we know they won't end up in plaintext by mistake
+		files := []migrate.MigrationFileContents{}
+		encrypted := false
+		for _, v := range args {
+			contents := readUpgradedMigrationFile(ctx, v, nil)
+			if contents.Header != nil && contents.Header.Secrets != nil && contents.Header.Secrets.Mode == migrate.SecretsEncrypted {
+				encrypted = true
+			}
+			files = append(files, contents)
+		}
+		if encrypted && !encryptSecrets && !redactSecrets && !mergePlaintext {
+			log.Fatal("the secrets of the migration files are encrypted, use --encrypt-secrets to encrypt them in the merged file, --redact-secrets to leave them out or --plaintext to write them decrypted")
+		}
+		keys := secretKeys()
+		for i := range files {
+			if err := files[i].DecryptSecrets(keys); err != nil {
+				log.Fatalf("could not decrypt the secrets of %v: %v", args[i], err)
+			}
+		}
+
+		merged, conflicts, err := migrate.Merge(conflictPolicy, files...)
+		if err != nil {
+			log.Fatalf("unable to merge: %v", err)
+		}
+		for _, v := range conflicts {
+			log.Warnf("%v %v differs between files, kept the one from %v", v.Kind, v.Name, args[v.Kept])
+		}
+		merged.Header.ToolVersion = Version
+
+		if sealer := newSecretSealer(); sealer != nil {
+			merged, err = merged.SealSecrets(sealer)
+			if err != nil {
+				log.Fatalf("unable to protect migration file secrets: %v", err)
+			}
+		}
+		err = merged.WriteMigrationFile(ctx, mergeOutput, migrationFormat)
+		if err != nil {
+			log.Fatalf("unable to write migration file: %v", err)
+		}
+		counts := merged.Counts()
+		log.Infof("Merged %d files into %v: %d assets, %d streaming locators, %d conflicts", len(args), mergeOutput, counts[migrate.ASSETS], counts[migrate.STREAMINGLOCATORS], len(conflicts))
+	},
+}
+
+func init() {
+	addFormatFlag(mergeCmd)
+	addSecretFlags(mergeCmd)
+	mergeCmd.Flags().StringVar(&mergeOutput, "output", "", "file to write the merged migration file to")
+	mergeCmd.Flags().BoolVar(&mergePlaintext, "plaintext", false, "write the decrypted secrets of encrypted migration files to the merged file in plaintext")
+	mergeCmd.Flags().StringVar(&conflictPolicy, "on-conflict", migrate.MergeFail, "how to resolve resources that differ between files: "+strings.Join(migrate.MergePolicies, ", "))
+
+	rootCmd.AddCommand(mergeCmd)
+}
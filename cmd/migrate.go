@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+var validateAfterImport bool
+var stream bool
+
+// Incremental options
+var (
+	incremental   bool
+	watermarkFile string
+)
+
+// migrateCmd exports and imports in a single run
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Export resources and import them into mk.io in a single run",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		// Nothing is kept in memory to validate against
+		if stream && validateAfterImport {
+			log.Fatal("--validate can't be used with --stream. Run validate on the exported file instead")
+		}
+		// Streaming has no migration file contents to work out the new watermarks from
+		if stream && incremental {
+			log.Fatal("--incremental can't be used with --stream")
+		}
+		// The journal of a streaming migration is always written one resource per line
+		if stream && cmd.Flag("format").Changed && migrationFormat != migrate.FormatNDJSON {
+			log.Fatal("--stream always writes an ndjson migration file")
+		}
+
+		p := newPipeline()
+		var watermarks migrate.Watermarks
+		if incremental {
+			var err error
+			watermarks, err = migrate.ReadWatermarks(watermarkFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			p.ChangedSince = watermarks.Since(exportSource())
+			// Resources changed since the last run are updated in mk.io, unless they are the same there. The first
+			// run of a resource type leaves what is already in mk.io alone
+			p.OverwriteChanged = true
+			p.SkipUnchanged = true
+			for _, kind := range migrate.ImportOrder {
+				if t, ok := p.ChangedSince[kind]; ok {
+					log.Infof("Migrating %v changed since %v", kind, t.Format(time.RFC3339))
+				}
+			}
+		}
+
+		// Log into mk.io first so we know if it fails before we do any work
+		destination, err := newDestination(ctx)
+		if err != nil {
+			log.Fatalf("import Error: %v", err)
+		}
+		p.Destination = destination
+
+		source, err := newSource(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p.Source = source
+
+		if stream {
+			timings := runStream(ctx, p)
+			printResults(timings)
+			// What could be exported was imported, but the migration isn't complete
+			if err := exportError(timings); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
+		// The exported resources are imported as they are, the migration file may have their secrets protected
+		contents, timings := runExport(ctx, p)
+		if ctx.Err() != nil {
+			printResults(timings)
+			return
+		}
+		timings = append(timings, runImport(ctx, p, &contents, nil)...)
+		if validateAfterImport {
+			runValidate(ctx, p, &contents)
+		}
+		if incremental {
+			advanceWatermarks(watermarks, contents, timings)
+		}
+
+		printResults(timings)
+	},
+}
+
+// runStream imports the resources as they are exported, writing each one to the export journal on the way
+func runStream(ctx context.Context, p *migrate.Pipeline) []migrate.Result {
+	if migrationFile == "" {
+		migrationFile = fmt.Sprintf("migration-%v.jsonl", time.Now().Unix())
+	}
+	journal, err := migrate.CreateExportJournal(migrationFile, newSecretSealer())
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The counts and checksums of the resources aren't known yet, so the journal's header has neither
+	header := exportHeader(p)
+	if err := journal.WriteHeader(&header); err != nil {
+		log.Fatalf("unable to write export journal header: %v", err)
+	}
+
+	closeStores := openImportStores(p)
+	defer closeStores()
+
+	log.Info("Starting streaming migration")
+	timings, err := p.Stream(ctx, journal)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := journal.Close(); err != nil {
+		log.Errorf("%v", err)
+	}
+	log.Infof("Done. Exported resources written to journal: %s", migrationFile)
+	writeFailureManifest(timings)
+
+	return timings
+}
+
+// advanceWatermarks moves the watermarks past the resources of an incremental migration and saves them
+func advanceWatermarks(watermarks migrate.Watermarks, contents migrate.MigrationFileContents, timings []migrate.Result) {
+	source := exportSource()
+	moved := watermarks.Advance(source, contents, timings)
+	if len(moved) == 0 {
+		log.Info("Watermarks unchanged")
+		return
+	}
+	if err := watermarks.Write(watermarkFile); err != nil {
+		log.Errorf("%v", err)
+		return
+	}
+	for _, kind := range moved {
+		log.Infof("Watermark of %v for %v moved to %v", kind, source, watermarks.Since(source)[kind].Format(time.RFC3339))
+	}
+}
+
+func init() {
+	addSourceFlags(migrateCmd)
+	addDestinationFlags(migrateCmd)
+	addResourceFlags(migrateCmd)
+	addWorkerFlags(migrateCmd)
+	addImportFlags(migrateCmd)
+	addFormatFlag(migrateCmd)
+	addSecretFlags(migrateCmd)
+	migrateCmd.Flags().BoolVar(&stream, "stream", false, "import each resource as soon as it is exported instead of writing the whole migration file first. Exported resources are written to --migration-file in the ndjson format")
+	migrateCmd.Flags().BoolVar(&incremental, "incremental", false, "only migrate the resources created or modified since the last incremental run, and update them in mk.io")
+	migrateCmd.Flags().StringVar(&watermarkFile, "watermark-file", "watermarks.json", "file keeping the time of the newest resource migrated by --incremental, per source account and resource type")
+	migrateCmd.Flags().BoolVar(&validateAfterImport, "validate", false, "validate the StreamingLocators in mk.io after the import")
+
+	rootCmd.AddCommand(migrateCmd)
+}
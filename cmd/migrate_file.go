@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+var upgradeOutput string
+
+// migrateFileCmd groups the commands that maintain migration files
+var migrateFileCmd = &cobra.Command{
+	Use:   "migrate-file",
+	Short: "Maintain migration files",
+}
+
+// upgradeCmd rewrites a migration file in the current schema version
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Upgrade a migration file to the current schema version",
+	Long: `Upgrade a migration file to the current schema version.
+
+The file keeps its format. Without --output the file is rewritten in place and the original is kept
+with a .bak extension. Files written before the header was introduced get a header with an unknown
+source, and the time the file was last modified as the export time.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		if migrationFile == "" {
+			log.Fatal("upgrade needs the --migration-file to upgrade")
+		}
+		format, err := migrate.ReadMigrationFileFormat(migrationFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		info, err := os.Stat(migrationFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		contents := migrate.MigrationFileContents{}
+		err = contents.ReadMigrationFile(ctx, migrationFile, nil)
+		if err != nil {
+			log.Fatalf("could not read migration file: %v", err)
+		}
+		from, err := contents.Upgrade(Version, info.ModTime())
+		if err != nil {
+			log.Fatal(err)
+		}
+		if from == migrate.SchemaVersion {
+			log.Infof("%v already has schema version %d", migrationFile, from)
+			return
+		}
+
+		output := upgradeOutput
+		if output == "" {
+			output = migrationFile
+			err = os.Rename(migrationFile, migrationFile+".bak")
+			if err != nil {
+				log.Fatalf("unable to back up %v: %v", migrationFile, err)
+			}
+			log.Infof("Original kept in %v", migrationFile+".bak")
+		}
+		err = contents.WriteMigrationFile(ctx, output, format)
+		if err != nil {
+			log.Fatalf("unable to write migration file: %v", err)
+		}
+		log.Infof("Upgraded %v from schema version %d to %d", output, from, migrate.SchemaVersion)
+	},
+}
+
+func init() {
+	upgradeCmd.Flags().StringVar(&upgradeOutput, "output", "", "file to write the upgraded migration file to (default: rewrite --migration-file)")
+
+	migrateFileCmd.AddCommand(upgradeCmd)
+	rootCmd.AddCommand(migrateFileCmd)
+}
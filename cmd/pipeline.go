@@ -0,0 +1,452 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
+)
+
+// Source options
+var (
+	azSubscription       string
+	azResourceGroup      string
+	azAccountName        string
+	mkExportSubscription string
+	createdBefore        string
+	createdAfter         string
+
+	// Azure credential. The client secret and certificate password are read from the AZURE_CLIENT_SECRET and
+	// AZURE_CLIENT_CERTIFICATE_PASSWORD environment variables
+	azCredential        string
+	azTenantID          string
+	azClientID          string
+	azClientCertificate string
+	azCloud             string
+)
+
+// Output options
+var (
+	migrationFormat string
+)
+
+// Secret options. The passphrase is read from the MIGRATION_PASSPHRASE environment variable
+var (
+	encryptSecrets   bool
+	redactSecrets    bool
+	secretRecipients []string
+)
+
+// Destination options
+var (
+	mkImportSubscription string
+)
+
+// Resource selection
+var (
+	assets             bool
+	assetFilters       bool
+	contentKeyPolicies bool
+	streamingLocators  bool
+	streamingEndpoints bool
+	streamingPolicies  bool
+)
+
+// Import options
+var (
+	workers                  int
+	overwrite                bool
+	resume                   bool
+	fairplayAmsCompatibility bool
+	checkpointFile           string
+	failureManifestFile      string
+	retryFailuresFile        string
+	snapshotFile             string
+	dryRun                   bool
+	unsupportedCdn           string
+)
+
+func addSourceFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&azSubscription, "azure-subscription", "", "Azure Subscription ID for existing AMS")
+	cmd.Flags().StringVar(&azResourceGroup, "azure-resource-group", "", "Resource Group for existing AMS")
+	cmd.Flags().StringVar(&azAccountName, "azure-account-name", "", "Account Name for existing AMS")
+	addAzureCredentialFlags(cmd)
+	cmd.Flags().StringVar(&mkExportSubscription, "mediakind-export-subscription", "", "Mediakind Subscription ID for export in mk.io")
+	addCreatedFlags(cmd)
+}
+
+func addCreatedFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&createdBefore, "created-before", "", "filter export for resources created before date")
+	cmd.Flags().StringVar(&createdAfter, "created-after", "", "filter export for resources created after date")
+}
+
+func addAzureCredentialFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&azCredential, "azure-credential", migrate.CredentialDefault, "how to log into Azure: "+strings.Join(migrate.CredentialTypes, ", "))
+	cmd.Flags().StringVar(&azTenantID, "azure-tenant-id", "", "Azure AD tenant to log into. Defaults to AZURE_TENANT_ID")
+	cmd.Flags().StringVar(&azClientID, "azure-client-id", "", "client ID of the service principal, workload identity or user-assigned managed identity. Defaults to AZURE_CLIENT_ID")
+	cmd.Flags().StringVar(&azClientCertificate, "azure-client-certificate", "", "PEM or PKCS12 file of the certificate credential. The key password is read from AZURE_CLIENT_CERTIFICATE_PASSWORD")
+	cmd.Flags().StringVar(&azCloud, "azure-cloud", migrate.CloudPublic, "Azure cloud of the AMS account: public, china or government")
+}
+
+func addFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&migrationFormat, "format", migrate.FormatJSON, "format of the migration file: json (a single JSON object) or ndjson (one resource per line)")
+}
+
+func addSecretFlags(cmd *cobra.Command) {
+	addEncryptFlags(cmd)
+	cmd.Flags().BoolVar(&redactSecrets, "redact-secrets", false, "leave the secrets out of the migration file, so it can be shared. The file can't be imported")
+}
+
+func addEncryptFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&encryptSecrets, "encrypt-secrets", false, "encrypt content key values, FairPlay ASK/PFX/passwords and token signing keys in the migration and snapshot files, with MIGRATION_PASSPHRASE or the --recipient keys")
+	cmd.Flags().StringSliceVar(&secretRecipients, "recipient", nil, "age public key (age1...) to encrypt the secrets for, instead of a passphrase. Can be repeated")
+}
+
+func addDestinationFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&mkImportSubscription, "mediakind-import-subscription", "", "Mediakind Subscription ID for import in mk.io")
+}
+
+func addResourceFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&assets, "assets", false, "Run on Assets")
+	cmd.Flags().BoolVar(&assetFilters, "asset-filters", false, "Run on Asset Filters")
+	cmd.Flags().BoolVar(&contentKeyPolicies, "content-key-policies", false, "Run on ContentKeyPolicies")
+	cmd.Flags().BoolVar(&streamingLocators, "streaming-locators", false, "run on StreamingLocators")
+	cmd.Flags().BoolVar(&streamingEndpoints, "streaming-endpoints", false, "run on StreamingEndpoints")
+	cmd.Flags().BoolVar(&streamingPolicies, "streaming-policies", false, "run on StreamingPolicies")
+}
+
+func addWorkerFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&workers, "workers", 1, "number of workers to run in parallel")
+}
+
+func addImportFlags(cmd *cobra.Command) {
+	addImportOptionFlags(cmd)
+	cmd.Flags().StringVar(&checkpointFile, "checkpoint-file", "", "Import checkpoint filename (default: <migration-file>.checkpoint)")
+	cmd.Flags().StringVar(&failureManifestFile, "failure-manifest", "", "Import failure manifest filename (default: <migration-file>.failures.json)")
+	cmd.Flags().StringVar(&snapshotFile, "snapshot-file", "", "File keeping a copy of every resource replaced by --overwrite (default: <migration-file>.snapshots)")
+}
+
+func addImportOptionFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&overwrite, "overwrite", false, "overwrite resources that already exist")
+	cmd.Flags().BoolVar(&resume, "resume", false, "resume an interrupted import, skipping resources already handled according to the checkpoint file")
+	cmd.Flags().BoolVar(&fairplayAmsCompatibility, "fairplay-ams-compatibility", false, "set fairPlayAmsCompatibility=true for all fairplay content key policies")
+	addUnsupportedCdnFlag(cmd)
+}
+
+// addUnsupportedCdnFlag adds --unsupported-cdn. Its default depends on the command, so it is empty
+func addUnsupportedCdnFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&unsupportedCdn, "unsupported-cdn", "", "what to do with streaming endpoints whose CDN provider mk.io doesn't support: "+strings.Join(migrate.CdnPolicies, ", ")+" (default: prompt, fail for sync)")
+}
+
+// selectedKinds returns the resource types selected on the command line
+func selectedKinds() migrate.ResourceKinds {
+	return migrate.ResourceKinds{
+		Assets:             assets,
+		AssetFilters:       assetFilters,
+		ContentKeyPolicies: contentKeyPolicies,
+		StreamingLocators:  streamingLocators,
+		StreamingEndpoints: streamingEndpoints,
+		StreamingPolicies:  streamingPolicies,
+	}
+}
+
+// newPipeline creates the pipeline shared by all stages. Stages add their providers to it
+func newPipeline() *migrate.Pipeline {
+	return &migrate.Pipeline{
+		Kinds:                    selectedKinds(),
+		CreatedBefore:            createdBefore,
+		CreatedAfter:             createdAfter,
+		Workers:                  workers,
+		Overwrite:                overwrite,
+		FairplayAmsCompatibility: fairplayAmsCompatibility,
+		Transformations:          transformations,
+		UnsupportedCdn:           cdnPolicy(migrate.CdnPrompt),
+		ConfirmCdn:               confirmCdn,
+	}
+}
+
+// cdnPolicy returns the --unsupported-cdn policy, or value if it isn't set
+func cdnPolicy(value migrate.CdnPolicy) migrate.CdnPolicy {
+	if unsupportedCdn == "" {
+		return value
+	}
+	return migrate.CdnPolicy(unsupportedCdn)
+}
+
+// cdnPrompt serializes the CDN Provider question, so only one StreamingEndpoint asks the user at a time
+var cdnPrompt sync.Mutex
+
+// confirmCdn asks the user whether to change the CDN provider of a StreamingEndpoint to Akamai
+func confirmCdn(name string, provider string) bool {
+	cdnPrompt.Lock()
+	defer cdnPrompt.Unlock()
+
+	log.Info("CDN Provider mismatch. User input required")
+	var answer string
+	fmt.Printf("CDN Provider %v of %v isn't supported. Change to Akamai [y/N]\n", provider, name)
+	fmt.Scan(&answer)
+	return answer == "y" || answer == "Y"
+}
+
+// secretKeys returns the keys to encrypt and decrypt migration file secrets with
+func secretKeys() migrate.SecretKeys {
+	return migrate.SecretKeys{
+		Passphrase:    os.Getenv("MIGRATION_PASSPHRASE"),
+		Recipients:    secretRecipients,
+		IdentityFiles: identityFiles,
+	}
+}
+
+// newSecretSealer returns the sealer selected by --encrypt-secrets or --redact-secrets, nil if the secrets are
+// written as they are
+func newSecretSealer() *migrate.SecretSealer {
+	if redactSecrets {
+		return migrate.NewSecretRedacter()
+	}
+	return newSnapshotSealer()
+}
+
+// newSnapshotSealer returns the sealer selected by --encrypt-secrets for the snapshot file, nil if the secrets are
+// kept in plaintext. Redacted snapshots couldn't be restored
+func newSnapshotSealer() *migrate.SecretSealer {
+	if !encryptSecrets {
+		return nil
+	}
+	keys := secretKeys()
+	// Recipients replace the passphrase
+	if len(keys.Recipients) > 0 {
+		keys.Passphrase = ""
+	}
+	sealer, err := migrate.NewSecretEncrypter(keys)
+	if err != nil {
+		log.Fatalf("unable to encrypt secrets: %v", err)
+	}
+	return sealer
+}
+
+// mkioAuth is where the mk.io token comes from: the token file, the keyring, the token command or the MKIO_TOKEN
+// environment variable
+func mkioAuth() (mkiosdk.TokenProvider, error) {
+	sources := []string{}
+	for name, value := range map[string]string{"--mkio-token-file": mkioTokenFile, "--mkio-keyring-service": mkioKeyringService, "--mkio-token-command": mkioTokenCommand} {
+		if value != "" {
+			sources = append(sources, name)
+		}
+	}
+	if len(sources) > 1 {
+		sort.Strings(sources)
+		return nil, fmt.Errorf("the mk.io token can only come from one of %v", strings.Join(sources, ", "))
+	}
+
+	switch {
+	case mkioTokenFile != "":
+		return mkiosdk.NewTokenFile(mkioTokenFile, mkioTokenWait), nil
+	case mkioKeyringService != "":
+		return mkiosdk.NewKeyringToken(mkioKeyringService, mkioKeyringAccount, mkioTokenWait), nil
+	case mkioTokenCommand != "":
+		// Start with MKIO_TOKEN if it is set, and run the command once it expires
+		return mkiosdk.NewRefreshingToken(os.Getenv("MKIO_TOKEN"), mkiosdk.CommandRefresh(mkioTokenCommand)), nil
+	}
+
+	mkToken := os.Getenv("MKIO_TOKEN")
+	if mkToken == "" {
+		return nil, fmt.Errorf("could not find MKIO_TOKEN environment variable, --mkio-token-file, --mkio-keyring-service or --mkio-token-command")
+	}
+	return mkiosdk.StaticToken(mkToken), nil
+}
+
+// retryPolicy is the retry policy of the mk.io requests, from the retry options
+func retryPolicy() *mkiosdk.RetryPolicy {
+	policy := mkiosdk.DefaultRetryPolicy()
+	policy.MaxAttempts = maxAttempts
+	policy.BaseDelay = retryBaseDelay
+	policy.MaxDelay = retryMaxDelay
+	return policy
+}
+
+// rateLimits are the limits of the mk.io requests, from the rate limit options
+func rateLimits() (mkiosdk.RateLimits, error) {
+	limits := mkiosdk.RateLimits{
+		Default:  mkiosdk.RateLimit{Rate: rateLimit, Burst: rateBurst},
+		Methods:  map[string]mkiosdk.RateLimit{},
+		Adaptive: adaptiveRateLimit,
+	}
+	for method, value := range methodRateLimits {
+		method = strings.ToUpper(method)
+		if method != http.MethodGet && method != http.MethodPut && method != http.MethodPost && method != http.MethodDelete {
+			return limits, fmt.Errorf("cannot rate limit HTTP method %q. Use GET, PUT, POST or DELETE", method)
+		}
+		// <rate> or <rate>:<burst>
+		limit := mkiosdk.RateLimit{Burst: rateBurst}
+		r, burst, hasBurst := strings.Cut(value, ":")
+		var err error
+		limit.Rate, err = strconv.ParseFloat(r, 64)
+		if err == nil && hasBurst {
+			limit.Burst, err = strconv.Atoi(burst)
+		}
+		if err != nil {
+			return limits, fmt.Errorf("%v rate limit %q is not <requests per second>[:<burst>]", method, value)
+		}
+		limits.Methods[method] = limit
+	}
+	return limits, limits.Validate()
+}
+
+// clientOptions are the options of the mk.io clients of a subscription. They share a rate limiter, their
+// connections and their token
+func clientOptions() (*mkiosdk.ClientOptions, error) {
+	// The limits were checked by validateOptions
+	limits, _ := rateLimits()
+	auth, err := mkioAuth()
+	if err != nil {
+		return nil, err
+	}
+	return &mkiosdk.ClientOptions{
+		Retry:         retryPolicy(),
+		RateLimiter:   mkiosdk.NewRateLimiter(limits),
+		HTTPClient:    &http.Client{},
+		TokenProvider: auth,
+	}, nil
+}
+
+// azureCredentials is the Azure credential selected by the options, with the IDs and secrets that weren't given as
+// options taken from the environment
+func azureCredentials() migrate.AzureCredentialConfig {
+	credentials := migrate.AzureCredentialConfig{
+		Type:                azCredential,
+		TenantID:            azTenantID,
+		ClientID:            azClientID,
+		ClientSecret:        os.Getenv("AZURE_CLIENT_SECRET"),
+		CertificatePath:     azClientCertificate,
+		CertificatePassword: os.Getenv("AZURE_CLIENT_CERTIFICATE_PASSWORD"),
+		Cloud:               azCloud,
+	}
+	if credentials.TenantID == "" {
+		credentials.TenantID = os.Getenv("AZURE_TENANT_ID")
+	}
+	if credentials.ClientID == "" {
+		credentials.ClientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+	return credentials
+}
+
+// newSource logs into the Azure or mk.io subscription selected for export
+func newSource(ctx context.Context) (migrate.SourceProvider, error) {
+	if (azSubscription != "" || azResourceGroup != "" || azAccountName != "") && mkExportSubscription != "" {
+		return nil, fmt.Errorf("export Error: cannot export from both Azure and mk.io subscription")
+	}
+
+	if azSubscription != "" && azResourceGroup != "" && azAccountName != "" {
+		azureClient, err := migrate.NewAzureServiceProvider(azSubscription, azResourceGroup, azAccountName, azureCredentials())
+		if err != nil {
+			return nil, fmt.Errorf("unable to log into Azure: %v", err)
+		}
+		return azureClient, nil
+	}
+
+	if mkExportSubscription != "" {
+		options, err := clientOptions()
+		if err != nil {
+			return nil, fmt.Errorf("export Error: %v", err)
+		}
+		// The token comes from the TokenProvider of the options
+		return migrate.NewMkioServiceProvider(ctx, mkExportSubscription, "", apiEndpoint, options)
+	}
+
+	return nil, fmt.Errorf("export Error: cannot export without Azure or mk.io subscription information")
+}
+
+// newDestination logs into the mk.io subscription selected for import
+func newDestination(ctx context.Context) (*migrate.MkioServiceProvider, error) {
+	if mkImportSubscription == "" {
+		return nil, fmt.Errorf("missing --mediakind-import-subscription")
+	}
+	options, err := clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	// The token comes from the TokenProvider of the options
+	return migrate.NewMkioServiceProvider(ctx, mkImportSubscription, "", apiEndpoint, options)
+}
+
+// readUpgradedMigrationFile reads a migration file and upgrades it to the current schema version in memory. With nil
+// keys the secrets are left as they are in the file
+func readUpgradedMigrationFile(ctx context.Context, fileName string, keys *migrate.SecretKeys) migrate.MigrationFileContents {
+	contents := migrate.MigrationFileContents{}
+	err := contents.ReadMigrationFile(ctx, fileName, keys)
+	if err != nil {
+		log.Fatalf("could not read migration file %v: %v", fileName, err)
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := contents.Upgrade(Version, info.ModTime()); err != nil {
+		log.Fatalf("could not upgrade migration file %v: %v", fileName, err)
+	}
+	return contents
+}
+
+// readMigrationFile reads the migration file selected on the command line
+func readMigrationFile(ctx context.Context) migrate.MigrationFileContents {
+	if migrationFile == "" {
+		log.Fatal("missing --migration-file")
+	}
+	contents := migrate.MigrationFileContents{}
+	keys := secretKeys()
+	err := contents.ReadMigrationFile(ctx, migrationFile, &keys)
+	if err != nil {
+		log.Fatalf("could not read migration file: %v", err)
+	}
+	if contents.Version() < migrate.SchemaVersion {
+		log.Warnf("migration file has schema version %d, run migrate-file upgrade to bring it up to %d", contents.Version(), migrate.SchemaVersion)
+	}
+	return contents
+}
+
+// printResults writes out the statistics and failures of a run
+func printResults(timings []migrate.Result) {
+	fmt.Println("Results:")
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Operation\tResource\tMigrated\tSkipped\tFailed\tRetries\tDuration\n")
+	for _, v := range timings {
+		// Some output to give stats at the end
+		if v.Operation == migrate.EXPORT {
+			_, _ = fmt.Fprintf(w, "%v\t%v\t%d\t-\t-\t%d\t%v\n", v.Operation, v.Resource, v.Migrated, v.Retries, v.Duration)
+		} else if v.Operation == migrate.IMPORT || v.Operation == migrate.ROLLBACK || v.Operation == migrate.RESTORE {
+			_, _ = fmt.Fprintf(w, "%v\t%v\t%d\t%d\t%d\t%d\t%v\n", v.Operation, v.Resource, v.Migrated, v.Skipped, len(v.Failures), v.Retries, v.Duration)
+		}
+	}
+	w.Flush()
+
+	fmt.Println("\nFailures:")
+	for _, v := range timings {
+		if v.Err != nil {
+			fmt.Printf("\tFailed to %v %v: %v\n", v.Operation, v.Resource, v.Err)
+		}
+		if len(v.Failures) > 0 {
+			fmt.Printf("\tFailed to %v %v: %v\n", v.Operation, v.Resource, v.Failures)
+		}
+	}
+}
+
+// exportError returns the first export that failed as a whole, e.g. because the resources couldn't be listed
+func exportError(timings []migrate.Result) error {
+	for _, v := range timings {
+		if v.Operation == migrate.EXPORT && v.Err != nil {
+			return fmt.Errorf("unable to export %v: %v", v.Resource, v.Err)
+		}
+	}
+	return nil
+}
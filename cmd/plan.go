@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+// planCmd shows what an import would do without changing anything in mk.io
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show what import would create, skip, update or replace in mk.io",
+	Long: `Show what import would create, skip, update or replace in mk.io.
+
+Only the lookups are made. Nothing is created or deleted. This is the same as import --dry-run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		var retryManifest *migrate.FailureManifest
+		if retryFailuresFile != "" {
+			retryManifest = loadRetryManifest()
+		}
+
+		p := newPipeline()
+		destination, err := newDestination(ctx)
+		if err != nil {
+			log.Fatalf("plan Error: %v", err)
+		}
+		p.Destination = destination
+
+		runPlan(ctx, p, retryManifest)
+	},
+}
+
+// runPlan looks up the contents of the migration file in the pipeline destination and prints the planned actions
+func runPlan(ctx context.Context, p *migrate.Pipeline, retryManifest *migrate.FailureManifest) {
+	log.Info("Planning Import to mk.io")
+
+	contents := readMigrationFile(ctx)
+	if retryManifest != nil {
+		contents = contents.FilterFailures(retryManifest.Failures)
+	}
+
+	// Only read the checkpoint when resuming. Opening it otherwise would clear it
+	if resume {
+		if checkpointFile == "" {
+			checkpointFile = migrationFile + ".checkpoint"
+		}
+		checkpoint, err := migrate.OpenCheckpointStore(checkpointFile, true, mkImportSubscription)
+		if err != nil {
+			log.Fatalf("could not open checkpoint file: %v", err)
+		}
+		defer checkpoint.Close()
+		p.Checkpoint = checkpoint
+	}
+
+	plan, err := p.Plan(ctx, contents)
+	if err != nil {
+		log.Fatal(err)
+	}
+	printPlan(plan)
+}
+
+// printPlan writes out the action of each resource followed by the totals
+func printPlan(plan migrate.Plan) {
+	fmt.Println("Plan:")
+	w := tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Action\tResource\tName\tReason\n")
+	for _, item := range plan.Items {
+		_, _ = fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", item.Action, item.Kind, item, item.Reason)
+	}
+	w.Flush()
+
+	fmt.Println("\nTotals:")
+	totals := plan.Totals()
+	w = tabwriter.NewWriter(os.Stdout, 1, 1, 1, ' ', 0)
+	_, _ = fmt.Fprintf(w, "Resource")
+	for _, action := range migrate.PlanActions {
+		_, _ = fmt.Fprintf(w, "\t%v", action)
+	}
+	_, _ = fmt.Fprintf(w, "\n")
+	all := map[migrate.PlanAction]int{}
+	for _, kind := range []string{migrate.CONTENTKEYPOLICIES, migrate.ASSETS, migrate.ASSETFILTERS, migrate.STREAMINGPOLICIES, migrate.STREAMINGLOCATORS, migrate.STREAMINGENDPOINTS} {
+		counts, ok := totals[kind]
+		if !ok {
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%v", kind)
+		for _, action := range migrate.PlanActions {
+			_, _ = fmt.Fprintf(w, "\t%d", counts[action])
+			all[action] += counts[action]
+		}
+		_, _ = fmt.Fprintf(w, "\n")
+	}
+	_, _ = fmt.Fprintf(w, "Total")
+	for _, action := range migrate.PlanActions {
+		_, _ = fmt.Fprintf(w, "\t%d", all[action])
+	}
+	_, _ = fmt.Fprintf(w, "\n")
+	w.Flush()
+}
+
+func init() {
+	addDestinationFlags(planCmd)
+	addResourceFlags(planCmd)
+	addWorkerFlags(planCmd)
+	planCmd.Flags().BoolVar(&overwrite, "overwrite", false, "plan as if existing resources will be overwritten")
+	planCmd.Flags().BoolVar(&resume, "resume", false, "skip resources already handled according to the checkpoint file")
+	planCmd.Flags().StringVar(&checkpointFile, "checkpoint-file", "", "Import checkpoint filename (default: <migration-file>.checkpoint)")
+	planCmd.Flags().StringVar(&retryFailuresFile, "retry-failures", "", "Plan only the resources listed in this failure manifest")
+
+	rootCmd.AddCommand(planCmd)
+}
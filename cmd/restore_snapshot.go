@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+// restoreSnapshotCmd puts back the resources an import replaced with --overwrite
+var restoreSnapshotCmd = &cobra.Command{
+	Use:   "restore-snapshot",
+	Short: "Put back the mk.io resources an import replaced with --overwrite",
+	Long: `Put back the mk.io resources an import replaced with --overwrite.
+
+Before overwriting a resource, import saves a copy of it in the snapshot file. This command recreates
+those originals. If a resource was overwritten more than once, the oldest copy is restored. Encrypted
+secrets are decrypted with MIGRATION_PASSPHRASE or --identity.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		if snapshotFile == "" {
+			if migrationFile == "" {
+				log.Fatal("restore-snapshot needs --snapshot-file or the --migration-file of the import")
+			}
+			snapshotFile = migrationFile + ".snapshots"
+		}
+		snapshots, err := migrate.ReadSnapshots(snapshotFile, secretKeys())
+		if err != nil {
+			log.Fatalf("could not read snapshots: %v", err)
+		}
+		if len(snapshots) == 0 {
+			log.Info("Nothing to restore")
+			return
+		}
+
+		for _, entry := range snapshots {
+			fmt.Printf("restore\t%v\t%v\t%v\n", entry.Kind, entry, entry.Time)
+		}
+		if dryRun {
+			fmt.Printf("\n%d resources would be restored in %v\n", len(snapshots), mkImportSubscription)
+			return
+		}
+		if !assumeYes {
+			var answer string
+			fmt.Printf("\nReplace %d resources in mk.io subscription %v with their snapshots [y/N]\n", len(snapshots), mkImportSubscription)
+			fmt.Scan(&answer)
+			if answer != "y" && answer != "Y" {
+				log.Info("Restore cancelled")
+				return
+			}
+		}
+
+		p := newPipeline()
+		destination, err := newDestination(ctx)
+		if err != nil {
+			log.Fatalf("restore Error: %v", err)
+		}
+		p.Destination = destination
+
+		timings, err := p.RestoreSnapshots(ctx, snapshots)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printResults(timings)
+	},
+}
+
+func init() {
+	addDestinationFlags(restoreSnapshotCmd)
+	addWorkerFlags(restoreSnapshotCmd)
+	restoreSnapshotCmd.Flags().StringVar(&snapshotFile, "snapshot-file", "", "Snapshot file written by import (default: <migration-file>.snapshots)")
+	restoreSnapshotCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only list the resources that would be restored")
+	restoreSnapshotCmd.Flags().BoolVar(&assumeYes, "yes", false, "do not ask for confirmation before restoring")
+
+	rootCmd.AddCommand(restoreSnapshotCmd)
+}
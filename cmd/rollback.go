@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+var assumeYes bool
+
+// rollbackCmd deletes the resources a previous import created
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Delete the resources a previous import created in mk.io",
+	Long: `Delete the resources a previous import created in mk.io.
+
+The checkpoint file of the import is used as the journal of the run. Only resources the import created are deleted.
+Resources that already existed, including the ones overwritten with --overwrite, are left alone.
+Resources are deleted in the order StreamingLocators, AssetFilters, Assets, StreamingPolicies, ContentKeyPolicies, StreamingEndpoints.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		if checkpointFile == "" {
+			if migrationFile == "" {
+				log.Fatal("rollback needs --checkpoint-file or the --migration-file of the import")
+			}
+			checkpointFile = migrationFile + ".checkpoint"
+		}
+		entries, err := migrate.ReadJournal(checkpointFile)
+		if err != nil {
+			log.Fatalf("could not read journal: %v", err)
+		}
+		// Never delete from a subscription the import didn't write to
+		if err := migrate.CheckJournalSubscription(entries, mkImportSubscription); err != nil {
+			log.Fatalf("could not roll back %v: %v", checkpointFile, err)
+		}
+
+		created := migrate.RollbackEntries(entries)
+		total := 0
+		for _, kind := range migrate.RollbackOrder {
+			for _, entry := range created[kind] {
+				fmt.Printf("delete\t%v\t%v\n", kind, entry)
+				total++
+			}
+		}
+		if total == 0 {
+			log.Info("Nothing to roll back")
+			return
+		}
+		if dryRun {
+			fmt.Printf("\n%d resources would be deleted from %v\n", total, mkImportSubscription)
+			return
+		}
+		if !assumeYes {
+			var answer string
+			fmt.Printf("\nDelete %d resources from mk.io subscription %v [y/N]\n", total, mkImportSubscription)
+			fmt.Scan(&answer)
+			if answer != "y" && answer != "Y" {
+				log.Info("Rollback cancelled")
+				return
+			}
+		}
+
+		p := newPipeline()
+		destination, err := newDestination(ctx)
+		if err != nil {
+			log.Fatalf("rollback Error: %v", err)
+		}
+		p.Destination = destination
+
+		timings, err := p.Rollback(ctx, entries)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printResults(timings)
+	},
+}
+
+func init() {
+	addDestinationFlags(rollbackCmd)
+	addWorkerFlags(rollbackCmd)
+	rollbackCmd.Flags().StringVar(&checkpointFile, "checkpoint-file", "", "Checkpoint file of the import to roll back (default: <migration-file>.checkpoint)")
+	rollbackCmd.Flags().BoolVar(&dryRun, "dry-run", false, "only list the resources that would be deleted")
+	rollbackCmd.Flags().BoolVar(&assumeYes, "yes", false, "do not ask for confirmation before deleting")
+
+	rootCmd.AddCommand(rollbackCmd)
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+// Split options
+var (
+	assetsPerFile  int
+	splitDates     []string
+	splitPrefixes  []string
+	splitOutputDir string
+)
+
+// splitCmd divides a migration file into waves
+var splitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "Split a migration file into smaller files, to import in waves",
+	Long: `Split a migration file into smaller files, to import in waves.
+
+Assets are split by count (--assets-per-file), by created date (--by-date, each date starts a new
+file) or by name prefix (--by-prefix, assets matching no prefix go to an "other" file). Each asset
+stays in the same file as its Asset Filters and Streaming Locators. Content Key Policies, Streaming
+Policies and Streaming Endpoints go in the first file, which must be imported first.
+
+The files are written as <migration-file>-<part>.json, or .jsonl for the ndjson format, next to the
+migration file or in --output-dir. Secrets are copied as they are in the file.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		if migrationFile == "" {
+			log.Fatal("split needs the --migration-file to split")
+		}
+		opts := migrate.SplitOptions{AssetsPerFile: assetsPerFile, Prefixes: splitPrefixes}
+		for _, v := range splitDates {
+			t, err := parseDate(v)
+			if err != nil {
+				log.Fatalf("--by-date %q is not a date (2006-01-02) or RFC3339 time", v)
+			}
+			opts.DateBoundaries = append(opts.DateBoundaries, t)
+		}
+
+		if !cmd.Flag("format").Changed {
+			format, err := migrate.ReadMigrationFileFormat(migrationFile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			migrationFormat = format
+		}
+
+		contents := readUpgradedMigrationFile(ctx, migrationFile, nil)
+		shards, err := contents.Split(opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		dir := splitOutputDir
+		if dir == "" {
+			dir = filepath.Dir(migrationFile)
+		}
+		base := strings.TrimSuffix(filepath.Base(migrationFile), filepath.Ext(migrationFile))
+		for _, shard := range shards {
+			fileName := filepath.Join(dir, fmt.Sprintf("%v-%v%v", base, shard.Name, formatExt(migrationFormat)))
+			err := shard.Contents.WriteMigrationFile(ctx, fileName, migrationFormat)
+			if err != nil {
+				log.Fatalf("unable to write migration file: %v", err)
+			}
+			counts := shard.Contents.Counts()
+			log.Infof("Wrote %v: %d assets, %d asset filters, %d streaming locators", fileName, counts[migrate.ASSETS], counts[migrate.ASSETFILTERS], counts[migrate.STREAMINGLOCATORS])
+		}
+	},
+}
+
+func init() {
+	addFormatFlag(splitCmd)
+	splitCmd.Flags().IntVar(&assetsPerFile, "assets-per-file", 0, "number of assets in each file")
+	splitCmd.Flags().StringSliceVar(&splitDates, "by-date", nil, "created dates the files are split at, e.g. 2023-01-01,2023-07-01")
+	splitCmd.Flags().StringSliceVar(&splitPrefixes, "by-prefix", nil, "asset name prefixes to split by, e.g. news-,sport-")
+	splitCmd.Flags().StringVar(&splitOutputDir, "output-dir", "", "directory to write the files to (default: the directory of --migration-file)")
+	splitCmd.MarkFlagsMutuallyExclusive("assets-per-file", "by-date", "by-prefix")
+
+	rootCmd.AddCommand(splitCmd)
+}
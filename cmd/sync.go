@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+// Sync options
+var (
+	syncInterval time.Duration
+	healthListen string
+)
+
+// syncCmd keeps mk.io in sync with the source until it is stopped
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Keep mk.io in sync with AMS or another mk.io subscription during a cut-over",
+	Long: `Keep mk.io in sync with AMS or another mk.io subscription during a cut-over.
+
+sync runs until it gets SIGTERM or SIGINT. Every --interval it migrates the resources created or modified
+since the previous cycle, like migrate --incremental, and updates the ones that already exist in mk.io
+unless they are the same there. Before a resource is replaced, a copy is saved to --snapshot-file so it
+can be put back with restore-snapshot. A cycle in progress is stopped on exit, and what it didn't finish
+is picked up by the next run.
+
+Streaming endpoints with a CDN provider mk.io doesn't support are handled by --unsupported-cdn, sync
+never asks.
+
+/healthz and /readyz are served on --listen. /readyz succeeds once a cycle has completed without errors
+and fails while the last cycle failed. Both return the state of the sync as JSON.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		if unsupportedCdn == string(migrate.CdnPrompt) {
+			log.Fatalf("sync can't ask about unsupported CDN providers, set --unsupported-cdn to one of %v, %v or %v", migrate.CdnAkamai, migrate.CdnSkip, migrate.CdnFail)
+		}
+		p := newPipeline()
+		p.UnsupportedCdn = cdnPolicy(migrate.CdnFail)
+		p.ConfirmCdn = nil
+		destination, err := newDestination(ctx)
+		if err != nil {
+			log.Fatalf("import Error: %v", err)
+		}
+		p.Destination = destination
+
+		source, err := newSource(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p.Source = source
+
+		syncer, err := migrate.NewSyncer(p, exportSource(), watermarkFile, syncInterval)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Keep a copy of everything we overwrite so it can be put back with restore-snapshot
+		if snapshotFile == "" {
+			snapshotFile = watermarkFile + ".snapshots"
+		}
+		snapshots, err := migrate.OpenSnapshotStore(snapshotFile, newSnapshotSealer())
+		if err != nil {
+			log.Fatalf("could not open snapshot file: %v", err)
+		}
+		p.Snapshots = snapshots
+		defer p.Snapshots.Close()
+
+		var server *http.Server
+		if healthListen != "" {
+			server = &http.Server{Addr: healthListen, Handler: syncer.Handler(), ReadHeaderTimeout: 10 * time.Second}
+			go func() {
+				log.Infof("Serving /healthz and /readyz on %v", healthListen)
+				if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Fatalf("unable to serve health endpoints: %v", err)
+				}
+			}()
+		}
+
+		syncer.Run(ctx)
+
+		if server != nil {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				log.Errorf("unable to stop health endpoints: %v", err)
+			}
+		}
+	},
+}
+
+func init() {
+	addSourceFlags(syncCmd)
+	addDestinationFlags(syncCmd)
+	addResourceFlags(syncCmd)
+	addWorkerFlags(syncCmd)
+	addEncryptFlags(syncCmd)
+	addUnsupportedCdnFlag(syncCmd)
+	syncCmd.Flags().BoolVar(&fairplayAmsCompatibility, "fairplay-ams-compatibility", false, "set fairPlayAmsCompatibility=true for all fairplay content key policies")
+	syncCmd.Flags().StringVar(&snapshotFile, "snapshot-file", "", "File keeping a copy of every resource sync replaces (default: <watermark-file>.snapshots)")
+	syncCmd.Flags().DurationVar(&syncInterval, "interval", 5*time.Minute, "time between the end of a sync cycle and the start of the next")
+	syncCmd.Flags().StringVar(&watermarkFile, "watermark-file", "watermarks.json", "file keeping the time of the newest resource synced, per source account and resource type")
+	syncCmd.Flags().StringVar(&healthListen, "listen", ":8080", "address to serve the health endpoints on, empty to not serve them")
+
+	rootCmd.AddCommand(syncCmd)
+}
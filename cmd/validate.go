@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	migrate "dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/migration"
+)
+
+// validateCmd validates imported resources in mk.io
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate that the StreamingLocators of a migration file work in mk.io",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		p := newPipeline()
+		destination, err := newDestination(ctx)
+		if err != nil {
+			log.Fatalf("validation Error: %v", err)
+		}
+		p.Destination = destination
+
+		runValidate(ctx, p, nil)
+	},
+}
+
+// runValidate validates the StreamingLocators of the migration file against the pipeline destination
+func runValidate(ctx context.Context, p *migrate.Pipeline, exported *migrate.MigrationFileContents) {
+	// Read migration file & populate migration contents from it
+	var contents migrate.MigrationFileContents
+	if exported != nil {
+		contents = *exported
+	} else {
+		contents = readMigrationFile(ctx)
+	}
+
+	err := p.Validate(ctx, contents)
+	if err != nil {
+		log.Errorf("error validating streamingLocators: %v", err)
+	}
+}
+
+func init() {
+	addDestinationFlags(validateCmd)
+
+	rootCmd.AddCommand(validateCmd)
+}
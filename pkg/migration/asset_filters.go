@@ -3,7 +3,6 @@ package migrate
 import (
 	"context"
 	"fmt"
-	"strings"
 	"sync"
 
 	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
@@ -88,107 +87,47 @@ func ExportMkAssetFilters(ctx context.Context, client *mkiosdk.AssetFiltersClien
 	return allAssetFilters, nil
 }
 
-func ImportAssetFilterWorker(ctx context.Context, client *mkiosdk.AssetFiltersClient, overwrite bool, wg *sync.WaitGroup, jobs chan map[string][]*armmediaservices.AssetFilter, successChan chan string, skippedChan chan string, failedChan chan string) {
-
-	for job := range jobs {
-		for assetName, filters := range job {
-			log.Debugf("Importing AssetFilters for Asset: %v\n", assetName)
-			for _, assetFilter := range filters {
-				found := true
-				// Check if assetFilter already exists. Skip update unless overwrite is set
-				_, err := client.Get(ctx, assetName, *assetFilter.Name, nil)
-				if err != nil {
-					if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "Not Found") {
-						found = false
-					}
-				}
-				if found && !overwrite {
-					// Found something and we're not overwriting. We should skip it
-					log.Debugf("Skipping existing AssetFilter %v\n", *assetFilter.Name)
-					skippedChan <- fmt.Sprintf("%v/%v", assetName, *assetFilter.Name)
-				} else {
-					_, err = client.CreateOrUpdate(ctx, assetName, *assetFilter.Name, assetFilter, nil)
-					if err != nil {
-						log.Errorf("unable to import asset filter %v: %v\n", *assetFilter.Name, err)
-						failedChan <- fmt.Sprintf("%v/%v", assetName, *assetFilter.Name)
-					} else {
-						successChan <- *assetFilter.Name
-					}
-				}
-				wg.Done()
-			}
-		}
-	}
-}
-
-// ImportAssetFilters reads a file containing AssetFilters in JSON format. Insert each asset filter into MKIO
-func ImportAssetFilters(ctx context.Context, client *mkiosdk.AssetFiltersClient, assetFilters map[string][]*armmediaservices.AssetFilter, overwrite bool, workers int) (int, int, []string, error) {
-
-	log.Info("Importing AssetFilters")
-
-	// Waitgroup to wait for all goroutines to finish
-	wg := new(sync.WaitGroup)
-
-	// Get total number of filters
-	totalFilters := 0
-	for _, v := range assetFilters {
-		totalFilters += len(v)
+// importAssetFilter imports a single asset filter into MKIO and records the outcome in the checkpoint store.
+// Returns true if the asset filter already existed and was skipped.
+func importAssetFilter(ctx context.Context, client *mkiosdk.AssetFiltersClient, assetName string, assetFilter *armmediaservices.AssetFilter, overwrite bool, skipUnchanged bool, checkpoint *CheckpointStore, snapshots *SnapshotStore) (bool, error) {
+	// Check if assetFilter already exists. Skip update unless overwrite is set
+	existing, err := client.Get(ctx, assetName, *assetFilter.Name, nil)
+	found := err == nil
+	if err != nil && !mkiosdk.IsNotFound(err) {
+		log.Errorf("unable to look up asset filter %v: %v", *assetFilter.Name, err)
+		checkpoint.Record(ASSETFILTERS, assetName, *assetFilter.Name, StatusFailed, "", err)
+		return false, err
 	}
-
-	// Create channels to communicate between workers
-	successChan := make(chan string, (totalFilters))
-	skippedChan := make(chan string, (totalFilters))
-	failedChan := make(chan string, (totalFilters))
-	jobs := make(chan map[string][]*armmediaservices.AssetFilter, (totalFilters))
-
-	// Setup worker pool. This will start X workers to handle jobs
-	for w := 1; w <= workers; w++ {
-		log.Infof("Starting AssetFilter worker %d", w)
-		go ImportAssetFilterWorker(ctx, client, overwrite, wg, jobs, successChan, skippedChan, failedChan)
+	if found && !overwrite {
+		// Found something and we're not overwriting. We should skip it
+		log.Debugf("Skipping existing AssetFilter %v\n", *assetFilter.Name)
+		checkpoint.Record(ASSETFILTERS, assetName, *assetFilter.Name, StatusSkipped, ImportExisting, nil)
+		return true, nil
 	}
-
-	failedAssetFilters := []string{}
-	skipped := 0
-	successCount := 0
-
-	// Create each asset filter
-	for assetName, assetFilterList := range assetFilters {
-		wg.Add(len(assetFilterList))
-		jobs <- map[string][]*armmediaservices.AssetFilter{assetName: assetFilterList}
+	if found && skipUnchanged && sameResource(&existing.AssetFilter, assetFilter) {
+		log.Debugf("Asset Filter is the same in MKIO, skipping: %v", *assetFilter.Name)
+		checkpoint.Record(ASSETFILTERS, assetName, *assetFilter.Name, StatusSkipped, ImportExisting, nil)
+		return true, nil
 	}
 
-	log.Info("Waiting for AssetFilter workers to finish")
-	wg.Wait()
-	log.Info("Done importing Asset Filters")
-
-	close(jobs)
-	close(successChan)
-	close(skippedChan)
-	close(failedChan)
-	for f := range successChan {
-		if f != "" {
-			successCount++
-		}
-	}
-	for result := range skippedChan {
-		if result != "" {
-			skipped++
-		}
-	}
-	for result := range failedChan {
-		if result != "" {
-			failedAssetFilters = append(failedAssetFilters, result)
+	// Keep a copy of the filter we're about to replace
+	if found {
+		err = snapshots.saveExisting(ASSETFILTERS, assetName, *assetFilter.Name, existing.AssetFilter, err)
+		if err != nil {
+			log.Errorf("not overwriting asset filter %v: %v\n", *assetFilter.Name, err)
+			checkpoint.Record(ASSETFILTERS, assetName, *assetFilter.Name, StatusFailed, "", err)
+			return false, err
 		}
 	}
 
-	log.Infof("Skipped %d existing Asset Filters", skipped)
-	log.Infof("Imported %d Asset Filters", successCount)
-
-	if len(failedAssetFilters) > 0 {
-		return successCount, skipped, failedAssetFilters, fmt.Errorf("failed to import %d Asset Filters: %v", len(failedAssetFilters), failedAssetFilters)
+	_, err = client.CreateOrUpdate(ctx, assetName, *assetFilter.Name, assetFilter, nil)
+	if err != nil {
+		log.Errorf("unable to import asset filter %v: %v\n", *assetFilter.Name, err)
+		checkpoint.Record(ASSETFILTERS, assetName, *assetFilter.Name, StatusFailed, "", err)
+		return false, err
 	}
-
-	return successCount, skipped, failedAssetFilters, nil
+	checkpoint.Record(ASSETFILTERS, assetName, *assetFilter.Name, StatusImported, importAction(found), nil)
+	return false, nil
 }
 
 // ValidateAssetFilters
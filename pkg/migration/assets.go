@@ -3,7 +3,6 @@ package migrate
 import (
 	"context"
 	"fmt"
-	"sync"
 
 	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
@@ -36,99 +35,52 @@ func ExportMkAssets(ctx context.Context, client *mkiosdk.AssetsClient, before st
 	return assets, nil
 }
 
-// ImportAssetsWorker - Do the work to import an asset into MKIO
-func ImportAssetsWorker(ctx context.Context, client *mkiosdk.AssetsClient, overwrite bool, wg *sync.WaitGroup, jobs chan *armmediaservices.Asset, successChan chan string, skippedChan chan string, failedChan chan string) {
-
-	for asset := range jobs {
-		log.Debugf("Importing Asset in MKIO: %v", *asset.Name)
-
-		found := true
-		// Check if asset already exists. Skip update unless overwrite is set
-		_, err := client.Get(ctx, *asset.Name, nil)
-		if err != nil {
-			found = false
-		}
-		if found && !overwrite {
-			// Found something and we're not overwriting. We should skip it
-			log.Debugf("Asset already exists in MKIO, skipping: %v", *asset.Name)
-			skippedChan <- *asset.Name
-		} else {
-
-			log.Debugf("Creating Asset in MKIO: %v", *asset.Name)
-
-			_, err = client.CreateOrUpdate(ctx, *asset.Name, asset, nil)
-			if err != nil {
-				log.Errorf("unable to import asset %v: %v", *asset.Name, err)
-				failedChan <- *asset.Name
-			} else {
-				successChan <- *asset.Name
-			}
-		}
-		wg.Done()
+// importAsset imports a single asset into MKIO and records the outcome in the checkpoint store.
+// Returns true if the asset already existed and was skipped.
+func importAsset(ctx context.Context, client *mkiosdk.AssetsClient, asset *armmediaservices.Asset, overwrite bool, skipUnchanged bool, checkpoint *CheckpointStore, snapshots *SnapshotStore) (bool, error) {
+	log.Debugf("Importing Asset in MKIO: %v", *asset.Name)
+
+	// Check if asset already exists. Skip update unless overwrite is set
+	existing, err := client.Get(ctx, *asset.Name, nil)
+	found := err == nil
+	if err != nil && !mkiosdk.IsNotFound(err) {
+		// Without knowing whether it exists, creating it could overwrite it
+		log.Errorf("unable to look up asset %v: %v", *asset.Name, err)
+		checkpoint.Record(ASSETS, "", *asset.Name, StatusFailed, "", err)
+		return false, err
 	}
-}
-
-// ImportAssets reads a file containing Assets in JSON format. Insert each asset into MKIO
-func ImportAssets(ctx context.Context, client *mkiosdk.AssetsClient, assets []*armmediaservices.Asset, overwrite bool, workers int) (int, int, []string, error) {
-	log.Info("Importing Assets")
-
-	// Waitgroup to wait for all goroutines to finish
-	wg := new(sync.WaitGroup)
-
-	// Create channels to communicate between workers
-	successChan := make(chan string, len(assets))
-	skippedChan := make(chan string, len(assets))
-	failedChan := make(chan string, len(assets))
-	jobs := make(chan *armmediaservices.Asset, len(assets))
-
-	// Setup worker pool. This will start X workers to handle jobs
-	for w := 1; w <= workers; w++ {
-		log.Infof("Starting Asset worker %d", w)
-		go ImportAssetsWorker(ctx, client, overwrite, wg, jobs, successChan, skippedChan, failedChan)
+	if found && !overwrite {
+		// Found something and we're not overwriting. We should skip it
+		log.Debugf("Asset already exists in MKIO, skipping: %v", *asset.Name)
+		checkpoint.Record(ASSETS, "", *asset.Name, StatusSkipped, ImportExisting, nil)
+		return true, nil
 	}
-
-	failedAssets := []string{}
-	skipped := 0
-	successCount := 0
-
-	// Create each asset
-	for _, asset := range assets {
-		wg.Add(1)
-		jobs <- asset
+	if found && skipUnchanged && sameResource(&existing.Asset, asset) {
+		log.Debugf("Asset is the same in MKIO, skipping: %v", *asset.Name)
+		checkpoint.Record(ASSETS, "", *asset.Name, StatusSkipped, ImportExisting, nil)
+		return true, nil
 	}
 
-	log.Info("Waiting for Assets workers to finish")
-	wg.Wait()
-	log.Info("Done importing Assets")
+	log.Debugf("Creating Asset in MKIO: %v", *asset.Name)
 
-	close(jobs)
-	close(successChan)
-	close(skippedChan)
-	close(failedChan)
-	for f := range successChan {
-		if f != "" {
-			successCount++
-		}
-	}
-	for result := range skippedChan {
-		if result != "" {
-			skipped++
-		}
-	}
-	for result := range failedChan {
-		if result != "" {
-			failedAssets = append(failedAssets, result)
+	// Keep a copy of the asset we're about to replace
+	if found {
+		err = snapshots.saveExisting(ASSETS, "", *asset.Name, existing.Asset, nil)
+		if err != nil {
+			log.Errorf("not overwriting asset %v: %v", *asset.Name, err)
+			checkpoint.Record(ASSETS, "", *asset.Name, StatusFailed, "", err)
+			return false, err
 		}
 	}
 
-	log.Infof("Skipped %d existing assets", skipped)
-	log.Infof("Imported %d assets", successCount)
-
-	if len(failedAssets) > 0 {
-		return successCount, skipped, failedAssets, fmt.Errorf("failed to import %d assets: %v", len(failedAssets), failedAssets)
+	_, err = client.CreateOrUpdate(ctx, *asset.Name, asset, nil)
+	if err != nil {
+		log.Errorf("unable to import asset %v: %v", *asset.Name, err)
+		checkpoint.Record(ASSETS, "", *asset.Name, StatusFailed, "", err)
+		return false, err
 	}
-
-	return successCount, skipped, failedAssets, nil
+	checkpoint.Record(ASSETS, "", *asset.Name, StatusImported, importAction(found), nil)
+	return false, nil
 }
 
 // ValidateAssets
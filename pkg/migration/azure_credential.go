@@ -0,0 +1,141 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// Azure credential types
+const (
+	// CredentialDefault tries the environment, workload identity, managed identity and the Azure CLI in turn
+	CredentialDefault          = "default"
+	CredentialClientSecret     = "client-secret"
+	CredentialCertificate      = "certificate"
+	CredentialWorkloadIdentity = "workload-identity"
+	CredentialManagedIdentity  = "managed-identity"
+	CredentialAzureCLI         = "azure-cli"
+	CredentialDeviceCode       = "device-code"
+)
+
+// CredentialTypes are the supported Azure credential types
+var CredentialTypes = []string{CredentialDefault, CredentialClientSecret, CredentialCertificate, CredentialWorkloadIdentity, CredentialManagedIdentity, CredentialAzureCLI, CredentialDeviceCode}
+
+// Azure clouds
+const (
+	CloudPublic     = "public"
+	CloudChina      = "china"
+	CloudGovernment = "government"
+)
+
+// AzureCredentialConfig selects how to log into Azure. The zero value uses the default credential in the public cloud
+type AzureCredentialConfig struct {
+	// Type is one of CredentialTypes. Empty is CredentialDefault
+	Type string
+	// TenantID is the Azure AD tenant to log into. Not used by managed identities
+	TenantID string
+	// ClientID is the application of a client secret, certificate, workload identity or device code, or the
+	// user-assigned managed identity
+	ClientID string
+	// ClientSecret of a client-secret credential
+	ClientSecret string
+	// CertificatePath is a PEM or PKCS12 file with the certificate and private key of a certificate credential,
+	// and CertificatePassword the password of its key, if any
+	CertificatePath     string
+	CertificatePassword string
+	// Cloud is CloudPublic, CloudChina or CloudGovernment. Empty is CloudPublic
+	Cloud string
+}
+
+// Validate checks that the options needed by the credential type are set
+func (c AzureCredentialConfig) Validate() error {
+	if _, err := c.cloud(); err != nil {
+		return err
+	}
+	switch c.Type {
+	case "", CredentialDefault, CredentialWorkloadIdentity, CredentialManagedIdentity, CredentialAzureCLI, CredentialDeviceCode:
+		return nil
+	case CredentialClientSecret:
+		if c.TenantID == "" || c.ClientID == "" || c.ClientSecret == "" {
+			return fmt.Errorf("the %v Azure credential needs a tenant ID, client ID and client secret", c.Type)
+		}
+	case CredentialCertificate:
+		if c.TenantID == "" || c.ClientID == "" || c.CertificatePath == "" {
+			return fmt.Errorf("the %v Azure credential needs a tenant ID, client ID and certificate", c.Type)
+		}
+	default:
+		return fmt.Errorf("unknown Azure credential %q. Use one of %v", c.Type, strings.Join(CredentialTypes, ", "))
+	}
+	return nil
+}
+
+// cloud returns the endpoints of the Azure cloud
+func (c AzureCredentialConfig) cloud() (cloud.Configuration, error) {
+	switch strings.ToLower(c.Cloud) {
+	case "", CloudPublic:
+		return cloud.AzurePublic, nil
+	case CloudChina:
+		return cloud.AzureChina, nil
+	case CloudGovernment:
+		return cloud.AzureGovernment, nil
+	}
+	return cloud.Configuration{}, fmt.Errorf("unknown Azure cloud %q. Use %v, %v or %v", c.Cloud, CloudPublic, CloudChina, CloudGovernment)
+}
+
+// clientOptions are the options of the Azure clients, and of the credential
+func (c AzureCredentialConfig) clientOptions() (*arm.ClientOptions, error) {
+	cfg, err := c.cloud()
+	if err != nil {
+		return nil, err
+	}
+	return &arm.ClientOptions{ClientOptions: azcore.ClientOptions{Cloud: cfg}}, nil
+}
+
+// newCredential creates the credential of the type selected
+func (c AzureCredentialConfig) newCredential() (azcore.TokenCredential, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	options, err := c.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Type {
+	case CredentialClientSecret:
+		return azidentity.NewClientSecretCredential(c.TenantID, c.ClientID, c.ClientSecret, &azidentity.ClientSecretCredentialOptions{ClientOptions: options.ClientOptions})
+	case CredentialCertificate:
+		data, err := os.ReadFile(c.CertificatePath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read certificate: %v", err)
+		}
+		var password []byte
+		if c.CertificatePassword != "" {
+			password = []byte(c.CertificatePassword)
+		}
+		certs, key, err := azidentity.ParseCertificates(data, password)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse certificate %v: %v", c.CertificatePath, err)
+		}
+		return azidentity.NewClientCertificateCredential(c.TenantID, c.ClientID, certs, key, &azidentity.ClientCertificateCredentialOptions{ClientOptions: options.ClientOptions})
+	case CredentialWorkloadIdentity:
+		// Anything not set is read from the environment set up by the workload identity webhook
+		return azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{ClientOptions: options.ClientOptions, TenantID: c.TenantID, ClientID: c.ClientID})
+	case CredentialManagedIdentity:
+		miOptions := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: options.ClientOptions}
+		if c.ClientID != "" {
+			miOptions.ID = azidentity.ClientID(c.ClientID)
+		}
+		return azidentity.NewManagedIdentityCredential(miOptions)
+	case CredentialAzureCLI:
+		return azidentity.NewAzureCLICredential(&azidentity.AzureCLICredentialOptions{TenantID: c.TenantID})
+	case CredentialDeviceCode:
+		return azidentity.NewDeviceCodeCredential(&azidentity.DeviceCodeCredentialOptions{ClientOptions: options.ClientOptions, TenantID: c.TenantID, ClientID: c.ClientID})
+	}
+	return azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: options.ClientOptions, TenantID: c.TenantID})
+}
@@ -6,8 +6,8 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
 	log "github.com/sirupsen/logrus"
@@ -15,7 +15,7 @@ import (
 
 type AzureServiceProvider struct {
 	// config               AzureServiceProviderConfig
-	credential               *azidentity.DefaultAzureCredential
+	credential               azcore.TokenCredential
 	subscriptionId           string
 	resourceGroup            string
 	accountName              string
@@ -30,44 +30,49 @@ type AzureServiceProvider struct {
 	accountsClient *armstorage.AccountsClient
 }
 
-func NewAzureServiceProvider(subscription string, resourceGroup string, accountName string) (*AzureServiceProvider, error) {
+// NewAzureServiceProvider logs into an AMS account with the credential and in the cloud selected by credentials
+func NewAzureServiceProvider(subscription string, resourceGroup string, accountName string, credentials AzureCredentialConfig) (*AzureServiceProvider, error) {
 
 	log.Info("Logging into Azure")
-	credential, err := azidentity.NewDefaultAzureCredential(nil)
+	credential, err := credentials.newCredential()
 	if err != nil {
 		return nil, fmt.Errorf("failed to obtain a credential: %v", err)
 	}
+	options, err := credentials.clientOptions()
+	if err != nil {
+		return nil, err
+	}
 	// Get a Azure MediaServices Assets Client
-	assetsClient, err := armmediaservices.NewAssetsClient(subscription, credential, nil)
+	assetsClient, err := armmediaservices.NewAssetsClient(subscription, credential, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Azure Media Service Client: %v", err)
 	}
 	// Get a Azure MediaServices Asset filters Client
-	assetFiltersClient, err := armmediaservices.NewAssetFiltersClient(subscription, credential, nil)
+	assetFiltersClient, err := armmediaservices.NewAssetFiltersClient(subscription, credential, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Azure Media Service Client: %v", err)
 	}
 	// Get a Azure MediaServices StreamingLocator Client
-	streamingLocatorsClient, err := armmediaservices.NewStreamingLocatorsClient(subscription, credential, nil)
+	streamingLocatorsClient, err := armmediaservices.NewStreamingLocatorsClient(subscription, credential, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Azure Media Service Client: %v", err)
 	}
 	// Get a Azure MediaServices StreamingEndpoints Client
-	streamingEndpointsClient, err := armmediaservices.NewStreamingEndpointsClient(subscription, credential, nil)
+	streamingEndpointsClient, err := armmediaservices.NewStreamingEndpointsClient(subscription, credential, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Azure Media Service Client: %v", err)
 	}
 	// Get a Azure MediaServices StreamingEndpoints Client
-	contentKeyPoliciesClient, err := armmediaservices.NewContentKeyPoliciesClient(subscription, credential, nil)
+	contentKeyPoliciesClient, err := armmediaservices.NewContentKeyPoliciesClient(subscription, credential, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Azure Media Service Client: %v", err)
 	}
 	// Get a Azure MediaServices StreamingPolicies Client
-	streamingPoliciesClient, err := armmediaservices.NewStreamingPoliciesClient(subscription, credential, nil)
+	streamingPoliciesClient, err := armmediaservices.NewStreamingPoliciesClient(subscription, credential, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Azure Media Service Client: %v", err)
 	}
-	accountsClient, err := armstorage.NewAccountsClient(subscription, credential, nil)
+	accountsClient, err := armstorage.NewAccountsClient(subscription, credential, options)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create accounts client: %v", err)
 	}
@@ -106,6 +111,16 @@ func generateFilter(before string, after string) string {
 
 // lookupAssets  Get assets from Azure MediaServices. Remove pagination
 func (a *AzureServiceProvider) lookupAssets(ctx context.Context, before string, after string) ([]*armmediaservices.Asset, error) {
+	assets := []*armmediaservices.Asset{}
+	err := a.listAssets(ctx, before, after, func(page []*armmediaservices.Asset) error {
+		assets = append(assets, page...)
+		return nil
+	})
+	return assets, err
+}
+
+// listAssets Get assets from Azure MediaServices, passing each page to the page func as it is read
+func (a *AzureServiceProvider) listAssets(ctx context.Context, before string, after string, page func([]*armmediaservices.Asset) error) error {
 	client := a.assetsClient
 
 	// Generate the filter
@@ -119,19 +134,20 @@ func (a *AzureServiceProvider) lookupAssets(ctx context.Context, before string,
 
 	pager := client.NewListPager(a.resourceGroup, a.accountName, options)
 
-	assets := []*armmediaservices.Asset{}
-	// We get pages back. Loop through pages and create a list of assets
+	// We get pages back. Hand each one over before reading the next
 	for pager.More() {
 		nextResult, err := pager.NextPage(ctx)
 		if err != nil {
-			return assets, fmt.Errorf("failed to advance page: %v", err)
+			return fmt.Errorf("failed to advance page: %v", err)
 		}
 		for _, v := range nextResult.Value {
 			log.Debugf("Id: %s, Name: %s, Type: %s, Container: %s, StorageAccountName: %s, AssetId: %s\n", *v.ID, *v.Name, *v.Type, *v.Properties.Container, *v.Properties.StorageAccountName, *v.Properties.AssetID)
-			assets = append(assets, v)
+		}
+		if err := page(nextResult.Value); err != nil {
+			return err
 		}
 	}
-	return assets, nil
+	return nil
 }
 
 func (a *AzureServiceProvider) lookupAssetFiltersWorker(ctx context.Context, wg *sync.WaitGroup, jobs chan string, filterChan chan<- map[string][]*armmediaservices.AssetFilter, errorChan chan<- string) {
@@ -192,8 +208,17 @@ func (a *AzureServiceProvider) lookupContentKeysWorker(ctx context.Context, wg *
 
 // lookupStreamingLocators Get StreamingLocators from Azure MediaServices. Remove pagination
 func (a *AzureServiceProvider) lookupStreamingLocators(ctx context.Context, before string, after string) ([]*armmediaservices.StreamingLocator, error) {
-	client := a.streamingLocatorsClient
 	sl := []*armmediaservices.StreamingLocator{}
+	err := a.listStreamingLocators(ctx, before, after, func(page []*armmediaservices.StreamingLocator) error {
+		sl = append(sl, page...)
+		return nil
+	})
+	return sl, err
+}
+
+// listStreamingLocators Get StreamingLocators from Azure MediaServices, passing each page to the page func as it is read
+func (a *AzureServiceProvider) listStreamingLocators(ctx context.Context, before string, after string, page func([]*armmediaservices.StreamingLocator) error) error {
+	client := a.streamingLocatorsClient
 
 	// Generate the filter
 	filter := generateFilter(before, after)
@@ -206,18 +231,20 @@ func (a *AzureServiceProvider) lookupStreamingLocators(ctx context.Context, befo
 
 	pager := client.NewListPager(a.resourceGroup, a.accountName, options)
 
-	// Paginated result. We just need a list. loop through and generate that list
+	// Paginated result. Hand each page over before reading the next
 	for pager.More() {
 		nextResult, err := pager.NextPage(ctx)
 		if err != nil {
-			return sl, fmt.Errorf("failed to advance page: %v", err)
+			return fmt.Errorf("failed to advance page: %v", err)
 		}
 		for _, v := range nextResult.Value {
 			log.Debugf("Id: %s, Name: %s, Type: %s, AssetName: %s, StreamingLocatorID: %s, StreamingPolicyName: %s\n", *v.ID, *v.Name, *v.Type, *v.Properties.AssetName, *v.Properties.StreamingLocatorID, *v.Properties.StreamingPolicyName)
-			sl = append(sl, v)
+		}
+		if err := page(nextResult.Value); err != nil {
+			return err
 		}
 	}
-	return sl, nil
+	return nil
 }
 
 // lookupStreamingPolicies Get StreamingPolicies from Azure MediaServices. Remove pagination
@@ -324,3 +351,50 @@ func (a *AzureServiceProvider) lookupContentKeyPolicies(ctx context.Context, bef
 	}
 	return ckp, nil
 }
+
+// ExportAssets implements SourceProvider
+func (a *AzureServiceProvider) ExportAssets(ctx context.Context, before string, after string) ([]*armmediaservices.Asset, error) {
+	return ExportAzAssets(ctx, a, before, after)
+}
+
+// StreamAssets implements SourceProvider
+func (a *AzureServiceProvider) StreamAssets(ctx context.Context, before string, after string, page func([]*armmediaservices.Asset) error) error {
+	log.Info("Exporting Assets")
+	return a.listAssets(ctx, before, after, page)
+}
+
+// ExportAssetFilters implements SourceProvider
+func (a *AzureServiceProvider) ExportAssetFilters(ctx context.Context, assets []*armmediaservices.Asset, workers int) (map[string][]*armmediaservices.AssetFilter, error) {
+	return ExportAzAssetFilters(ctx, a, assets, workers)
+}
+
+// ExportStreamingPolicies implements SourceProvider
+func (a *AzureServiceProvider) ExportStreamingPolicies(ctx context.Context, before string, after string) ([]*armmediaservices.StreamingPolicy, error) {
+	return ExportAzStreamingPolicies(ctx, a, before, after)
+}
+
+// ExportStreamingLocators implements SourceProvider
+func (a *AzureServiceProvider) ExportStreamingLocators(ctx context.Context, before string, after string) ([]*armmediaservices.StreamingLocator, error) {
+	return ExportAzStreamingLocators(ctx, a, before, after)
+}
+
+// StreamStreamingLocators implements SourceProvider
+func (a *AzureServiceProvider) StreamStreamingLocators(ctx context.Context, before string, after string, page func([]*armmediaservices.StreamingLocator) error) error {
+	log.Info("Exporting Streaming Locators")
+	return a.listStreamingLocators(ctx, before, after, page)
+}
+
+// ExportContentKeys implements SourceProvider
+func (a *AzureServiceProvider) ExportContentKeys(ctx context.Context, streamingLocators []*armmediaservices.StreamingLocator, workers int) ([]*armmediaservices.StreamingLocator, error) {
+	return ExportAzContentKeys(ctx, a, streamingLocators, workers)
+}
+
+// ExportStreamingEndpoints implements SourceProvider
+func (a *AzureServiceProvider) ExportStreamingEndpoints(ctx context.Context) ([]*armmediaservices.StreamingEndpoint, error) {
+	return ExportAzStreamingEndpoints(ctx, a)
+}
+
+// ExportContentKeyPolicies implements SourceProvider
+func (a *AzureServiceProvider) ExportContentKeyPolicies(ctx context.Context, before string, after string) ([]*armmediaservices.ContentKeyPolicy, error) {
+	return ExportAzContentKeyPolicies(ctx, a, before, after)
+}
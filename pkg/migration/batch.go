@@ -0,0 +1,233 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+	log "github.com/sirupsen/logrus"
+)
+
+// BatchAccount is an AMS account of a batch migration, and the mk.io subscription it is migrated into
+type BatchAccount struct {
+	Subscription          string `yaml:"subscription" json:"subscription"`
+	ResourceGroup         string `yaml:"resourceGroup" json:"resourceGroup"`
+	AccountName           string `yaml:"accountName" json:"accountName"`
+	MediakindSubscription string `yaml:"mediakindSubscription" json:"mediakindSubscription"`
+}
+
+// Source identifies the account in migration file headers and watermarks
+func (a BatchAccount) Source() MigrationSource {
+	return MigrationSource{Kind: SourceAzure, Subscription: a.Subscription, ResourceGroup: a.ResourceGroup, AccountName: a.AccountName}
+}
+
+// String is the account as shown in logs
+func (a BatchAccount) String() string {
+	return fmt.Sprintf("%v/%v/%v", a.Subscription, a.ResourceGroup, a.AccountName)
+}
+
+// Validate checks the account has everything needed to migrate it
+func (a BatchAccount) Validate() error {
+	if a.Subscription == "" || a.ResourceGroup == "" || a.AccountName == "" {
+		return fmt.Errorf("batch account %v needs subscription, resource group and account name", a)
+	}
+	if a.MediakindSubscription == "" {
+		return fmt.Errorf("batch account %v has no mk.io subscription", a)
+	}
+	return nil
+}
+
+// DiscoverAccounts lists the Media Services accounts of an Azure subscription. Each is migrated into
+// mediakindSubscription, or into the mk.io subscription named like the account if it is empty
+func DiscoverAccounts(ctx context.Context, subscription string, mediakindSubscription string, credentials AzureCredentialConfig) ([]BatchAccount, error) {
+	log.Infof("Discovering Media Services accounts of subscription %v", subscription)
+	credential, err := credentials.newCredential()
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain a credential: %v", err)
+	}
+	options, err := credentials.clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	client, err := armmediaservices.NewClient(subscription, credential, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Azure Media Service Client: %v", err)
+	}
+
+	accounts := []BatchAccount{}
+	pager := client.NewListBySubscriptionPager(nil)
+	for pager.More() {
+		nextResult, err := pager.NextPage(ctx)
+		if err != nil {
+			return accounts, fmt.Errorf("failed to advance page: %v", err)
+		}
+		for _, v := range nextResult.Value {
+			id, err := arm.ParseResourceID(*v.ID)
+			if err != nil {
+				return accounts, fmt.Errorf("unable to parse the ID of Media Services account %v: %v", *v.Name, err)
+			}
+			account := BatchAccount{Subscription: subscription, ResourceGroup: id.ResourceGroupName, AccountName: *v.Name, MediakindSubscription: mediakindSubscription}
+			if account.MediakindSubscription == "" {
+				account.MediakindSubscription = *v.Name
+			}
+			log.Debugf("Discovered Media Services account %v", account)
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+// BatchResult is the outcome of the migration of one account of a batch
+type BatchResult struct {
+	Account       BatchAccount
+	MigrationFile string
+	Results       []Result
+	// Err is set if the account couldn't be migrated at all, e.g. because logging in failed
+	Err      error
+	Duration time.Duration
+}
+
+// RunBatch migrates accounts with migrate, up to concurrency of them at a time. The results are in the order of
+// accounts. Accounts not started before ctx is cancelled fail with its error
+func RunBatch(ctx context.Context, accounts []BatchAccount, concurrency int, migrate func(context.Context, BatchAccount) BatchResult) []BatchResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]BatchResult, len(accounts))
+	jobs := make(chan int)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				account := accounts[j]
+				if ctx.Err() != nil {
+					results[j] = BatchResult{Account: account, Err: ctx.Err()}
+					continue
+				}
+				log.Infof("Migrating account %v into mk.io subscription %v", account, account.MediakindSubscription)
+				start := time.Now()
+				results[j] = migrate(ctx, account)
+				results[j].Account = account
+				results[j].Duration = time.Since(start)
+				if results[j].Err != nil {
+					log.Errorf("unable to migrate account %v: %v", account, results[j].Err)
+				} else {
+					log.Infof("Done migrating account %v", account)
+				}
+			}
+		}()
+	}
+	for j := range accounts {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// BatchReport is the consolidated report of a batch migration
+type BatchReport struct {
+	Accounts []BatchAccountReport `json:"accounts"`
+	// Totals are the sums over all accounts, per operation and resource type, e.g. import assets
+	Totals []BatchTotal `json:"totals"`
+}
+
+// BatchAccountReport is the part of a BatchReport about one account
+type BatchAccountReport struct {
+	BatchAccount
+	MigrationFile string          `json:"migrationFile,omitempty"`
+	Duration      string          `json:"duration"`
+	Error         string          `json:"error,omitempty"`
+	Totals        []BatchTotal    `json:"totals"`
+	Failures      []ImportFailure `json:"failures,omitempty"`
+}
+
+// BatchTotal counts the resources of an operation on a resource type
+type BatchTotal struct {
+	Operation string `json:"operation"`
+	Resource  string `json:"resource"`
+	Migrated  int    `json:"migrated"`
+	Skipped   int    `json:"skipped"`
+	Failed    int    `json:"failed"`
+	Retries   int    `json:"retries"`
+	// Errors counts the operations that failed as a whole
+	Errors int `json:"errors"`
+}
+
+// NewBatchReport consolidates the results of a batch migration
+func NewBatchReport(results []BatchResult) BatchReport {
+	report := BatchReport{Accounts: []BatchAccountReport{}}
+	totals := []BatchTotal{}
+	for _, r := range results {
+		account := BatchAccountReport{
+			BatchAccount:  r.Account,
+			MigrationFile: r.MigrationFile,
+			Duration:      r.Duration.Round(time.Millisecond).String(),
+			Totals:        addBatchTotals([]BatchTotal{}, r.Results),
+			Failures:      Failures(r.Results),
+		}
+		if r.Err != nil {
+			account.Error = r.Err.Error()
+		}
+		report.Accounts = append(report.Accounts, account)
+		totals = addBatchTotals(totals, r.Results)
+	}
+	report.Totals = totals
+	return report
+}
+
+// addBatchTotals adds results to the totals of their operation and resource type
+func addBatchTotals(totals []BatchTotal, results []Result) []BatchTotal {
+	for _, v := range results {
+		i := 0
+		for i < len(totals) && (totals[i].Operation != v.Operation || totals[i].Resource != v.Resource) {
+			i++
+		}
+		if i == len(totals) {
+			totals = append(totals, BatchTotal{Operation: v.Operation, Resource: v.Resource})
+		}
+		totals[i].Migrated += v.Migrated
+		totals[i].Skipped += v.Skipped
+		totals[i].Failed += len(v.Failures)
+		totals[i].Retries += v.Retries
+		if v.Err != nil {
+			totals[i].Errors++
+		}
+	}
+	return totals
+}
+
+// Failed returns true if any account, operation or resource of the batch failed
+func (r BatchReport) Failed() bool {
+	for _, a := range r.Accounts {
+		if a.Error != "" || len(a.Failures) > 0 {
+			return true
+		}
+	}
+	for _, t := range r.Totals {
+		if t.Errors > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteFile writes the report to fileName as JSON
+func (r BatchReport) WriteFile(fileName string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(fileName, data, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to write batch report %v: %v", fileName, err)
+	}
+	return nil
+}
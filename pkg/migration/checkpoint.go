@@ -0,0 +1,274 @@
+package migrate
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CheckpointStatus is the import state of a single resource
+type CheckpointStatus string
+
+const (
+	// StatusPending is reported for resources that have no entry in the checkpoint store yet
+	StatusPending  CheckpointStatus = "pending"
+	StatusImported CheckpointStatus = "imported"
+	StatusSkipped  CheckpointStatus = "skipped"
+	StatusFailed   CheckpointStatus = "failed"
+)
+
+// ImportAction records what an import did to mk.io. Used by rollback to find the resources a run created
+type ImportAction string
+
+const (
+	// ImportCreated - the resource did not exist before the import
+	ImportCreated ImportAction = "created"
+	// ImportOverwritten - the resource existed and was replaced or updated because of overwrite
+	ImportOverwritten ImportAction = "overwritten"
+	// ImportExisting - the resource existed and was left alone
+	ImportExisting ImportAction = "existing"
+)
+
+// importAction returns the action of a successful import, depending on whether the resource existed
+func importAction(found bool) ImportAction {
+	if found {
+		return ImportOverwritten
+	}
+	return ImportCreated
+}
+
+// CheckpointEntry records the outcome of importing a single resource
+type CheckpointEntry struct {
+	Kind      string           `json:"kind"`
+	AssetName string           `json:"assetName,omitempty"`
+	Name      string           `json:"name"`
+	Status    CheckpointStatus `json:"status"`
+	Action    ImportAction     `json:"action,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Time      time.Time        `json:"time"`
+	// Subscription is the mk.io subscription the resource was imported into
+	Subscription string `json:"subscription,omitempty"`
+}
+
+// String returns the name of the resource, prefixed with its asset for Asset Filters
+func (e CheckpointEntry) String() string {
+	if e.AssetName != "" {
+		return e.AssetName + "/" + e.Name
+	}
+	return e.Name
+}
+
+// CheckpointStore persists the import status of every resource so an interrupted import can be resumed.
+// It doubles as the journal of the run, which rollback reads to undo an import.
+// Entries are appended to the file as JSON lines and the latest entry for a resource wins.
+// A nil *CheckpointStore is valid and records nothing.
+type CheckpointStore struct {
+	mu           sync.Mutex
+	file         *os.File
+	subscription string
+	entries      map[string]CheckpointEntry
+}
+
+// OpenCheckpointStore opens the checkpoint file of an import into the mk.io subscription. When resume is set
+// existing entries are loaded, and they must be of the same subscription. Otherwise the import starts from scratch,
+// and the journal of the previous run is kept as <fileName>.<time of its last entry> so it can still be rolled back.
+func OpenCheckpointStore(fileName string, resume bool, subscription string) (*CheckpointStore, error) {
+	store := &CheckpointStore{subscription: subscription, entries: map[string]CheckpointEntry{}}
+
+	if resume {
+		err := store.load(fileName)
+		if err != nil {
+			return nil, err
+		}
+		if err := CheckJournalSubscription(store.Entries(), subscription); err != nil {
+			return nil, fmt.Errorf("cannot resume from checkpoint file %v: %v", fileName, err)
+		}
+	} else if err := rotateJournal(fileName); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open checkpoint file %v: %v", fileName, err)
+	}
+	store.file = f
+
+	return store, nil
+}
+
+// rotateJournal moves the journal of a previous run out of the way. Empty journals have nothing to roll back
+func rotateJournal(fileName string) error {
+	info, err := os.Stat(fileName)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && info.Size() == 0) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to open checkpoint file %v: %v", fileName, err)
+	}
+	previous := fmt.Sprintf("%v.%v", fileName, info.ModTime().UTC().Format("20060102T150405Z"))
+	// Never replace the journal of an older run
+	for i := 1; fileExists(previous); i++ {
+		previous = fmt.Sprintf("%v.%v-%d", fileName, info.ModTime().UTC().Format("20060102T150405Z"), i)
+	}
+	if err := os.Rename(fileName, previous); err != nil {
+		return fmt.Errorf("unable to keep the previous checkpoint file %v: %v", fileName, err)
+	}
+	log.Infof("Journal of the previous import kept as %v. Roll it back with --checkpoint-file %v", previous, previous)
+	return nil
+}
+
+// fileExists returns true if anything is at fileName
+func fileExists(fileName string) bool {
+	_, err := os.Stat(fileName)
+	return err == nil
+}
+
+// CheckJournalSubscription checks the journal entries were recorded by an import into the mk.io subscription.
+// Journals written before the subscription was recorded can't be checked and pass
+func CheckJournalSubscription(entries []CheckpointEntry, subscription string) error {
+	for _, entry := range entries {
+		if entry.Subscription != "" && entry.Subscription != subscription {
+			return fmt.Errorf("the journal was recorded by an import into mk.io subscription %v, not %v", entry.Subscription, subscription)
+		}
+	}
+	return nil
+}
+
+// load reads the entries of an existing checkpoint file
+func (s *CheckpointStore) load(fileName string) error {
+	f, err := os.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Warnf("no checkpoint file %v found. Starting from scratch", fileName)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to open checkpoint file %v: %v", fileName, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := CheckpointEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// The last line may be incomplete if we were killed mid-write. Anything in it will be retried
+			log.Warnf("ignoring unreadable checkpoint entry: %v", err)
+			continue
+		}
+		s.entries[checkpointKey(entry.Kind, entry.AssetName, entry.Name)] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read checkpoint file %v: %v", fileName, err)
+	}
+
+	log.Infof("Loaded %d checkpoint entries from %v", len(s.entries), fileName)
+	return nil
+}
+
+func checkpointKey(kind string, assetName string, name string) string {
+	return kind + "/" + assetName + "/" + name
+}
+
+// Status returns the last recorded status of a resource. assetName is only used for AssetFilters.
+func (s *CheckpointStore) Status(kind string, assetName string, name string) CheckpointStatus {
+	if s == nil {
+		return StatusPending
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[checkpointKey(kind, assetName, name)]
+	if !ok {
+		return StatusPending
+	}
+	return entry.Status
+}
+
+// Done returns true if a previous run already imported or skipped the resource
+func (s *CheckpointStore) Done(kind string, assetName string, name string) bool {
+	status := s.Status(kind, assetName, name)
+	return status == StatusImported || status == StatusSkipped
+}
+
+// Record persists the status of a resource and what the import did to it. err is stored for failed resources.
+func (s *CheckpointStore) Record(kind string, assetName string, name string, status CheckpointStatus, action ImportAction, err error) {
+	if s == nil {
+		return
+	}
+
+	entry := CheckpointEntry{
+		Kind:         kind,
+		AssetName:    assetName,
+		Name:         name,
+		Status:       status,
+		Action:       action,
+		Time:         time.Now().UTC(),
+		Subscription: s.subscription,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	line, mErr := json.Marshal(entry)
+	if mErr != nil {
+		log.Errorf("unable to marshal checkpoint entry for %v %v: %v", kind, name, mErr)
+		return
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[checkpointKey(kind, assetName, name)] = entry
+	if _, wErr := s.file.Write(line); wErr != nil {
+		log.Errorf("unable to write checkpoint entry for %v %v: %v", kind, name, wErr)
+	}
+}
+
+// Entries returns the latest entry of every resource
+func (s *CheckpointStore) Entries() []CheckpointEntry {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]CheckpointEntry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// ReadJournal reads the entries of a checkpoint file without opening it for writing
+func ReadJournal(fileName string) ([]CheckpointEntry, error) {
+	if _, err := os.Stat(fileName); err != nil {
+		return nil, fmt.Errorf("unable to open journal %v: %v", fileName, err)
+	}
+	store := &CheckpointStore{entries: map[string]CheckpointEntry{}}
+	err := store.load(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return store.Entries(), nil
+}
+
+// Close flushes the checkpoint file to disk
+func (s *CheckpointStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	return s.file.Close()
+}
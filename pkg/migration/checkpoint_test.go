@@ -0,0 +1,132 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointStoreResume(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "import.checkpoint")
+	store, err := OpenCheckpointStore(fileName, false, "sub")
+	if err != nil {
+		t.Fatalf("OpenCheckpointStore: %v", err)
+	}
+	store.Record(ASSETS, "", "a1", StatusFailed, "", fmt.Errorf("failed"))
+	store.Record(ASSETS, "", "a1", StatusImported, ImportCreated, nil)
+	store.Record(ASSETS, "", "a2", StatusSkipped, ImportExisting, nil)
+	store.Record(ASSETFILTERS, "a1", "f1", StatusFailed, "", fmt.Errorf("failed"))
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// Killed in the middle of writing an entry
+	f, err := os.OpenFile(fileName, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"kind":"assets","name":"a3","sta`)
+	f.Close()
+
+	resumed, err := OpenCheckpointStore(fileName, true, "sub")
+	if err != nil {
+		t.Fatalf("OpenCheckpointStore: %v", err)
+	}
+	defer resumed.Close()
+
+	tests := []struct {
+		kind      string
+		assetName string
+		name      string
+		status    CheckpointStatus
+		done      bool
+	}{
+		{kind: ASSETS, name: "a1", status: StatusImported, done: true},
+		{kind: ASSETS, name: "a2", status: StatusSkipped, done: true},
+		{kind: ASSETS, name: "a3", status: StatusPending},
+		{kind: ASSETFILTERS, assetName: "a1", name: "f1", status: StatusFailed},
+		{kind: ASSETFILTERS, assetName: "a2", name: "f1", status: StatusPending},
+		{kind: STREAMINGLOCATORS, name: "a1", status: StatusPending},
+	}
+	for _, tt := range tests {
+		if got := resumed.Status(tt.kind, tt.assetName, tt.name); got != tt.status {
+			t.Errorf("Status(%v %v/%v) = %v, want %v", tt.kind, tt.assetName, tt.name, got, tt.status)
+		}
+		if got := resumed.Done(tt.kind, tt.assetName, tt.name); got != tt.done {
+			t.Errorf("Done(%v %v/%v) = %v, want %v", tt.kind, tt.assetName, tt.name, got, tt.done)
+		}
+	}
+	if entries := resumed.Entries(); len(entries) != 3 {
+		t.Errorf("resumed %d entries, want the latest of 3 resources", len(entries))
+	}
+}
+
+func TestCheckpointStoreNil(t *testing.T) {
+	var store *CheckpointStore
+	store.Record(ASSETS, "", "a1", StatusImported, ImportCreated, nil)
+	if store.Status(ASSETS, "", "a1") != StatusPending || store.Done(ASSETS, "", "a1") || store.Entries() != nil || store.Close() != nil {
+		t.Errorf("nil checkpoint store recorded something")
+	}
+}
+
+func TestCheckpointStoreStartOver(t *testing.T) {
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "import.checkpoint")
+	for i := 0; i < 2; i++ {
+		store, err := OpenCheckpointStore(fileName, false, "sub")
+		if err != nil {
+			t.Fatalf("OpenCheckpointStore: %v", err)
+		}
+		if store.Done(ASSETS, "", "a1") {
+			t.Errorf("a new import starts with the entries of the previous one")
+		}
+		store.Record(ASSETS, "", "a1", StatusImported, ImportCreated, nil)
+		store.Close()
+	}
+
+	// The journals of the previous runs are kept so they can be rolled back
+	files, _ := filepath.Glob(filepath.Join(dir, "import.checkpoint*"))
+	if len(files) != 2 {
+		t.Errorf("files %v, want the journal and the previous one", files)
+	}
+	for _, v := range files {
+		entries, err := ReadJournal(v)
+		if err != nil || len(entries) != 1 {
+			t.Errorf("ReadJournal(%v) = %v, %v, want 1 entry", v, entries, err)
+		}
+	}
+
+	// Empty journals have nothing to keep
+	store, err := OpenCheckpointStore(filepath.Join(dir, "empty.checkpoint"), false, "sub")
+	if err != nil {
+		t.Fatalf("OpenCheckpointStore: %v", err)
+	}
+	store.Close()
+	if _, err := OpenCheckpointStore(filepath.Join(dir, "empty.checkpoint"), false, "sub"); err != nil {
+		t.Fatalf("OpenCheckpointStore: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "empty.checkpoint*")); len(files) != 1 {
+		t.Errorf("files %v, want only the journal", files)
+	}
+}
+
+func TestCheckpointStoreSubscription(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "import.checkpoint")
+	store, err := OpenCheckpointStore(fileName, false, "sub")
+	if err != nil {
+		t.Fatalf("OpenCheckpointStore: %v", err)
+	}
+	store.Record(ASSETS, "", "a1", StatusImported, ImportCreated, nil)
+	store.Close()
+
+	if _, err := OpenCheckpointStore(fileName, true, "other"); err == nil {
+		t.Errorf("resumed the import of another subscription")
+	}
+	entries, err := ReadJournal(fileName)
+	if err != nil {
+		t.Fatalf("ReadJournal: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Subscription != "sub" || entries[0].Action != ImportCreated {
+		t.Errorf("journal entries %+v, want a1 created in sub", entries)
+	}
+}
@@ -5,14 +5,25 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
 )
 
+// Resource kinds handled by the migration
+const ASSETS = "assets"
+const ASSETFILTERS = "assetFilters"
+const STREAMINGPOLICIES = "streamingPolicies"
+const STREAMINGLOCATORS = "streamingLocators"
+const STREAMINGENDPOINTS = "streamingEndpoints"
+const CONTENTKEYPOLICIES = "contentKeyPolicies"
+const CONTENTKEYS = "contentKeys"
+
 // MigrationFileContents contains the contents for the StreamingEndpoint File
 type MigrationFileContents struct {
+	// Header is nil in files of schema version 1
+	Header *MigrationFileHeader `json:",omitempty"`
+
 	AssetFilters       map[string][]*armmediaservices.AssetFilter
 	Assets             []*armmediaservices.Asset
 	ContentKeyPolicies []*armmediaservices.ContentKeyPolicy
@@ -22,10 +33,10 @@ type MigrationFileContents struct {
 	StreamingPolicies  []*armmediaservices.StreamingPolicy
 }
 
-func (contents MigrationFileContents) WriteMigrationFile(ctx context.Context, fileName string) error {
-	migrationBytes, err := json.Marshal(contents)
-	if err != nil {
-		return fmt.Errorf("unable to marshal assets: %v", err)
+// WriteMigrationFile writes the contents to a file in the given format, FormatJSON or FormatNDJSON
+func (contents MigrationFileContents) WriteMigrationFile(ctx context.Context, fileName string, format string) error {
+	if format != FormatJSON && format != FormatNDJSON {
+		return fmt.Errorf("unknown migration file format %v", format)
 	}
 
 	// Create the file
@@ -37,7 +48,11 @@ func (contents MigrationFileContents) WriteMigrationFile(ctx context.Context, fi
 	// Close the file when we're done w/ it
 	defer f.Close()
 
-	_, err = f.Write(migrationBytes)
+	if format == FormatNDJSON {
+		err = contents.WriteNDJSON(f)
+	} else {
+		err = json.NewEncoder(f).Encode(contents)
+	}
 	if err != nil {
 		return fmt.Errorf("unable to write to file %v: %v", fileName, err)
 	}
@@ -46,7 +61,14 @@ func (contents MigrationFileContents) WriteMigrationFile(ctx context.Context, fi
 	return nil
 }
 
-func (contents *MigrationFileContents) ReadMigrationFile(ctx context.Context, fileName string) error {
+// ReadMigrationFile reads a migration file in either format. The format is detected from the contents.
+// Encrypted secrets are decrypted with keys. With nil keys they are left encrypted, as they are in the file
+func (contents *MigrationFileContents) ReadMigrationFile(ctx context.Context, fileName string, keys *SecretKeys) error {
+	format, err := ReadMigrationFileFormat(fileName)
+	if err != nil {
+		return err
+	}
+
 	// Read in our migration file
 	file, err := os.Open(fileName)
 	if err != nil {
@@ -54,23 +76,37 @@ func (contents *MigrationFileContents) ReadMigrationFile(ctx context.Context, fi
 	}
 	defer file.Close()
 
-	// Validate a file exists
-	stat, err := file.Stat()
+	if format == FormatNDJSON {
+		err = contents.ReadNDJSON(file)
+	} else {
+		err = json.NewDecoder(bufio.NewReader(file)).Decode(contents)
+	}
 	if err != nil {
-		return fmt.Errorf("unable to get migration file stats: %v", err)
+		return fmt.Errorf("unable to unmarshal migration file contents: %v", err)
 	}
-
-	// Read the file into a byte slice
-	bs := make([]byte, stat.Size())
-	_, err = bufio.NewReader(file).Read(bs)
-	if err != nil && err != io.EOF {
-		return fmt.Errorf("unable to read migration file: %v", err)
+	if contents.Version() > SchemaVersion {
+		return fmt.Errorf("migration file has schema version %d, this tool only supports up to %d", contents.Version(), SchemaVersion)
+	}
+	if keys != nil {
+		if err := contents.DecryptSecrets(*keys); err != nil {
+			return fmt.Errorf("unable to decrypt migration file secrets: %v", err)
+		}
 	}
 
-	err = json.Unmarshal(bs, contents)
+	return nil
+}
+
+// ReadMigrationFileFormat returns the format of a migration file, FormatJSON or FormatNDJSON
+func ReadMigrationFileFormat(fileName string) (string, error) {
+	file, err := os.Open(fileName)
 	if err != nil {
-		return fmt.Errorf("unable to unmarshal migration file contents: %v", err)
+		return "", fmt.Errorf("unable to open migration file: %v: %v", fileName, err)
 	}
+	defer file.Close()
 
-	return nil
+	format, err := DetectFormat(bufio.NewReader(file))
+	if err != nil {
+		return "", fmt.Errorf("unable to read migration file %v: %v", fileName, err)
+	}
+	return format, nil
 }
@@ -3,8 +3,6 @@ package migrate
 import (
 	"context"
 	"fmt"
-	"strings"
-	"sync"
 
 	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
@@ -39,131 +37,78 @@ func ExportMkContentKeyPolicies(ctx context.Context, client *mkiosdk.ContentKeyP
 	return contentKeyPolicies, nil
 }
 
-// ImportContentKeyPoliciesWorker - Do work to import Content Key Policies into MKIO
-func ImportContentKeyPoliciesWorker(ctx context.Context, client *mkiosdk.ContentKeyPoliciesClient, overwrite bool, wg *sync.WaitGroup, jobs chan *mkiosdk.FPContentKeyPolicy, successChan chan string, skippedChan chan string, failedChan chan string) {
+// importContentKeyPolicy imports a single ContentKeyPolicy into MKIO and records the outcome in the checkpoint store.
+// Returns true if the ContentKeyPolicy already existed and was skipped.
+func importContentKeyPolicy(ctx context.Context, client *mkiosdk.ContentKeyPoliciesClient, contentKeyPolicy *mkiosdk.FPContentKeyPolicy, overwrite bool, skipUnchanged bool, checkpoint *CheckpointStore, snapshots *SnapshotStore) (bool, error) {
+	// Check if ContentKeyPolicy already exists. Skip update unless overwrite is set
+	_, err := client.Get(ctx, *contentKeyPolicy.Name, nil)
+	found := err == nil
+	if err != nil && !mkiosdk.IsNotFound(err) {
+		log.Errorf("unable to look up ContentKeyPolicy %v: %v", *contentKeyPolicy.Name, err)
+		checkpoint.Record(CONTENTKEYPOLICIES, "", *contentKeyPolicy.Name, StatusFailed, "", err)
+		return false, err
+	}
+	if found && !overwrite {
+		// Found something and we're not overwriting. We should skip it
+		checkpoint.Record(CONTENTKEYPOLICIES, "", *contentKeyPolicy.Name, StatusSkipped, ImportExisting, nil)
+		return true, nil
+	}
 
-	for contentKeyPolicy := range jobs {
-		found := true
-		// Check if ContentKeyPolicy already exists. Skip update unless overwrite is set
-		_, err := client.Get(ctx, *contentKeyPolicy.Name, nil)
-		if err != nil {
-			if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "Not Found") {
-				found = false
-			}
+	if found && overwrite {
+		// Keep a copy of the ContentKeyPolicy we're about to delete. The plain Get doesn't return the keys
+		existing, lookupErr := client.GetPolicyPropertiesWithSecrets(ctx, *contentKeyPolicy.Name, nil)
+		if lookupErr == nil && skipUnchanged && sameResource(&existing.ContentKeyPolicy, &contentKeyPolicy.ContentKeyPolicy) {
+			log.Debugf("ContentKeyPolicy is the same in MKIO, skipping: %v", *contentKeyPolicy.Name)
+			checkpoint.Record(CONTENTKEYPOLICIES, "", *contentKeyPolicy.Name, StatusSkipped, ImportExisting, nil)
+			return true, nil
 		}
-		if found && !overwrite {
-			// Found something and we're not overwriting. We should skip it
-			skippedChan <- *contentKeyPolicy.Name
-			wg.Done()
-			continue
-		}
-
-		if found && overwrite {
-			// it exists, but we're overwriting, so we should delete it
-			_, err := client.Delete(ctx, *contentKeyPolicy.Name, nil)
-			if err != nil {
-				failedChan <- *contentKeyPolicy.Name
-				log.Errorf("unable to delete old ContentKeyPolicy %v for overwrite: %v", *contentKeyPolicy.Name, err)
-				wg.Done()
-				continue
-			}
+		err := snapshots.saveExisting(CONTENTKEYPOLICIES, "", *contentKeyPolicy.Name, existing.ContentKeyPolicy, lookupErr)
+		if err != nil {
+			log.Errorf("not overwriting ContentKeyPolicy %v: %v", *contentKeyPolicy.Name, err)
+			checkpoint.Record(CONTENTKEYPOLICIES, "", *contentKeyPolicy.Name, StatusFailed, "", err)
+			return false, err
 		}
 
-		log.Debugf("Creating ContentKeyPolicy in MKIO: %v", *contentKeyPolicy.Name)
-
-		_, err = client.CreateOrUpdate(ctx, *contentKeyPolicy.Name, contentKeyPolicy, nil)
+		// it exists, but we're overwriting, so we should delete it
+		_, err = client.Delete(ctx, *contentKeyPolicy.Name, nil)
 		if err != nil {
-			failedChan <- *contentKeyPolicy.Name
-			log.Errorf("unable to import ContentKeyPolicy %v: %v", *contentKeyPolicy.Name, err)
-		} else {
-			successChan <- *contentKeyPolicy.Name
+			log.Errorf("unable to delete old ContentKeyPolicy %v for overwrite: %v", *contentKeyPolicy.Name, err)
+			checkpoint.Record(CONTENTKEYPOLICIES, "", *contentKeyPolicy.Name, StatusFailed, "", err)
+			return false, err
 		}
-		wg.Done()
 	}
-}
 
-// ImportContentKeyPolicies reads a file containing ContentKeyPolicies in JSON format. Insert each ContentKeyPolicy into MKIO
-func ImportContentKeyPolicies(ctx context.Context, client *mkiosdk.ContentKeyPoliciesClient, contentKeyPolicies []*armmediaservices.ContentKeyPolicy, overwrite bool, fairplayAmsCompatibility bool, workers int) (int, int, []string, error) {
-	log.Info("Importing ContentKeyPolicies")
+	log.Debugf("Creating ContentKeyPolicy in MKIO: %v", *contentKeyPolicy.Name)
 
-	// Waitgroup to wait for all goroutines to finish
-	wg := new(sync.WaitGroup)
-
-	// Create channels to communicate between workers
-	successChan := make(chan string, len(contentKeyPolicies))
-	skippedChan := make(chan string, len(contentKeyPolicies))
-	failedChan := make(chan string, len(contentKeyPolicies))
-	jobs := make(chan *mkiosdk.FPContentKeyPolicy, len(contentKeyPolicies))
-
-	// Setup worker pool. This will start X workers to handle jobs
-	for w := 1; w <= workers; w++ {
-		log.Infof("Starting ContentKeyPolicy worker %d", w)
-		go ImportContentKeyPoliciesWorker(ctx, client, overwrite, wg, jobs, successChan, skippedChan, failedChan)
+	_, err = client.CreateOrUpdate(ctx, *contentKeyPolicy.Name, contentKeyPolicy, nil)
+	if err != nil {
+		log.Errorf("unable to import ContentKeyPolicy %v: %v", *contentKeyPolicy.Name, err)
+		checkpoint.Record(CONTENTKEYPOLICIES, "", *contentKeyPolicy.Name, StatusFailed, "", err)
+		return false, err
 	}
+	checkpoint.Record(CONTENTKEYPOLICIES, "", *contentKeyPolicy.Name, StatusImported, importAction(found), nil)
+	return false, nil
+}
 
-	failedContentKeyPolicies := []string{}
-	skipped := 0
-	successCount := 0
-	// Workaround to add FairPlayAmsCompatibility element to ContentKeyPolicy
-	fpContentKeyPolicies := make([]*mkiosdk.FPContentKeyPolicy, 0)
-	for _, contentKeyPolicy := range contentKeyPolicies {
-		val := false
-		if fairplayAmsCompatibility {
-			for _, option := range contentKeyPolicy.Properties.Options {
-				if *option.Configuration.GetContentKeyPolicyConfiguration().ODataType == fpConfiguration {
-					val = true
-					break
-				}
+// toFPContentKeyPolicy adds the FairPlayAmsCompatibility element mk.io expects to a ContentKeyPolicy.
+// It is only set when requested and the policy has a FairPlay option
+func toFPContentKeyPolicy(contentKeyPolicy *armmediaservices.ContentKeyPolicy, fairplayAmsCompatibility bool) *mkiosdk.FPContentKeyPolicy {
+	val := false
+	if fairplayAmsCompatibility {
+		for _, option := range contentKeyPolicy.Properties.Options {
+			if *option.Configuration.GetContentKeyPolicyConfiguration().ODataType == fpConfiguration {
+				val = true
+				break
 			}
 		}
-		fpContentKeyPolicies = append(fpContentKeyPolicies,
-			&mkiosdk.FPContentKeyPolicy{
-				ContentKeyPolicy: *contentKeyPolicy,
-				FPProperties: &mkiosdk.FPContentKeyPolicyProperties{
-					ContentKeyPolicyProperties: *contentKeyPolicy.Properties,
-					FairPlayAmsCompatibility:   &val,
-				},
-			})
-	}
-
-	// create each ContentKeyPolicy
-	for _, contentKeyPolicy := range fpContentKeyPolicies {
-		wg.Add(1)
-		jobs <- contentKeyPolicy
 	}
-
-	log.Info("Waiting for Content Key Policy workers to finish")
-	wg.Wait()
-	log.Info("Done importing content key policies")
-
-	close(jobs)
-	close(successChan)
-	close(skippedChan)
-	close(failedChan)
-	for f := range successChan {
-		if f != "" {
-			successCount++
-		}
-	}
-	for result := range skippedChan {
-		if result != "" {
-			skipped++
-		}
+	return &mkiosdk.FPContentKeyPolicy{
+		ContentKeyPolicy: *contentKeyPolicy,
+		FPProperties: &mkiosdk.FPContentKeyPolicyProperties{
+			ContentKeyPolicyProperties: *contentKeyPolicy.Properties,
+			FairPlayAmsCompatibility:   &val,
+		},
 	}
-	for result := range failedChan {
-		if result != "" {
-			failedContentKeyPolicies = append(failedContentKeyPolicies, result)
-		}
-	}
-
-	log.Infof("Skipped %d existing ContentKeyPolicies", skipped)
-	log.Infof("Imported %d ContentKeyPolicies", successCount)
-
-	if len(failedContentKeyPolicies) > 0 {
-		return successCount, skipped, failedContentKeyPolicies, fmt.Errorf("failed to import %d ContentKeyPolicies: %v", len(failedContentKeyPolicies), failedContentKeyPolicies)
-	}
-
-	return successCount, skipped, failedContentKeyPolicies, nil
 }
 
 // ValidateContentKeyPolicies TODO
@@ -0,0 +1,357 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// DefaultDiffIgnore are the fields Diff ignores by default. They change without the resource changing
+var DefaultDiffIgnore = []string{"created", "lastModified", "systemData"}
+
+// DiffReport is the difference between two sets of resources, per resource type in ImportOrder
+type DiffReport struct {
+	Kinds []KindDiff `json:"kinds"`
+}
+
+// KindDiff is the difference between the resources of one type. Asset Filters are named asset/filter
+type KindDiff struct {
+	Kind      string         `json:"kind"`
+	Added     []string       `json:"added"`
+	Removed   []string       `json:"removed"`
+	Changed   []ResourceDiff `json:"changed"`
+	Unchanged int            `json:"unchanged"`
+}
+
+// ResourceDiff lists the fields that differ in a resource present on both sides
+type ResourceDiff struct {
+	Name   string        `json:"name"`
+	Fields []FieldChange `json:"fields"`
+}
+
+// FieldChange is a single field that differs. Old or New is missing if the field is only on one side
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Empty returns true if there is no difference
+func (r DiffReport) Empty() bool {
+	for _, v := range r.Kinds {
+		if len(v.Added) > 0 || len(v.Removed) > 0 || len(v.Changed) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff compares old and updated, per resource type and name. Fields named in ignore are left out at any depth.
+// Secret fields are compared by hash, so their values don't end up in the report. If either side still has its
+// secrets encrypted or redacted, they aren't compared at all. Only kinds are compared, all resource types if kinds
+// is empty
+func Diff(old MigrationFileContents, updated MigrationFileContents, kinds []string, ignore []string) (DiffReport, error) {
+	report := DiffReport{Kinds: []KindDiff{}}
+
+	protected := func(c MigrationFileContents) bool {
+		return c.Header != nil && c.Header.Secrets != nil
+	}
+	hideSecrets := func(c MigrationFileContents) (map[string]map[string]interface{}, error) {
+		return c.diffResources(protected(old) || protected(updated))
+	}
+	oldResources, err := hideSecrets(old)
+	if err != nil {
+		return report, err
+	}
+	newResources, err := hideSecrets(updated)
+	if err != nil {
+		return report, err
+	}
+
+	ignored := map[string]bool{}
+	for _, v := range ignore {
+		ignored[v] = true
+	}
+	selected := map[string]bool{}
+	for _, v := range kinds {
+		selected[v] = true
+	}
+
+	for _, kind := range ImportOrder {
+		if len(kinds) > 0 && !selected[kind] {
+			continue
+		}
+		d := KindDiff{Kind: kind, Added: []string{}, Removed: []string{}, Changed: []ResourceDiff{}}
+		for _, name := range sortedKeys(oldResources[kind]) {
+			if _, ok := newResources[kind][name]; !ok {
+				d.Removed = append(d.Removed, name)
+			}
+		}
+		for _, name := range sortedKeys(newResources[kind]) {
+			oldResource, ok := oldResources[kind][name]
+			if !ok {
+				d.Added = append(d.Added, name)
+				continue
+			}
+			changes := diffValues("", stripFields(oldResource, ignored), stripFields(newResources[kind][name], ignored))
+			if len(changes) == 0 {
+				d.Unchanged++
+				continue
+			}
+			d.Changed = append(d.Changed, ResourceDiff{Name: name, Fields: changes})
+		}
+		report.Kinds = append(report.Kinds, d)
+	}
+	return report, nil
+}
+
+// diffResources returns the resources of the contents as generic JSON values, by type and name. Secret fields are
+// replaced by a hash of their value, or removed
+func (contents MigrationFileContents) diffResources(dropSecrets bool) (map[string]map[string]interface{}, error) {
+	resources := map[string]map[string]interface{}{}
+	add := func(kind string, name string, resource interface{}) error {
+		if len(secretFields(resource)) > 0 {
+			c, err := copyResource(resource)
+			if err != nil {
+				return err
+			}
+			for _, field := range secretFields(c) {
+				value, ok := field.get()
+				if !ok {
+					continue
+				}
+				if dropSecrets {
+					field.clear()
+					continue
+				}
+				sum := sha256.Sum256(value)
+				field.set([]byte("sha256:" + hex.EncodeToString(sum[:8])))
+			}
+			resource = c
+		}
+
+		data, err := json.Marshal(resource)
+		if err != nil {
+			return fmt.Errorf("unable to compare %v %v: %v", kind, name, err)
+		}
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return fmt.Errorf("unable to compare %v %v: %v", kind, name, err)
+		}
+		if resources[kind] == nil {
+			resources[kind] = map[string]interface{}{}
+		}
+		resources[kind][name] = v
+		return nil
+	}
+
+	var err error
+	for _, v := range contents.Assets {
+		if err = add(ASSETS, resourceName(v.Name), v); err != nil {
+			return nil, err
+		}
+	}
+	for assetName, filters := range contents.AssetFilters {
+		for _, v := range filters {
+			if err = add(ASSETFILTERS, assetName+"/"+resourceName(v.Name), v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, v := range contents.ContentKeyPolicies {
+		if err = add(CONTENTKEYPOLICIES, resourceName(v.Name), v); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range contents.StreamingEndpoints {
+		if err = add(STREAMINGENDPOINTS, resourceName(v.Name), v); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range contents.StreamingLocators {
+		if err = add(STREAMINGLOCATORS, resourceName(v.Name), v); err != nil {
+			return nil, err
+		}
+	}
+	for _, v := range contents.StreamingPolicies {
+		if err = add(STREAMINGPOLICIES, resourceName(v.Name), v); err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+// stripFields removes the ignored fields from a generic JSON value, at any depth
+func stripFields(v interface{}, ignored map[string]bool) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, value := range t {
+			if !ignored[k] {
+				m[k] = stripFields(value, ignored)
+			}
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(t))
+		for i, value := range t {
+			a[i] = stripFields(value, ignored)
+		}
+		return a
+	}
+	return v
+}
+
+// diffValues returns the differences between two generic JSON values. Objects are compared key by key and
+// arrays element by element
+func diffValues(path string, old interface{}, updated interface{}) []FieldChange {
+	changes := []FieldChange{}
+	join := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := updated.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		keys := map[string]interface{}{}
+		for k := range oldMap {
+			keys[k] = nil
+		}
+		for k := range newMap {
+			keys[k] = nil
+		}
+		for _, k := range sortedKeys(keys) {
+			oldValue, inOld := oldMap[k]
+			newValue, inNew := newMap[k]
+			switch {
+			case !inOld:
+				changes = append(changes, FieldChange{Path: join(k), New: newValue})
+			case !inNew:
+				changes = append(changes, FieldChange{Path: join(k), Old: oldValue})
+			default:
+				changes = append(changes, diffValues(join(k), oldValue, newValue)...)
+			}
+		}
+		return changes
+	}
+
+	oldArray, oldIsArray := old.([]interface{})
+	newArray, newIsArray := updated.([]interface{})
+	if oldIsArray && newIsArray {
+		for i := 0; i < len(oldArray) || i < len(newArray); i++ {
+			p := fmt.Sprintf("%v[%d]", path, i)
+			switch {
+			case i >= len(oldArray):
+				changes = append(changes, FieldChange{Path: p, New: newArray[i]})
+			case i >= len(newArray):
+				changes = append(changes, FieldChange{Path: p, Old: oldArray[i]})
+			default:
+				changes = append(changes, diffValues(p, oldArray[i], newArray[i])...)
+			}
+		}
+		return changes
+	}
+
+	if !reflect.DeepEqual(old, updated) {
+		changes = append(changes, FieldChange{Path: path, Old: old, New: updated})
+	}
+	return changes
+}
+
+// sameResource returns true if existing, read from mk.io, already has every field of resource with the same value.
+// Fields only mk.io sets, and the ones Diff ignores by default, aren't compared. Neither are the content keys of
+// StreamingLocators, which mk.io doesn't return with the locator
+func sameResource(existing interface{}, resource interface{}) bool {
+	ignored := map[string]bool{"id": true, "policyId": true}
+	for _, v := range DefaultDiffIgnore {
+		ignored[v] = true
+	}
+	if _, ok := resource.(*armmediaservices.StreamingLocator); ok {
+		ignored["contentKeys"] = true
+	}
+	generic := func(resource interface{}) (interface{}, error) {
+		data, err := json.Marshal(resource)
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		err = json.Unmarshal(data, &v)
+		return stripFields(v, ignored), err
+	}
+	old, err := generic(existing)
+	if err != nil {
+		return false
+	}
+	updated, err := generic(resource)
+	if err != nil {
+		return false
+	}
+	for _, change := range diffValues("", old, updated) {
+		// A field only mk.io has is fine, an array element it has too many isn't
+		if change.New != nil || strings.HasSuffix(change.Path, "]") {
+			return false
+		}
+	}
+	return true
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteText writes the report for people: a summary line per resource type, then + for added, - for removed and
+// ~ for changed resources with the fields that changed
+func (r DiffReport) WriteText(w io.Writer) error {
+	value := func(v interface{}) string {
+		if v == nil {
+			return "(none)"
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(b)
+	}
+
+	b := &strings.Builder{}
+	for _, d := range r.Kinds {
+		fmt.Fprintf(b, "%v: %d added, %d removed, %d changed, %d unchanged\n", d.Kind, len(d.Added), len(d.Removed), len(d.Changed), d.Unchanged)
+		for _, v := range d.Added {
+			fmt.Fprintf(b, "  + %v\n", v)
+		}
+		for _, v := range d.Removed {
+			fmt.Fprintf(b, "  - %v\n", v)
+		}
+		for _, v := range d.Changed {
+			fmt.Fprintf(b, "  ~ %v\n", v.Name)
+			for _, f := range v.Fields {
+				fmt.Fprintf(b, "      %v: %v -> %v\n", f.Path, value(f.Old), value(f.New))
+			}
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report as JSON
+func (r DiffReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
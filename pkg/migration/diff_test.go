@@ -0,0 +1,168 @@
+package migrate
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// testJSON returns a JSON document as a generic value
+func testJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid test JSON %v: %v", s, err)
+	}
+	return v
+}
+
+func TestDiffValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     string
+		updated string
+		want    []FieldChange
+	}{
+		{
+			name:    "equal",
+			old:     `{"a":1,"b":{"c":[1,2]}}`,
+			updated: `{"b":{"c":[1,2]},"a":1}`,
+			want:    []FieldChange{},
+		},
+		{
+			name:    "changed value",
+			old:     `{"a":1,"b":"x"}`,
+			updated: `{"a":2,"b":"x"}`,
+			want:    []FieldChange{{Path: "a", Old: 1.0, New: 2.0}},
+		},
+		{
+			name:    "added and removed keys, in key order",
+			old:     `{"b":1,"c":true}`,
+			updated: `{"a":"new","b":1}`,
+			want:    []FieldChange{{Path: "a", New: "new"}, {Path: "c", Old: true}},
+		},
+		{
+			name:    "nested objects",
+			old:     `{"properties":{"container":"c1","description":"d"}}`,
+			updated: `{"properties":{"container":"c2","description":"d"}}`,
+			want:    []FieldChange{{Path: "properties.container", Old: "c1", New: "c2"}},
+		},
+		{
+			name:    "arrays element by element",
+			old:     `{"keys":[{"id":"k1"},{"id":"k2"}]}`,
+			updated: `{"keys":[{"id":"k1"},{"id":"k3"},{"id":"k4"}]}`,
+			want: []FieldChange{
+				{Path: "keys[1].id", Old: "k2", New: "k3"},
+				{Path: "keys[2]", New: map[string]interface{}{"id": "k4"}},
+			},
+		},
+		{
+			name:    "shorter array",
+			old:     `[1,2]`,
+			updated: `[1]`,
+			want:    []FieldChange{{Path: "[1]", Old: 2.0}},
+		},
+		{
+			name:    "type change",
+			old:     `{"a":{"b":1}}`,
+			updated: `{"a":[1]}`,
+			want:    []FieldChange{{Path: "a", Old: map[string]interface{}{"b": 1.0}, New: []interface{}{1.0}}},
+		},
+		{
+			name:    "null to value",
+			old:     `{"a":null}`,
+			updated: `{"a":"x"}`,
+			want:    []FieldChange{{Path: "a", New: "x"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffValues("", testJSON(t, tt.old), testJSON(t, tt.updated))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffValues = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripFields(t *testing.T) {
+	ignored := map[string]bool{"created": true, "systemData": true}
+	got := stripFields(testJSON(t, `{"name":"a1","systemData":{"x":1},"properties":{"created":"t","items":[{"created":"t","id":1}]}}`), ignored)
+	want := testJSON(t, `{"name":"a1","properties":{"items":[{"id":1}]}}`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stripFields = %v, want %v", got, want)
+	}
+}
+
+func TestSameResource(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string
+		resource string
+		want     bool
+	}{
+		{
+			name:     "equal",
+			existing: `{"name":"a1","properties":{"container":"c1"}}`,
+			resource: `{"name":"a1","properties":{"container":"c1"}}`,
+			want:     true,
+		},
+		{
+			name:     "fields only mk.io sets",
+			existing: `{"id":"/subscriptions/s/assets/a1","name":"a1","properties":{"container":"c1","storageAccountName":"sa","created":"2023-06-02T00:00:00Z"}}`,
+			resource: `{"id":"/subscriptions/x/assets/a1","name":"a1","properties":{"container":"c1","created":"2023-06-01T00:00:00Z"}}`,
+			want:     true,
+		},
+		{
+			name:     "changed field",
+			existing: `{"name":"a1","properties":{"container":"c1"}}`,
+			resource: `{"name":"a1","properties":{"container":"c2"}}`,
+		},
+		{
+			name:     "field missing in mk.io",
+			existing: `{"name":"a1","properties":{}}`,
+			resource: `{"name":"a1","properties":{"description":"d"}}`,
+		},
+		{
+			name:     "extra array element in mk.io",
+			existing: `{"name":"l1","properties":{"contentKeys":[{"id":"k1"},{"id":"k2"}]}}`,
+			resource: `{"name":"l1","properties":{"contentKeys":[{"id":"k1"}]}}`,
+		},
+		{
+			name:     "policy id set by mk.io",
+			existing: `{"name":"l1","properties":{"contentKeys":[{"id":"k1","policyName":"p","policyId":"x"}]}}`,
+			resource: `{"name":"l1","properties":{"contentKeys":[{"id":"k1","policyName":"p"}]}}`,
+			want:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameResource(testJSON(t, tt.existing), testJSON(t, tt.resource)); got != tt.want {
+				t.Errorf("sameResource = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// mk.io doesn't return the content keys of a StreamingLocator, so they can't tell it apart
+func TestSameResourceLocator(t *testing.T) {
+	existing := &armmediaservices.StreamingLocator{Name: to.Ptr("l1"), Properties: &armmediaservices.StreamingLocatorProperties{AssetName: to.Ptr("a1"), StreamingPolicyName: to.Ptr("sp1")}}
+	locator := &armmediaservices.StreamingLocator{Name: to.Ptr("l1"), Properties: &armmediaservices.StreamingLocatorProperties{
+		AssetName:           to.Ptr("a1"),
+		StreamingPolicyName: to.Ptr("sp1"),
+		ContentKeys:         []*armmediaservices.StreamingLocatorContentKey{{ID: to.Ptr("k1"), Value: to.Ptr("key one")}},
+	}}
+	if !sameResource(existing, locator) {
+		t.Errorf("a locator with content keys differs from the same locator read from mk.io")
+	}
+
+	locator.Properties.AssetName = to.Ptr("a2")
+	if sameResource(existing, locator) {
+		t.Errorf("a locator of another asset is the same")
+	}
+}
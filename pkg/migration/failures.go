@@ -0,0 +1,160 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// ImportFailure describes a resource that could not be imported into mk.io
+type ImportFailure struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// AssetName is the parent asset of an AssetFilter
+	AssetName  string `json:"assetName,omitempty"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	ErrorCode  string `json:"errorCode,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Hint explains what to do about errors retrying the import won't fix on its own
+	Hint string `json:"hint,omitempty"`
+}
+
+// newImportFailure creates an ImportFailure, pulling the HTTP status and error code out of a mk.io ResponseError
+func newImportFailure(kind string, assetName string, name string, err error) ImportFailure {
+	failure := ImportFailure{
+		Kind:      kind,
+		Name:      name,
+		AssetName: assetName,
+	}
+	if err != nil {
+		failure.Error = err.Error()
+	}
+
+	var respErr *mkiosdk.ResponseError
+	if errors.As(err, &respErr) {
+		failure.StatusCode = respErr.StatusCode
+		failure.ErrorCode = respErr.ErrorCode
+	}
+	failure.Hint = failureHint(err)
+	return failure
+}
+
+// failureHint returns the hint for a mk.io error, or "" if there is none
+func failureHint(err error) string {
+	switch {
+	case mkiosdk.IsUnauthorized(err):
+		return "mk.io rejected the token. Log in again or pass a new token"
+	case mkiosdk.IsThrottled(err):
+		return "mk.io kept throttling the requests. Lower --workers or --rate-limit"
+	case mkiosdk.IsConflict(err):
+		return "the resource is in use, or its state in mk.io doesn't allow the change"
+	}
+	return ""
+}
+
+// String returns the resource name. AssetFilters are prefixed with their asset
+func (f ImportFailure) String() string {
+	if f.AssetName != "" {
+		return fmt.Sprintf("%v/%v", f.AssetName, f.Name)
+	}
+	return f.Name
+}
+
+// FailureManifest is a machine readable list of the resources that failed during an import
+type FailureManifest struct {
+	// MigrationFile is the migration file the failed resources were read from
+	MigrationFile string          `json:"migrationFile"`
+	Time          time.Time       `json:"time"`
+	Failures      []ImportFailure `json:"failures"`
+}
+
+// WriteFailureManifest writes the failures of an import to a file
+func WriteFailureManifest(fileName string, migrationFile string, failures []ImportFailure) error {
+	manifest := FailureManifest{
+		MigrationFile: migrationFile,
+		Time:          time.Now().UTC(),
+		Failures:      failures,
+	}
+	if manifest.Failures == nil {
+		manifest.Failures = []ImportFailure{}
+	}
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal failure manifest: %v", err)
+	}
+
+	err = os.WriteFile(fileName, manifestBytes, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to write failure manifest %v: %v", fileName, err)
+	}
+	return nil
+}
+
+// ReadFailureManifest reads a failure manifest written by a previous import
+func ReadFailureManifest(fileName string) (FailureManifest, error) {
+	manifest := FailureManifest{}
+
+	manifestBytes, err := os.ReadFile(fileName)
+	if err != nil {
+		return manifest, fmt.Errorf("unable to read failure manifest %v: %v", fileName, err)
+	}
+
+	err = json.Unmarshal(manifestBytes, &manifest)
+	if err != nil {
+		return manifest, fmt.Errorf("unable to unmarshal failure manifest %v: %v", fileName, err)
+	}
+	return manifest, nil
+}
+
+// FilterFailures returns a copy of the migration contents reduced to the failed resources
+func (contents MigrationFileContents) FilterFailures(failures []ImportFailure) MigrationFileContents {
+	failed := map[string]bool{}
+	for _, f := range failures {
+		failed[checkpointKey(f.Kind, f.AssetName, f.Name)] = true
+	}
+
+	reduced := MigrationFileContents{}
+	for _, a := range contents.Assets {
+		if failed[checkpointKey(ASSETS, "", *a.Name)] {
+			reduced.Assets = append(reduced.Assets, a)
+		}
+	}
+	for assetName, filters := range contents.AssetFilters {
+		for _, af := range filters {
+			if failed[checkpointKey(ASSETFILTERS, assetName, *af.Name)] {
+				if reduced.AssetFilters == nil {
+					reduced.AssetFilters = map[string][]*armmediaservices.AssetFilter{}
+				}
+				reduced.AssetFilters[assetName] = append(reduced.AssetFilters[assetName], af)
+			}
+		}
+	}
+	for _, ckp := range contents.ContentKeyPolicies {
+		if failed[checkpointKey(CONTENTKEYPOLICIES, "", *ckp.Name)] {
+			reduced.ContentKeyPolicies = append(reduced.ContentKeyPolicies, ckp)
+		}
+	}
+	for _, se := range contents.StreamingEndpoints {
+		if failed[checkpointKey(STREAMINGENDPOINTS, "", *se.Name)] {
+			reduced.StreamingEndpoints = append(reduced.StreamingEndpoints, se)
+		}
+	}
+	for _, sl := range contents.StreamingLocators {
+		if failed[checkpointKey(STREAMINGLOCATORS, "", *sl.Name)] {
+			reduced.StreamingLocators = append(reduced.StreamingLocators, sl)
+		}
+	}
+	for _, sp := range contents.StreamingPolicies {
+		if failed[checkpointKey(STREAMINGPOLICIES, "", *sp.Name)] {
+			reduced.StreamingPolicies = append(reduced.StreamingPolicies, sp)
+		}
+	}
+
+	return reduced
+}
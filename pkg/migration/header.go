@@ -0,0 +1,247 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"sort"
+	"time"
+)
+
+// SchemaVersion is the version of the migration files written by this tool. Files from before the header was
+// introduced have no header and are version 1
+const SchemaVersion = 2
+
+// Source kinds recorded in the header
+const (
+	SourceAzure   = "azure"
+	SourceMkio    = "mkio"
+	SourceUnknown = "unknown"
+)
+
+// MigrationSource identifies where the resources of a migration file were exported from
+type MigrationSource struct {
+	Kind          string `json:"kind"`
+	Subscription  string `json:"subscription,omitempty"`
+	ResourceGroup string `json:"resourceGroup,omitempty"`
+	AccountName   string `json:"accountName,omitempty"`
+}
+
+// ExportFilters are the options that selected the exported resources
+type ExportFilters struct {
+	CreatedBefore string   `json:"createdBefore,omitempty"`
+	CreatedAfter  string   `json:"createdAfter,omitempty"`
+	Resources     []string `json:"resources,omitempty"`
+	// ChangedSince is set for incremental exports, which only have the resources created or modified since these
+	// times, per resource type
+	ChangedSince map[string]time.Time `json:"changedSince,omitempty"`
+}
+
+// MigrationFileHeader describes a migration file and how it was produced
+type MigrationFileHeader struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	ToolVersion   string          `json:"toolVersion,omitempty"`
+	Source        MigrationSource `json:"source"`
+	ExportTime    time.Time       `json:"exportTime"`
+	Filters       ExportFilters   `json:"filters"`
+	// Counts and Checksums are per resource type. The journal of a streaming migration has neither, as its
+	// contents aren't known when the header is written
+	Counts    map[string]int    `json:"counts,omitempty"`
+	Checksums map[string]string `json:"checksums,omitempty"`
+	// UpgradedFrom is the schema version the file had before it was upgraded
+	UpgradedFrom int `json:"upgradedFrom,omitempty"`
+	// Secrets is set if the secret fields of the resources were encrypted or redacted
+	Secrets *SecretsEnvelope `json:"secrets,omitempty"`
+}
+
+// Version returns the schema version of the contents
+func (contents MigrationFileContents) Version() int {
+	if contents.Header == nil {
+		return 1
+	}
+	return contents.Header.SchemaVersion
+}
+
+// SetHeader sets the header of the contents, with the counts and checksums of the resources in them
+func (contents *MigrationFileContents) SetHeader(header MigrationFileHeader) error {
+	checksums, err := contents.Checksums()
+	if err != nil {
+		return err
+	}
+	header.SchemaVersion = SchemaVersion
+	header.Counts = contents.Counts()
+	header.Checksums = checksums
+	contents.Header = &header
+	return nil
+}
+
+// Counts returns the number of resources of each type in the contents
+func (contents MigrationFileContents) Counts() map[string]int {
+	filters := 0
+	for _, v := range contents.AssetFilters {
+		filters += len(v)
+	}
+	return map[string]int{
+		ASSETS:             len(contents.Assets),
+		ASSETFILTERS:       filters,
+		CONTENTKEYPOLICIES: len(contents.ContentKeyPolicies),
+		STREAMINGENDPOINTS: len(contents.StreamingEndpoints),
+		STREAMINGLOCATORS:  len(contents.StreamingLocators),
+		STREAMINGPOLICIES:  len(contents.StreamingPolicies),
+	}
+}
+
+// Checksums returns a SHA-256 of the resources of each type in the contents. The resources are hashed in the order
+// they appear in the contents, Asset Filters by asset name, so both formats of the same file have the same checksums
+func (contents MigrationFileContents) Checksums() (map[string]string, error) {
+	checksums := map[string]string{}
+	var err error
+	sum := func(kind string, write func(h hash.Hash) error) {
+		if err != nil {
+			return
+		}
+		h := sha256.New()
+		if err = write(h); err != nil {
+			err = fmt.Errorf("unable to checksum %v: %v", kind, err)
+			return
+		}
+		checksums[kind] = "sha256:" + hex.EncodeToString(h.Sum(nil))
+	}
+	// Each resource is hashed as a line of JSON
+	hashResource := func(h hash.Hash, prefix string, resource interface{}) error {
+		b, err := json.Marshal(resource)
+		if err != nil {
+			return err
+		}
+		h.Write([]byte(prefix))
+		h.Write(b)
+		h.Write([]byte{'\n'})
+		return nil
+	}
+
+	sum(ASSETS, func(h hash.Hash) error {
+		for _, v := range contents.Assets {
+			if err := hashResource(h, "", v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	sum(ASSETFILTERS, func(h hash.Hash) error {
+		assetNames := make([]string, 0, len(contents.AssetFilters))
+		for assetName := range contents.AssetFilters {
+			assetNames = append(assetNames, assetName)
+		}
+		sort.Strings(assetNames)
+		for _, assetName := range assetNames {
+			for _, v := range contents.AssetFilters[assetName] {
+				if err := hashResource(h, assetName+"/", v); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	sum(CONTENTKEYPOLICIES, func(h hash.Hash) error {
+		for _, v := range contents.ContentKeyPolicies {
+			if err := hashResource(h, "", v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	sum(STREAMINGENDPOINTS, func(h hash.Hash) error {
+		for _, v := range contents.StreamingEndpoints {
+			if err := hashResource(h, "", v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	sum(STREAMINGLOCATORS, func(h hash.Hash) error {
+		for _, v := range contents.StreamingLocators {
+			if err := hashResource(h, "", v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	sum(STREAMINGPOLICIES, func(h hash.Hash) error {
+		for _, v := range contents.StreamingPolicies {
+			if err := hashResource(h, "", v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return checksums, err
+}
+
+// VerifyHeader compares the counts and checksums of the header with the resources in the contents.
+// Returns a description of every mismatch
+func (contents MigrationFileContents) VerifyHeader() ([]string, error) {
+	problems := []string{}
+	if contents.Header == nil {
+		return problems, nil
+	}
+
+	counts := contents.Counts()
+	if contents.Header.Counts != nil {
+		for _, kind := range ImportOrder {
+			if contents.Header.Counts[kind] != counts[kind] {
+				problems = append(problems, fmt.Sprintf("header counts %d %v, file has %d", contents.Header.Counts[kind], kind, counts[kind]))
+			}
+		}
+	}
+
+	if contents.Header.Checksums != nil {
+		checksums, err := contents.Checksums()
+		if err != nil {
+			return problems, err
+		}
+		for _, kind := range ImportOrder {
+			if contents.Header.Checksums[kind] != checksums[kind] {
+				problems = append(problems, fmt.Sprintf("checksum of %v doesn't match the header. The file was modified after export", kind))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// upgrades rewrite contents of a schema version to the next version
+var upgrades = map[int]func(contents *MigrationFileContents, toolVersion string, exportTime time.Time) error{
+	// Version 1 files have no header. Where they came from isn't known, the best guess for the export time is the
+	// time the file was written
+	1: func(contents *MigrationFileContents, toolVersion string, exportTime time.Time) error {
+		return contents.SetHeader(MigrationFileHeader{
+			ToolVersion: toolVersion,
+			Source:      MigrationSource{Kind: SourceUnknown},
+			ExportTime:  exportTime.UTC(),
+		})
+	},
+}
+
+// Upgrade rewrites contents of an older schema version to SchemaVersion. Returns the version the contents had
+func (contents *MigrationFileContents) Upgrade(toolVersion string, exportTime time.Time) (int, error) {
+	from := contents.Version()
+	if from > SchemaVersion {
+		return from, fmt.Errorf("schema version %d is newer than this tool supports (%d)", from, SchemaVersion)
+	}
+
+	for v := from; v < SchemaVersion; v++ {
+		upgrade, ok := upgrades[v]
+		if !ok {
+			return from, fmt.Errorf("no upgrade from schema version %d", v)
+		}
+		if err := upgrade(contents, toolVersion, exportTime); err != nil {
+			return from, fmt.Errorf("unable to upgrade from schema version %d: %v", v, err)
+		}
+	}
+	if from < SchemaVersion {
+		contents.Header.UpgradedFrom = from
+	}
+	return from, nil
+}
@@ -0,0 +1,279 @@
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// MigrationFileSchema is the JSON Schema of migration files of SchemaVersion
+//
+//go:embed schema/migration-file.schema.json
+var MigrationFileSchema []byte
+
+// schemaURL is the name the schema is compiled under. It isn't fetched
+const schemaURL = "migration-file.schema.json"
+
+// Severities of lint issues
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintIssue is a problem found in a migration file
+type LintIssue struct {
+	Severity string
+	// Location is the line of an NDJSON file, or the JSON pointer of the value in a JSON file
+	Location string
+	Message  string
+}
+
+func (i LintIssue) String() string {
+	if i.Location == "" {
+		return fmt.Sprintf("%v: %v", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%v: %v: %v", i.Severity, i.Location, i.Message)
+}
+
+// LintMigrationFile checks a migration file without importing it. The file is validated against
+// MigrationFileSchema, then its header is verified and the references between its resources are checked.
+// The returned error is only set if the file couldn't be checked at all
+func LintMigrationFile(ctx context.Context, fileName string) ([]LintIssue, error) {
+	format, err := ReadMigrationFileFormat(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft7
+	if err := compiler.AddResource(schemaURL, bytes.NewReader(MigrationFileSchema)); err != nil {
+		return nil, fmt.Errorf("unable to load migration file schema: %v", err)
+	}
+
+	var issues []LintIssue
+	if format == FormatNDJSON {
+		issues, err = lintNDJSONSchema(compiler, fileName)
+	} else {
+		issues, err = lintJSONSchema(compiler, fileName)
+	}
+	if err != nil {
+		return issues, err
+	}
+
+	contents := MigrationFileContents{}
+	// Secrets are checked as they are in the file, the checksums are of the encrypted values
+	if err := contents.ReadMigrationFile(ctx, fileName, nil); err != nil {
+		return append(issues, LintIssue{Severity: LintError, Message: err.Error()}), nil
+	}
+	if contents.Header != nil && contents.Header.Secrets != nil && contents.Header.Secrets.Mode == SecretsRedacted {
+		issues = append(issues, LintIssue{Severity: LintWarning, Location: "header", Message: "secrets were redacted, the file can't be imported"})
+	}
+	if contents.Version() < SchemaVersion {
+		issues = append(issues, LintIssue{
+			Severity: LintError,
+			Message:  fmt.Sprintf("schema version %d is older than %d, run migrate-file upgrade first", contents.Version(), SchemaVersion),
+		})
+	}
+
+	problems, err := contents.VerifyHeader()
+	if err != nil {
+		return issues, err
+	}
+	for _, v := range problems {
+		issues = append(issues, LintIssue{Severity: LintError, Location: "header", Message: v})
+	}
+
+	return append(issues, contents.lintReferences()...), nil
+}
+
+// lintJSONSchema validates a JSON migration file against the schema
+func lintJSONSchema(compiler *jsonschema.Compiler, fileName string) ([]LintIssue, error) {
+	schema, err := compiler.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile migration file schema: %v", err)
+	}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open migration file: %v: %v", fileName, err)
+	}
+	defer file.Close()
+
+	var doc interface{}
+	dec := json.NewDecoder(bufio.NewReader(file))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return []LintIssue{{Severity: LintError, Message: fmt.Sprintf("invalid JSON: %v", err)}}, nil
+	}
+
+	// Files without a header are older than the schema. There is no point listing everything that's different
+	if m, ok := doc.(map[string]interface{}); ok && m["Header"] == nil {
+		return nil, nil
+	}
+	return schemaIssues("", schema.Validate(doc)), nil
+}
+
+// lintNDJSONSchema validates each line of an NDJSON migration file against the schema
+func lintNDJSONSchema(compiler *jsonschema.Compiler, fileName string) ([]LintIssue, error) {
+	schema, err := compiler.Compile(schemaURL + "#/definitions/ndjsonLine")
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile migration file schema: %v", err)
+	}
+
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open migration file: %v: %v", fileName, err)
+	}
+	defer file.Close()
+
+	issues := []LintIssue{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		location := fmt.Sprintf("line %d", line)
+
+		var doc interface{}
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			issues = append(issues, LintIssue{Severity: LintError, Location: location, Message: fmt.Sprintf("invalid JSON: %v", err)})
+			continue
+		}
+		// Files without a header are older than the schema, see lintJSONSchema
+		if line == 1 {
+			if m, ok := doc.(map[string]interface{}); ok && m["kind"] != NDJSONHeader {
+				return issues, nil
+			}
+		}
+		issues = append(issues, schemaIssues(location, schema.Validate(doc))...)
+	}
+	if err := scanner.Err(); err != nil {
+		return issues, fmt.Errorf("unable to read migration file %v: %v", fileName, err)
+	}
+	return issues, nil
+}
+
+// schemaIssues turns a schema validation error into an issue for each failed keyword
+func schemaIssues(location string, err error) []LintIssue {
+	if err == nil {
+		return nil
+	}
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []LintIssue{{Severity: LintError, Location: location, Message: err.Error()}}
+	}
+
+	issues := []LintIssue{}
+	var walk func(ve *jsonschema.ValidationError)
+	walk = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) == 0 {
+			where := strings.TrimSpace(location + " " + ve.InstanceLocation)
+			issues = append(issues, LintIssue{Severity: LintError, Location: where, Message: ve.Message})
+			return
+		}
+		for _, v := range ve.Causes {
+			walk(v)
+		}
+	}
+	walk(ve)
+	return issues
+}
+
+// lintReferences reports resources with the same name, and resources that use an Asset that isn't in the contents.
+// The Asset may already exist in mk.io, so the latter are only warnings
+func (contents MigrationFileContents) lintReferences() []LintIssue {
+	issues := []LintIssue{}
+	duplicates := func(kind string, names []string) {
+		seen := map[string]bool{}
+		for _, v := range names {
+			if seen[v] {
+				issues = append(issues, LintIssue{Severity: LintError, Location: kind, Message: fmt.Sprintf("%v appears more than once", v)})
+			}
+			seen[v] = true
+		}
+	}
+
+	assetNames := []string{}
+	inFile := map[string]bool{}
+	for _, v := range contents.Assets {
+		assetNames = append(assetNames, resourceName(v.Name))
+		inFile[resourceName(v.Name)] = true
+	}
+	duplicates(ASSETS, assetNames)
+
+	names := []string{}
+	for _, v := range contents.ContentKeyPolicies {
+		names = append(names, resourceName(v.Name))
+	}
+	duplicates(CONTENTKEYPOLICIES, names)
+
+	names = []string{}
+	for _, v := range contents.StreamingPolicies {
+		names = append(names, resourceName(v.Name))
+	}
+	duplicates(STREAMINGPOLICIES, names)
+
+	names = []string{}
+	for _, v := range contents.StreamingEndpoints {
+		names = append(names, resourceName(v.Name))
+	}
+	duplicates(STREAMINGENDPOINTS, names)
+
+	names = []string{}
+	for _, v := range contents.StreamingLocators {
+		names = append(names, resourceName(v.Name))
+		if v.Properties == nil || v.Properties.AssetName == nil {
+			continue
+		}
+		if len(contents.Assets) > 0 && !inFile[*v.Properties.AssetName] {
+			issues = append(issues, LintIssue{
+				Severity: LintWarning,
+				Location: STREAMINGLOCATORS,
+				Message:  fmt.Sprintf("%v uses asset %v, which isn't in the file", resourceName(v.Name), *v.Properties.AssetName),
+			})
+		}
+	}
+	duplicates(STREAMINGLOCATORS, names)
+
+	filterAssets := make([]string, 0, len(contents.AssetFilters))
+	for assetName := range contents.AssetFilters {
+		filterAssets = append(filterAssets, assetName)
+	}
+	sort.Strings(filterAssets)
+	for _, assetName := range filterAssets {
+		names = []string{}
+		for _, v := range contents.AssetFilters[assetName] {
+			names = append(names, resourceName(v.Name))
+		}
+		duplicates(ASSETFILTERS+" of "+assetName, names)
+		if !inFile[assetName] {
+			issues = append(issues, LintIssue{
+				Severity: LintWarning,
+				Location: ASSETFILTERS,
+				Message:  fmt.Sprintf("%d filters of asset %v, which isn't in the file", len(contents.AssetFilters[assetName]), assetName),
+			})
+		}
+	}
+	return issues
+}
+
+// resourceName returns the name of a resource, or an empty string if it has none
+func resourceName(name *string) string {
+	if name == nil {
+		return ""
+	}
+	return *name
+}
@@ -0,0 +1,274 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// Conflict policies of Merge, for resources with the same name but different contents
+const (
+	// MergeFail stops the merge
+	MergeFail = "fail"
+	// MergeFirst keeps the resource of the first file it appears in
+	MergeFirst = "first"
+	// MergeLast keeps the resource of the last file it appears in
+	MergeLast = "last"
+	// MergeNewest keeps the resource modified last. Resources without a time are kept from the last file
+	MergeNewest = "newest"
+)
+
+// MergePolicies are the conflict policies Merge accepts
+var MergePolicies = []string{MergeFail, MergeFirst, MergeLast, MergeNewest}
+
+// MergeConflict is a resource that appeared with different contents in more than one file
+type MergeConflict struct {
+	Kind string
+	Name string
+	// Kept is the index of the file the kept resource came from
+	Kept int
+}
+
+// mergeEntry is a resource kept by a merge so far
+type mergeEntry struct {
+	resource interface{}
+	file     int
+}
+
+// merger deduplicates the resources of a kind by name, in order of first appearance
+type merger struct {
+	policy    string
+	names     []string
+	entries   map[string]mergeEntry
+	conflicts []MergeConflict
+}
+
+func newMerger(policy string) *merger {
+	return &merger{policy: policy, entries: map[string]mergeEntry{}}
+}
+
+// add adds a resource from file. Identical duplicates are dropped, others are resolved with the policy
+func (m *merger) add(kind string, name string, resource interface{}, file int) error {
+	existing, ok := m.entries[name]
+	if !ok {
+		m.names = append(m.names, name)
+		m.entries[name] = mergeEntry{resource: resource, file: file}
+		return nil
+	}
+
+	a, err := json.Marshal(existing.resource)
+	if err != nil {
+		return fmt.Errorf("unable to compare %v %v: %v", kind, name, err)
+	}
+	b, err := json.Marshal(resource)
+	if err != nil {
+		return fmt.Errorf("unable to compare %v %v: %v", kind, name, err)
+	}
+	if string(a) == string(b) {
+		return nil
+	}
+
+	keep := existing
+	switch m.policy {
+	case MergeFail:
+		return fmt.Errorf("%v %v differs between file %d and file %d", kind, name, existing.file+1, file+1)
+	case MergeFirst:
+	case MergeLast:
+		keep = mergeEntry{resource: resource, file: file}
+	case MergeNewest:
+		if !modifiedTime(resource).Before(modifiedTime(existing.resource)) {
+			keep = mergeEntry{resource: resource, file: file}
+		}
+	default:
+		return fmt.Errorf("unknown conflict policy %q", m.policy)
+	}
+	m.entries[name] = keep
+	m.conflicts = append(m.conflicts, MergeConflict{Kind: kind, Name: name, Kept: keep.file})
+	return nil
+}
+
+// resources returns the kept resources in order of first appearance
+func (m *merger) resources() []interface{} {
+	resources := make([]interface{}, 0, len(m.names))
+	for _, v := range m.names {
+		resources = append(resources, m.entries[v].resource)
+	}
+	return resources
+}
+
+// modifiedTime returns when a resource was last changed, or created if that is all that's known
+func modifiedTime(resource interface{}) time.Time {
+	var t *time.Time
+	switch v := resource.(type) {
+	case *armmediaservices.Asset:
+		if v.Properties != nil {
+			t = v.Properties.LastModified
+			if t == nil {
+				t = v.Properties.Created
+			}
+		}
+	case *armmediaservices.AssetFilter:
+		if v.SystemData != nil {
+			t = v.SystemData.LastModifiedAt
+		}
+	case *armmediaservices.ContentKeyPolicy:
+		if v.Properties != nil {
+			t = v.Properties.LastModified
+		}
+	case *armmediaservices.StreamingEndpoint:
+		if v.Properties != nil {
+			t = v.Properties.LastModified
+		}
+	case *armmediaservices.StreamingLocator:
+		if v.Properties != nil {
+			t = v.Properties.Created
+		}
+	case *armmediaservices.StreamingPolicy:
+		if v.Properties != nil {
+			t = v.Properties.Created
+		}
+	}
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// Merge combines migration files into one. Resources are deduplicated by name, Asset Filters by asset and name.
+// Resources with the same name and different contents are resolved with policy. The header describes the merged
+// files: sources and filters they have in common, the latest export time and the counts of the result.
+// The files must not have protected secrets, decrypt them first
+func Merge(policy string, files ...MigrationFileContents) (MigrationFileContents, []MergeConflict, error) {
+	merged := MigrationFileContents{}
+	if len(files) == 0 {
+		return merged, nil, fmt.Errorf("nothing to merge")
+	}
+
+	assets := newMerger(policy)
+	filters := newMerger(policy)
+	ckps := newMerger(policy)
+	endpoints := newMerger(policy)
+	locators := newMerger(policy)
+	policies := newMerger(policy)
+	// Asset Filters are merged under "asset/filter"
+	filterAssets := map[string]string{}
+
+	for i, contents := range files {
+		if contents.Header != nil && contents.Header.Secrets != nil {
+			return merged, nil, fmt.Errorf("file %d has %v secrets, decrypt it before merging", i+1, contents.Header.Secrets.Mode)
+		}
+		for _, v := range contents.Assets {
+			if err := assets.add(ASSETS, resourceName(v.Name), v, i); err != nil {
+				return merged, nil, err
+			}
+		}
+		assetNames := make([]string, 0, len(contents.AssetFilters))
+		for assetName := range contents.AssetFilters {
+			assetNames = append(assetNames, assetName)
+		}
+		sort.Strings(assetNames)
+		for _, assetName := range assetNames {
+			for _, v := range contents.AssetFilters[assetName] {
+				key := assetName + "/" + resourceName(v.Name)
+				filterAssets[key] = assetName
+				if err := filters.add(ASSETFILTERS, key, v, i); err != nil {
+					return merged, nil, err
+				}
+			}
+		}
+		for _, v := range contents.ContentKeyPolicies {
+			if err := ckps.add(CONTENTKEYPOLICIES, resourceName(v.Name), v, i); err != nil {
+				return merged, nil, err
+			}
+		}
+		for _, v := range contents.StreamingEndpoints {
+			if err := endpoints.add(STREAMINGENDPOINTS, resourceName(v.Name), v, i); err != nil {
+				return merged, nil, err
+			}
+		}
+		for _, v := range contents.StreamingLocators {
+			if err := locators.add(STREAMINGLOCATORS, resourceName(v.Name), v, i); err != nil {
+				return merged, nil, err
+			}
+		}
+		for _, v := range contents.StreamingPolicies {
+			if err := policies.add(STREAMINGPOLICIES, resourceName(v.Name), v, i); err != nil {
+				return merged, nil, err
+			}
+		}
+	}
+
+	for _, v := range assets.resources() {
+		merged.Assets = append(merged.Assets, v.(*armmediaservices.Asset))
+	}
+	for _, key := range filters.names {
+		if merged.AssetFilters == nil {
+			merged.AssetFilters = map[string][]*armmediaservices.AssetFilter{}
+		}
+		assetName := filterAssets[key]
+		merged.AssetFilters[assetName] = append(merged.AssetFilters[assetName], filters.entries[key].resource.(*armmediaservices.AssetFilter))
+	}
+	for _, v := range ckps.resources() {
+		merged.ContentKeyPolicies = append(merged.ContentKeyPolicies, v.(*armmediaservices.ContentKeyPolicy))
+	}
+	for _, v := range endpoints.resources() {
+		merged.StreamingEndpoints = append(merged.StreamingEndpoints, v.(*armmediaservices.StreamingEndpoint))
+	}
+	for _, v := range locators.resources() {
+		merged.StreamingLocators = append(merged.StreamingLocators, v.(*armmediaservices.StreamingLocator))
+	}
+	for _, v := range policies.resources() {
+		merged.StreamingPolicies = append(merged.StreamingPolicies, v.(*armmediaservices.StreamingPolicy))
+	}
+
+	conflicts := []MergeConflict{}
+	for _, m := range []*merger{assets, filters, ckps, endpoints, locators, policies} {
+		conflicts = append(conflicts, m.conflicts...)
+	}
+
+	if err := merged.SetHeader(mergeHeaders(files)); err != nil {
+		return merged, conflicts, err
+	}
+	return merged, conflicts, nil
+}
+
+// mergeHeaders returns the header of merged files. What isn't the same in every file is left out
+func mergeHeaders(files []MigrationFileContents) MigrationFileHeader {
+	header := MigrationFileHeader{Source: MigrationSource{Kind: SourceUnknown}}
+	resources := map[string]bool{}
+	for i, contents := range files {
+		h := contents.Header
+		if h == nil {
+			h = &MigrationFileHeader{Source: MigrationSource{Kind: SourceUnknown}}
+		}
+		if i == 0 {
+			header.Source = h.Source
+			header.Filters.CreatedBefore = h.Filters.CreatedBefore
+			header.Filters.CreatedAfter = h.Filters.CreatedAfter
+		}
+		if h.Source != header.Source {
+			header.Source = MigrationSource{Kind: SourceUnknown}
+		}
+		if h.Filters.CreatedBefore != header.Filters.CreatedBefore {
+			header.Filters.CreatedBefore = ""
+		}
+		if h.Filters.CreatedAfter != header.Filters.CreatedAfter {
+			header.Filters.CreatedAfter = ""
+		}
+		if h.ExportTime.After(header.ExportTime) {
+			header.ExportTime = h.ExportTime
+		}
+		for _, v := range h.Filters.Resources {
+			resources[v] = true
+		}
+	}
+	for _, v := range ImportOrder {
+		if resources[v] {
+			header.Filters.Resources = append(header.Filters.Resources, v)
+		}
+	}
+	return header
+}
@@ -0,0 +1,178 @@
+package migrate
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// testMergeAsset returns an asset in container, last modified on the given day of June 2023. Day 0 has no time
+func testMergeAsset(name string, container string, day int) *armmediaservices.Asset {
+	properties := &armmediaservices.AssetProperties{Container: to.Ptr(container)}
+	if day > 0 {
+		properties.LastModified = to.Ptr(time.Date(2023, 6, day, 0, 0, 0, 0, time.UTC))
+	}
+	return &armmediaservices.Asset{Name: to.Ptr(name), Properties: properties}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		files  [][]*armmediaservices.Asset
+		// containers are the containers of the merged assets, in order
+		containers []string
+		// conflicts are the files the conflicting assets were kept from
+		conflicts []int
+		wantErr   string
+	}{
+		{
+			name:       "identical duplicates are dropped",
+			policy:     MergeFail,
+			files:      [][]*armmediaservices.Asset{{testMergeAsset("a1", "c1", 1)}, {testMergeAsset("a1", "c1", 1), testMergeAsset("a2", "c2", 1)}},
+			containers: []string{"c1", "c2"},
+		},
+		{
+			name:    "fail",
+			policy:  MergeFail,
+			files:   [][]*armmediaservices.Asset{{testMergeAsset("a1", "c1", 1)}, {testMergeAsset("a1", "c2", 2)}},
+			wantErr: "assets a1 differs between file 1 and file 2",
+		},
+		{
+			name:       "first",
+			policy:     MergeFirst,
+			files:      [][]*armmediaservices.Asset{{testMergeAsset("a1", "c1", 2)}, {testMergeAsset("a2", "c3", 1), testMergeAsset("a1", "c2", 1)}},
+			containers: []string{"c1", "c3"},
+			conflicts:  []int{0},
+		},
+		{
+			name:       "last",
+			policy:     MergeLast,
+			files:      [][]*armmediaservices.Asset{{testMergeAsset("a1", "c1", 2)}, {testMergeAsset("a1", "c2", 1)}, {testMergeAsset("a1", "c3", 1)}},
+			containers: []string{"c3"},
+			conflicts:  []int{1, 2},
+		},
+		{
+			name:       "newest",
+			policy:     MergeNewest,
+			files:      [][]*armmediaservices.Asset{{testMergeAsset("a1", "c1", 2)}, {testMergeAsset("a1", "c2", 1)}},
+			containers: []string{"c1"},
+			conflicts:  []int{0},
+		},
+		{
+			name:       "newest without times keeps the last",
+			policy:     MergeNewest,
+			files:      [][]*armmediaservices.Asset{{testMergeAsset("a1", "c1", 0)}, {testMergeAsset("a1", "c2", 0)}},
+			containers: []string{"c2"},
+			conflicts:  []int{1},
+		},
+		{
+			name:    "unknown policy",
+			policy:  "random",
+			files:   [][]*armmediaservices.Asset{{testMergeAsset("a1", "c1", 1)}, {testMergeAsset("a1", "c2", 1)}},
+			wantErr: "unknown conflict policy \"random\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := []MigrationFileContents{}
+			for _, assets := range tt.files {
+				files = append(files, MigrationFileContents{Assets: assets})
+			}
+			merged, conflicts, err := Merge(tt.policy, files...)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("Merge error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Merge: %v", err)
+			}
+
+			containers := []string{}
+			for _, v := range merged.Assets {
+				containers = append(containers, *v.Properties.Container)
+			}
+			if strings.Join(containers, ",") != strings.Join(tt.containers, ",") {
+				t.Errorf("merged containers %v, want %v", containers, tt.containers)
+			}
+			if len(conflicts) != len(tt.conflicts) {
+				t.Fatalf("conflicts %+v, want kept from files %v", conflicts, tt.conflicts)
+			}
+			for i, c := range conflicts {
+				if c.Kind != ASSETS || c.Name != "a1" || c.Kept != tt.conflicts[i] {
+					t.Errorf("conflict %+v, want a1 kept from file %d", c, tt.conflicts[i])
+				}
+			}
+			if merged.Header == nil || merged.Header.Counts[ASSETS] != len(tt.containers) {
+				t.Errorf("merged header %+v doesn't count the merged assets", merged.Header)
+			}
+		})
+	}
+}
+
+func TestMergeRejectsProtectedSecrets(t *testing.T) {
+	sealed := MigrationFileContents{Header: &MigrationFileHeader{Secrets: &SecretsEnvelope{Mode: SecretsEncrypted}}}
+	_, _, err := Merge(MergeFail, MigrationFileContents{}, sealed)
+	if err == nil || err.Error() != "file 2 has encrypted secrets, decrypt it before merging" {
+		t.Errorf("Merge error = %v, want file 2 rejected", err)
+	}
+}
+
+// Merging the shards of a split gives back every resource of the file
+func TestMergeSplit(t *testing.T) {
+	contents := testSplitContents()
+	shards, err := contents.Split(SplitOptions{AssetsPerFile: 1})
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	files := []MigrationFileContents{}
+	for _, shard := range shards {
+		files = append(files, shard.Contents)
+	}
+	// Shards merged with the file they came from only have identical duplicates
+	files = append(files, contents)
+
+	merged, conflicts, err := Merge(MergeFail, files...)
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("conflicts %+v, want none", conflicts)
+	}
+
+	names := func(c MigrationFileContents) []string {
+		n := []string{}
+		for _, v := range c.Assets {
+			n = append(n, ASSETS+"/"+*v.Name)
+		}
+		for assetName, filters := range c.AssetFilters {
+			for _, v := range filters {
+				n = append(n, ASSETFILTERS+"/"+assetName+"/"+*v.Name)
+			}
+		}
+		for _, v := range c.StreamingLocators {
+			n = append(n, STREAMINGLOCATORS+"/"+*v.Name)
+		}
+		for _, v := range c.ContentKeyPolicies {
+			n = append(n, CONTENTKEYPOLICIES+"/"+*v.Name)
+		}
+		for _, v := range c.StreamingPolicies {
+			n = append(n, STREAMINGPOLICIES+"/"+*v.Name)
+		}
+		for _, v := range c.StreamingEndpoints {
+			n = append(n, STREAMINGENDPOINTS+"/"+*v.Name)
+		}
+		sort.Strings(n)
+		return n
+	}
+	if got, want := strings.Join(names(merged), ","), strings.Join(names(contents), ","); got != want {
+		t.Errorf("merged %v\nwant %v", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+	log "github.com/sirupsen/logrus"
+)
+
+// MkioServiceProvider holds the mk.io clients for a single subscription
+type MkioServiceProvider struct {
+	subscriptionName         string
+	assetsClient             *mkiosdk.AssetsClient
+	assetFiltersClient       *mkiosdk.AssetFiltersClient
+	streamingLocatorsClient  *mkiosdk.StreamingLocatorsClient
+	streamingEndpointsClient *mkiosdk.StreamingEndpointsClient
+	streamingPoliciesClient  *mkiosdk.StreamingPoliciesClient
+	contentKeyPoliciesClient *mkiosdk.ContentKeyPoliciesClient
+}
+
+// NewMkioServiceProvider logs into an mk.io subscription. The clients of all resource types are created with
+// options, so they share its retry policy, rate limiter and HTTP client. Pass nil to accept the default values
+func NewMkioServiceProvider(ctx context.Context, subscriptionName string, token string, apiEndpoint string, options *mkiosdk.ClientOptions) (*MkioServiceProvider, error) {
+	log.Infof("Logging into mk.io subscription %v", subscriptionName)
+
+	assetsClient, err := mkiosdk.NewAssetsClient(ctx, subscriptionName, token, apiEndpoint, options)
+	if err != nil {
+		return nil, fmt.Errorf("error creating mk.io Assets Client: %v", err)
+	}
+	assetFiltersClient, err := mkiosdk.NewAssetFiltersClient(ctx, subscriptionName, token, apiEndpoint, options)
+	if err != nil {
+		return nil, fmt.Errorf("error creating mk.io Asset Filters Client: %v", err)
+	}
+	streamingPoliciesClient, err := mkiosdk.NewStreamingPoliciesClient(ctx, subscriptionName, token, apiEndpoint, options)
+	if err != nil {
+		return nil, fmt.Errorf("error creating mk.io StreamingPolicies Client: %v", err)
+	}
+	streamingLocatorsClient, err := mkiosdk.NewStreamingLocatorsClient(ctx, subscriptionName, token, apiEndpoint, options)
+	if err != nil {
+		return nil, fmt.Errorf("error creating mk.io StreamingLocators Client: %v", err)
+	}
+	streamingEndpointsClient, err := mkiosdk.NewStreamingEndpointsClient(ctx, subscriptionName, token, apiEndpoint, options)
+	if err != nil {
+		return nil, fmt.Errorf("error creating mk.io StreamingEndpoints Client: %v", err)
+	}
+	contentKeyPoliciesClient, err := mkiosdk.NewContentKeyPoliciesClient(ctx, subscriptionName, token, apiEndpoint, options)
+	if err != nil {
+		return nil, fmt.Errorf("error creating mk.io ContentKeyPolicies Client: %v", err)
+	}
+
+	return &MkioServiceProvider{
+		subscriptionName:         subscriptionName,
+		assetsClient:             assetsClient,
+		assetFiltersClient:       assetFiltersClient,
+		streamingLocatorsClient:  streamingLocatorsClient,
+		streamingEndpointsClient: streamingEndpointsClient,
+		streamingPoliciesClient:  streamingPoliciesClient,
+		contentKeyPoliciesClient: contentKeyPoliciesClient,
+	}, nil
+}
+
+// RetryStats returns the requests made so far by the client of each resource type and how many were retried
+func (m *MkioServiceProvider) RetryStats() map[string]mkiosdk.RetryStats {
+	return map[string]mkiosdk.RetryStats{
+		ASSETS:             m.assetsClient.RetryStats(),
+		ASSETFILTERS:       m.assetFiltersClient.RetryStats(),
+		STREAMINGLOCATORS:  m.streamingLocatorsClient.RetryStats(),
+		STREAMINGENDPOINTS: m.streamingEndpointsClient.RetryStats(),
+		STREAMINGPOLICIES:  m.streamingPoliciesClient.RetryStats(),
+		CONTENTKEYPOLICIES: m.contentKeyPoliciesClient.RetryStats(),
+	}
+}
+
+// ExportAssets implements SourceProvider
+func (m *MkioServiceProvider) ExportAssets(ctx context.Context, before string, after string) ([]*armmediaservices.Asset, error) {
+	return ExportMkAssets(ctx, m.assetsClient, before, after)
+}
+
+// StreamAssets implements SourceProvider
+func (m *MkioServiceProvider) StreamAssets(ctx context.Context, before string, after string, page func([]*armmediaservices.Asset) error) error {
+	log.Info("Exporting Assets")
+	err := m.assetsClient.ListAssets(ctx, before, after, page)
+	if err != nil {
+		return fmt.Errorf("encountered error while exporting assets from mk.io : %v", err)
+	}
+	return nil
+}
+
+// ExportAssetFilters implements SourceProvider. mk.io filters are looked up sequentially
+func (m *MkioServiceProvider) ExportAssetFilters(ctx context.Context, assets []*armmediaservices.Asset, workers int) (map[string][]*armmediaservices.AssetFilter, error) {
+	return ExportMkAssetFilters(ctx, m.assetFiltersClient, assets)
+}
+
+// ExportStreamingPolicies implements SourceProvider
+func (m *MkioServiceProvider) ExportStreamingPolicies(ctx context.Context, before string, after string) ([]*armmediaservices.StreamingPolicy, error) {
+	return ExportMkStreamingPolicies(ctx, m.streamingPoliciesClient, before, after)
+}
+
+// ExportStreamingLocators implements SourceProvider
+func (m *MkioServiceProvider) ExportStreamingLocators(ctx context.Context, before string, after string) ([]*armmediaservices.StreamingLocator, error) {
+	return ExportMkStreamingLocators(ctx, m.streamingLocatorsClient, before, after)
+}
+
+// StreamStreamingLocators implements SourceProvider
+func (m *MkioServiceProvider) StreamStreamingLocators(ctx context.Context, before string, after string, page func([]*armmediaservices.StreamingLocator) error) error {
+	log.Info("Exporting Streaming Locators")
+	err := m.streamingLocatorsClient.ListStreamingLocators(ctx, before, after, page)
+	if err != nil {
+		return fmt.Errorf("encountered error while exporting StreamingLocators From mk.io: %v", err)
+	}
+	return nil
+}
+
+// ExportContentKeys implements SourceProvider. Unlike in Azure, mk.io returns the content keys with the StreamingLocators
+func (m *MkioServiceProvider) ExportContentKeys(ctx context.Context, streamingLocators []*armmediaservices.StreamingLocator, workers int) ([]*armmediaservices.StreamingLocator, error) {
+	return streamingLocators, nil
+}
+
+// ExportStreamingEndpoints implements SourceProvider
+func (m *MkioServiceProvider) ExportStreamingEndpoints(ctx context.Context) ([]*armmediaservices.StreamingEndpoint, error) {
+	return ExportMkStreamingEndpoints(ctx, m.streamingEndpointsClient)
+}
+
+// ExportContentKeyPolicies implements SourceProvider
+func (m *MkioServiceProvider) ExportContentKeyPolicies(ctx context.Context, before string, after string) ([]*armmediaservices.ContentKeyPolicy, error) {
+	return ExportMkContentKeyPolicies(ctx, m.contentKeyPoliciesClient, before, after)
+}
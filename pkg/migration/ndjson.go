@@ -0,0 +1,284 @@
+package migrate
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// Migration file formats
+const FormatJSON = "json"
+const FormatNDJSON = "ndjson"
+
+// Kinds of the lines of an NDJSON migration file. The header, if any, is the first line
+const (
+	NDJSONHeader            = "header"
+	NDJSONAsset             = "asset"
+	NDJSONAssetFilter       = "assetFilter"
+	NDJSONContentKeyPolicy  = "contentKeyPolicy"
+	NDJSONStreamingEndpoint = "streamingEndpoint"
+	NDJSONStreamingLocator  = "streamingLocator"
+	NDJSONStreamingPolicy   = "streamingPolicy"
+)
+
+// ndjsonKinds maps resource types to the kind of their lines in an NDJSON migration file
+var ndjsonKinds = map[string]string{
+	ASSETS:             NDJSONAsset,
+	ASSETFILTERS:       NDJSONAssetFilter,
+	CONTENTKEYPOLICIES: NDJSONContentKeyPolicy,
+	STREAMINGENDPOINTS: NDJSONStreamingEndpoint,
+	STREAMINGLOCATORS:  NDJSONStreamingLocator,
+	STREAMINGPOLICIES:  NDJSONStreamingPolicy,
+}
+
+// NDJSONRecord is a single line of an NDJSON migration file
+type NDJSONRecord struct {
+	Kind string `json:"kind"`
+	// AssetName is the parent asset of an AssetFilter
+	AssetName string          `json:"assetName,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// NDJSONWriter writes resources as NDJSON migration file lines, one resource per line
+type NDJSONWriter struct {
+	w *bufio.Writer
+}
+
+// NewNDJSONWriter returns a writer for w. Call Flush when done
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes a resource of the given resource type. assetName is only used for AssetFilters
+func (n *NDJSONWriter) Write(kind string, assetName string, resource interface{}) error {
+	ndjsonKind, ok := ndjsonKinds[kind]
+	if !ok {
+		return fmt.Errorf("unknown resource type %v", kind)
+	}
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return fmt.Errorf("unable to marshal %v: %v", kind, err)
+	}
+	line, err := json.Marshal(NDJSONRecord{Kind: ndjsonKind, AssetName: assetName, Data: data})
+	if err != nil {
+		return fmt.Errorf("unable to marshal %v: %v", kind, err)
+	}
+	line = append(line, '\n')
+
+	_, err = n.w.Write(line)
+	return err
+}
+
+// WriteHeader writes the header line. It must be written before any resource
+func (n *NDJSONWriter) WriteHeader(header *MigrationFileHeader) error {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("unable to marshal header: %v", err)
+	}
+	line, err := json.Marshal(NDJSONRecord{Kind: NDJSONHeader, Data: data})
+	if err != nil {
+		return fmt.Errorf("unable to marshal header: %v", err)
+	}
+	line = append(line, '\n')
+
+	_, err = n.w.Write(line)
+	return err
+}
+
+// Flush writes any buffered lines to the underlying writer
+func (n *NDJSONWriter) Flush() error {
+	return n.w.Flush()
+}
+
+// NDJSONReader reads the resources of an NDJSON migration file one line at a time
+type NDJSONReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewNDJSONReader returns a reader for r
+func NewNDJSONReader(r io.Reader) *NDJSONReader {
+	scanner := bufio.NewScanner(r)
+	// StreamingLocators with content keys and ContentKeyPolicies with many options can be large
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &NDJSONReader{scanner: scanner}
+}
+
+// Read returns the resource type, parent asset name and resource of the next line. Blank lines are skipped.
+// The header line is returned as NDJSONHeader with a *MigrationFileHeader. Returns io.EOF after the last line
+func (n *NDJSONReader) Read() (string, string, interface{}, error) {
+	for n.scanner.Scan() {
+		n.line++
+		if len(bytes.TrimSpace(n.scanner.Bytes())) == 0 {
+			continue
+		}
+
+		record := NDJSONRecord{}
+		if err := json.Unmarshal(n.scanner.Bytes(), &record); err != nil {
+			return "", "", nil, fmt.Errorf("line %d: %v", n.line, err)
+		}
+
+		var resource interface{}
+		var kind string
+		switch record.Kind {
+		case NDJSONHeader:
+			if n.line != 1 {
+				return "", "", nil, fmt.Errorf("line %d: header must be the first line", n.line)
+			}
+			kind, resource = NDJSONHeader, &MigrationFileHeader{}
+		case NDJSONAsset:
+			kind, resource = ASSETS, &armmediaservices.Asset{}
+		case NDJSONAssetFilter:
+			kind, resource = ASSETFILTERS, &armmediaservices.AssetFilter{}
+			if record.AssetName == "" {
+				return "", "", nil, fmt.Errorf("line %d: assetFilter without assetName", n.line)
+			}
+		case NDJSONContentKeyPolicy:
+			kind, resource = CONTENTKEYPOLICIES, &armmediaservices.ContentKeyPolicy{}
+		case NDJSONStreamingEndpoint:
+			kind, resource = STREAMINGENDPOINTS, &armmediaservices.StreamingEndpoint{}
+		case NDJSONStreamingLocator:
+			kind, resource = STREAMINGLOCATORS, &armmediaservices.StreamingLocator{}
+		case NDJSONStreamingPolicy:
+			kind, resource = STREAMINGPOLICIES, &armmediaservices.StreamingPolicy{}
+		default:
+			return "", "", nil, fmt.Errorf("line %d: unknown kind %q", n.line, record.Kind)
+		}
+		if err := json.Unmarshal(record.Data, resource); err != nil {
+			return "", "", nil, fmt.Errorf("line %d: unable to unmarshal %v: %v", n.line, record.Kind, err)
+		}
+		return kind, record.AssetName, resource, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return "", "", nil, fmt.Errorf("line %d: %v", n.line+1, err)
+	}
+	return "", "", nil, io.EOF
+}
+
+// ReadNDJSON adds every resource read from r to the contents
+func (contents *MigrationFileContents) ReadNDJSON(r io.Reader) error {
+	reader := NewNDJSONReader(r)
+	for {
+		_, assetName, resource, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		contents.add(assetName, resource)
+	}
+}
+
+// add appends a single resource to the contents
+func (contents *MigrationFileContents) add(assetName string, resource interface{}) {
+	switch v := resource.(type) {
+	case *MigrationFileHeader:
+		contents.Header = v
+	case *armmediaservices.Asset:
+		contents.Assets = append(contents.Assets, v)
+	case *armmediaservices.AssetFilter:
+		if contents.AssetFilters == nil {
+			contents.AssetFilters = map[string][]*armmediaservices.AssetFilter{}
+		}
+		contents.AssetFilters[assetName] = append(contents.AssetFilters[assetName], v)
+	case *armmediaservices.ContentKeyPolicy:
+		contents.ContentKeyPolicies = append(contents.ContentKeyPolicies, v)
+	case *armmediaservices.StreamingEndpoint:
+		contents.StreamingEndpoints = append(contents.StreamingEndpoints, v)
+	case *armmediaservices.StreamingLocator:
+		contents.StreamingLocators = append(contents.StreamingLocators, v)
+	case *armmediaservices.StreamingPolicy:
+		contents.StreamingPolicies = append(contents.StreamingPolicies, v)
+	}
+}
+
+// WriteNDJSON writes the contents to w, one resource per line. Resources are written in ImportOrder so a reader
+// sees every resource before the resources that use it
+func (contents MigrationFileContents) WriteNDJSON(w io.Writer) error {
+	writer := NewNDJSONWriter(w)
+	var err error
+	if contents.Header != nil {
+		if err = writer.WriteHeader(contents.Header); err != nil {
+			return err
+		}
+	}
+	for _, kind := range ImportOrder {
+		switch kind {
+		case CONTENTKEYPOLICIES:
+			for _, v := range contents.ContentKeyPolicies {
+				if err = writer.Write(kind, "", v); err != nil {
+					return err
+				}
+			}
+		case ASSETS:
+			for _, v := range contents.Assets {
+				if err = writer.Write(kind, "", v); err != nil {
+					return err
+				}
+			}
+		case ASSETFILTERS:
+			// Keep the output stable so files can be diffed
+			assetNames := make([]string, 0, len(contents.AssetFilters))
+			for assetName := range contents.AssetFilters {
+				assetNames = append(assetNames, assetName)
+			}
+			sort.Strings(assetNames)
+			for _, assetName := range assetNames {
+				for _, v := range contents.AssetFilters[assetName] {
+					if err = writer.Write(kind, assetName, v); err != nil {
+						return err
+					}
+				}
+			}
+		case STREAMINGPOLICIES:
+			for _, v := range contents.StreamingPolicies {
+				if err = writer.Write(kind, "", v); err != nil {
+					return err
+				}
+			}
+		case STREAMINGLOCATORS:
+			for _, v := range contents.StreamingLocators {
+				if err = writer.Write(kind, "", v); err != nil {
+					return err
+				}
+			}
+		case STREAMINGENDPOINTS:
+			for _, v := range contents.StreamingEndpoints {
+				if err = writer.Write(kind, "", v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return writer.Flush()
+}
+
+// DetectFormat returns the format of a migration file by looking at the first key of its first JSON object.
+// NDJSON lines start with "kind", the single JSON object of the legacy format with a resource type
+func DetectFormat(r io.Reader) (string, error) {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return "", fmt.Errorf("migration file is empty")
+	}
+	if err != nil {
+		return "", err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return "", fmt.Errorf("migration file doesn't start with a JSON object")
+	}
+	tok, err = dec.Token()
+	if err != nil {
+		return "", err
+	}
+	if key, ok := tok.(string); ok && key == "kind" {
+		return FormatNDJSON, nil
+	}
+	return FormatJSON, nil
+}
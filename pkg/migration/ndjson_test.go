@@ -0,0 +1,162 @@
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// testContents returns migration file contents with one resource of every type. Asset a1 has a filter and a locator
+func testContents() MigrationFileContents {
+	created := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	return MigrationFileContents{
+		Assets: []*armmediaservices.Asset{
+			{Name: to.Ptr("a1"), Properties: &armmediaservices.AssetProperties{Container: to.Ptr("c1"), Created: to.Ptr(created)}},
+			{Name: to.Ptr("a2"), Properties: &armmediaservices.AssetProperties{Container: to.Ptr("c2"), Created: to.Ptr(created)}},
+		},
+		AssetFilters: map[string][]*armmediaservices.AssetFilter{
+			"a1": {{Name: to.Ptr("f1"), Properties: &armmediaservices.MediaFilterProperties{}}},
+		},
+		ContentKeyPolicies: []*armmediaservices.ContentKeyPolicy{
+			{Name: to.Ptr("ckp1"), Properties: &armmediaservices.ContentKeyPolicyProperties{Description: to.Ptr("policy")}},
+		},
+		StreamingEndpoints: []*armmediaservices.StreamingEndpoint{
+			{Name: to.Ptr("se1"), Location: to.Ptr("westeurope")},
+		},
+		StreamingLocators: []*armmediaservices.StreamingLocator{
+			{Name: to.Ptr("l1"), Properties: &armmediaservices.StreamingLocatorProperties{AssetName: to.Ptr("a1"), StreamingPolicyName: to.Ptr("sp1")}},
+		},
+		StreamingPolicies: []*armmediaservices.StreamingPolicy{
+			{Name: to.Ptr("sp1"), Properties: &armmediaservices.StreamingPolicyProperties{DefaultContentKeyPolicyName: to.Ptr("ckp1")}},
+		},
+	}
+}
+
+func TestNDJSONRoundTrip(t *testing.T) {
+	withHeader := testContents()
+	withHeader.Header = &MigrationFileHeader{
+		SchemaVersion: SchemaVersion,
+		ExportTime:    time.Date(2023, 2, 3, 4, 5, 6, 0, time.UTC),
+		Counts:        map[string]int{ASSETS: 2},
+	}
+
+	tests := []struct {
+		name     string
+		contents MigrationFileContents
+	}{
+		{name: "empty", contents: MigrationFileContents{}},
+		{name: "without header", contents: testContents()},
+		{name: "with header", contents: withHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := tt.contents.WriteNDJSON(buf); err != nil {
+				t.Fatalf("WriteNDJSON: %v", err)
+			}
+			format, err := DetectFormat(bytes.NewReader(buf.Bytes()))
+			if len(tt.contents.Assets) > 0 && (err != nil || format != FormatNDJSON) {
+				t.Errorf("DetectFormat = %q, %v, want %q", format, err, FormatNDJSON)
+			}
+
+			read := MigrationFileContents{}
+			if err := read.ReadNDJSON(bytes.NewReader(buf.Bytes())); err != nil {
+				t.Fatalf("ReadNDJSON: %v", err)
+			}
+			want, _ := json.Marshal(tt.contents)
+			got, _ := json.Marshal(read)
+			if !bytes.Equal(got, want) {
+				t.Errorf("read back\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestNDJSONWriteOrder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := testContents().WriteNDJSON(buf); err != nil {
+		t.Fatalf("WriteNDJSON: %v", err)
+	}
+
+	// Every resource comes after the resources it uses
+	seen := map[string]int{}
+	reader := NewNDJSONReader(buf)
+	for i := 0; ; i++ {
+		kind, _, _, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		if _, ok := seen[kind]; !ok {
+			seen[kind] = i
+		}
+	}
+	for _, order := range [][2]string{
+		{CONTENTKEYPOLICIES, STREAMINGPOLICIES},
+		{ASSETS, ASSETFILTERS},
+		{ASSETS, STREAMINGLOCATORS},
+		{STREAMINGPOLICIES, STREAMINGLOCATORS},
+	} {
+		if seen[order[0]] > seen[order[1]] {
+			t.Errorf("%v written after %v", order[0], order[1])
+		}
+	}
+}
+
+func TestNDJSONReadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:  "blank lines are skipped",
+			input: "\n{\"kind\":\"asset\",\"data\":{\"name\":\"a1\"}}\n\n",
+		},
+		{
+			name:    "header after a resource",
+			input:   "{\"kind\":\"asset\",\"data\":{}}\n{\"kind\":\"header\",\"data\":{}}\n",
+			wantErr: "line 2: header must be the first line",
+		},
+		{
+			name:    "asset filter without asset",
+			input:   "{\"kind\":\"assetFilter\",\"data\":{}}\n",
+			wantErr: "line 1: assetFilter without assetName",
+		},
+		{
+			name:    "unknown kind",
+			input:   "{\"kind\":\"job\",\"data\":{}}\n",
+			wantErr: "line 1: unknown kind \"job\"",
+		},
+		{
+			name:    "not JSON",
+			input:   "{\"kind\":\"asset\",\"data\":{}}\nnot json\n",
+			wantErr: "line 2:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contents := MigrationFileContents{}
+			err := contents.ReadNDJSON(strings.NewReader(tt.input))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ReadNDJSON: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("ReadNDJSON error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
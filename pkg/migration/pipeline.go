@@ -0,0 +1,469 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+	log "github.com/sirupsen/logrus"
+)
+
+// Operations reported in a Result
+const EXPORT = "export"
+const IMPORT = "import"
+
+// ImportOrder is the order resource types are imported in. Resources go after the resources they use
+var ImportOrder = []string{CONTENTKEYPOLICIES, ASSETS, ASSETFILTERS, STREAMINGPOLICIES, STREAMINGLOCATORS, STREAMINGENDPOINTS}
+
+// SourceProvider is a service resources can be exported from. Implemented by AzureServiceProvider and MkioServiceProvider
+type SourceProvider interface {
+	ExportAssets(ctx context.Context, before string, after string) ([]*armmediaservices.Asset, error)
+	ExportAssetFilters(ctx context.Context, assets []*armmediaservices.Asset, workers int) (map[string][]*armmediaservices.AssetFilter, error)
+	ExportStreamingPolicies(ctx context.Context, before string, after string) ([]*armmediaservices.StreamingPolicy, error)
+	ExportStreamingLocators(ctx context.Context, before string, after string) ([]*armmediaservices.StreamingLocator, error)
+	ExportContentKeys(ctx context.Context, streamingLocators []*armmediaservices.StreamingLocator, workers int) ([]*armmediaservices.StreamingLocator, error)
+	ExportStreamingEndpoints(ctx context.Context) ([]*armmediaservices.StreamingEndpoint, error)
+	ExportContentKeyPolicies(ctx context.Context, before string, after string) ([]*armmediaservices.ContentKeyPolicy, error)
+
+	// StreamAssets and StreamStreamingLocators pass the resources to page as they are read instead of collecting them
+	StreamAssets(ctx context.Context, before string, after string, page func([]*armmediaservices.Asset) error) error
+	StreamStreamingLocators(ctx context.Context, before string, after string, page func([]*armmediaservices.StreamingLocator) error) error
+}
+
+// ResourceKinds selects the resource types a Pipeline works on
+type ResourceKinds struct {
+	Assets             bool
+	AssetFilters       bool
+	ContentKeyPolicies bool
+	StreamingLocators  bool
+	StreamingEndpoints bool
+	StreamingPolicies  bool
+}
+
+// Result contains the statistics of one operation on one resource type
+type Result struct {
+	Resource  string
+	Operation string
+	Duration  time.Duration
+	Failures  []ImportFailure
+	Skipped   int
+	Migrated  int
+	// Err is set if the whole operation failed, e.g. an export that couldn't list the resources
+	Err error
+	// Retries counts the mk.io requests of the operation that had to be retried
+	Retries int
+}
+
+// Pipeline is shared by the export, import and validate stages of a migration
+type Pipeline struct {
+	// Source is only needed to export
+	Source SourceProvider
+	// Destination is only needed to import and validate
+	Destination *MkioServiceProvider
+	Kinds       ResourceKinds
+
+	// Export filters
+	CreatedBefore string
+	CreatedAfter  string
+	// ChangedSince exports only the resources created or modified since the time of their type, see
+	// MigrationFileContents.ChangedSince. Nil exports everything
+	ChangedSince map[string]time.Time
+
+	Workers                  int
+	Overwrite                bool
+	FairplayAmsCompatibility bool
+	// OverwriteChanged overwrites the resources of the types with a ChangedSince watermark, as if Overwrite was set
+	// for them. Types without one haven't been migrated yet, so what mk.io has of them is left alone
+	OverwriteChanged bool
+	// SkipUnchanged leaves the resources mk.io already has with the same contents alone, even with Overwrite
+	SkipUnchanged bool
+	// UnsupportedCdn is what import does with StreamingEndpoints whose CDN provider mk.io doesn't support. The zero
+	// value fails them
+	UnsupportedCdn CdnPolicy
+	// ConfirmCdn asks whether to change the CDN provider of a StreamingEndpoint to Akamai, for CdnPrompt. The
+	// Pipeline never reads input itself
+	ConfirmCdn func(name string, provider string) bool
+	// Transformations are applied to the contents before they are imported. May be nil
+	Transformations *Transformations
+	// Checkpoint records import progress. May be nil
+	Checkpoint *CheckpointStore
+	// Snapshots keeps a copy of every resource before it is overwritten. May be nil
+	Snapshots *SnapshotStore
+}
+
+// Export reads the selected resources from the Source
+func (p *Pipeline) Export(ctx context.Context) (MigrationFileContents, []Result, error) {
+	contents := MigrationFileContents{}
+	timings := []Result{}
+
+	if p.Source == nil {
+		return contents, timings, fmt.Errorf("export Error: no source to export from")
+	}
+	// A couple of simple checks to avoid bad things
+	if p.Kinds.AssetFilters && !p.Kinds.Assets {
+		return contents, timings, fmt.Errorf("AssetFilter export requires Asset export")
+	}
+	retries := retryStats(p.Source)
+
+	// Handle Assets
+	if p.Kinds.Assets {
+		start := time.Now()
+		assetList, err := p.Source.ExportAssets(ctx, p.CreatedBefore, p.CreatedAfter)
+		if err != nil {
+			log.Errorf("error exporting assets: %v", err)
+		}
+		contents.Assets = assetList
+		timings = append(timings, Result{Resource: ASSETS, Operation: EXPORT, Duration: time.Since(start), Migrated: len(assetList), Err: err})
+
+		// Handle Asset Filters -- Can only do this if we have a list of assets. The filters of unchanged Assets can
+		// change too, so they are read for every Asset and picked out by their own time below
+		if p.Kinds.AssetFilters {
+			start = time.Now()
+			assetFiltersList, err := p.Source.ExportAssetFilters(ctx, assetList, p.Workers)
+			if err != nil {
+				log.Errorf("error exporting asset filters: %v", err)
+			}
+			count := 0
+			// How many did we export?
+			for _, v := range assetFiltersList {
+				count = count + len(v)
+			}
+			contents.AssetFilters = assetFiltersList
+			timings = append(timings, Result{Resource: ASSETFILTERS, Operation: EXPORT, Duration: time.Since(start), Migrated: count, Err: err})
+		}
+	}
+
+	// Handle Streaming Policies. These are used by StreamingLocators, so do it first
+	if p.Kinds.StreamingPolicies {
+		start := time.Now()
+		sp, err := p.Source.ExportStreamingPolicies(ctx, p.CreatedBefore, p.createdAfter(STREAMINGPOLICIES))
+		if err != nil {
+			log.Errorf("error exporting streaming policies: %v", err)
+		}
+		contents.StreamingPolicies = sp
+		timings = append(timings, Result{Resource: STREAMINGPOLICIES, Operation: EXPORT, Duration: time.Since(start), Migrated: len(sp), Err: err})
+	}
+
+	// Handle StreamingLocators.
+	if p.Kinds.StreamingLocators {
+		start := time.Now()
+		streamingLocatorsList, err := p.Source.ExportStreamingLocators(ctx, p.CreatedBefore, p.createdAfter(STREAMINGLOCATORS))
+		if err != nil {
+			log.Errorf("error exporting streaming locators: %v", err)
+		}
+		// Only the Content Keys of changed StreamingLocators are looked up
+		if p.ChangedSince != nil {
+			streamingLocatorsList = MigrationFileContents{StreamingLocators: streamingLocatorsList}.ChangedSince(p.ChangedSince).StreamingLocators
+		}
+		timings = append(timings, Result{Resource: STREAMINGLOCATORS, Operation: EXPORT, Duration: time.Since(start), Migrated: len(streamingLocatorsList), Err: err})
+
+		start = time.Now()
+		streamingLocatorsList, err = p.Source.ExportContentKeys(ctx, streamingLocatorsList, p.Workers)
+		if err != nil {
+			log.Errorf("error exporting streaming locators Content Keys: %v", err)
+		}
+		// Without their keys mk.io would generate new ones
+		streamingLocatorsList = withoutFailedContentKeys(streamingLocatorsList, err)
+		timings = append(timings, Result{Resource: CONTENTKEYS, Operation: EXPORT, Duration: time.Since(start), Migrated: len(streamingLocatorsList), Err: err})
+
+		contents.StreamingLocators = streamingLocatorsList
+	}
+
+	// Handle StreamingEndpoints.
+	if p.Kinds.StreamingEndpoints {
+		start := time.Now()
+		se, err := p.Source.ExportStreamingEndpoints(ctx)
+		if err != nil {
+			log.Errorf("error exporting streaming endpoints: %v", err)
+		}
+		contents.StreamingEndpoints = se
+		timings = append(timings, Result{Resource: STREAMINGENDPOINTS, Operation: EXPORT, Duration: time.Since(start), Migrated: len(se), Err: err})
+	}
+
+	// Handle ContentKeyPolicies.
+	if p.Kinds.ContentKeyPolicies {
+		start := time.Now()
+		ckp, err := p.Source.ExportContentKeyPolicies(ctx, p.CreatedBefore, p.CreatedAfter)
+		if err != nil {
+			log.Errorf("error exporting content key policies: %v", err)
+		}
+		contents.ContentKeyPolicies = ckp
+		timings = append(timings, Result{Resource: CONTENTKEYPOLICIES, Operation: EXPORT, Duration: time.Since(start), Migrated: len(ckp), Err: err})
+	}
+
+	// The sources can't filter on modification time, so the rest of the delta is picked out here
+	if p.ChangedSince != nil {
+		contents = contents.ChangedSince(p.ChangedSince)
+		counts := contents.Counts()
+		for i, v := range timings {
+			if v.Resource != CONTENTKEYS {
+				timings[i].Migrated = counts[v.Resource]
+			} else {
+				timings[i].Migrated = counts[STREAMINGLOCATORS]
+			}
+		}
+	}
+
+	addRetries(timings, EXPORT, retries, retryStats(p.Source))
+	return contents, timings, nil
+}
+
+// createdAfter returns the created-after filter to export a resource type with. The changes of StreamingLocators
+// and StreamingPolicies are their creation, so their watermark is sent to the source unless CreatedAfter is later
+func (p *Pipeline) createdAfter(kind string) string {
+	mark, ok := p.ChangedSince[kind]
+	// The other types change without being created again
+	if !ok || mark.IsZero() || (kind != STREAMINGLOCATORS && kind != STREAMINGPOLICIES) {
+		return p.CreatedAfter
+	}
+	if p.CreatedAfter != "" {
+		after, err := time.Parse("2006-01-02", p.CreatedAfter)
+		if err != nil {
+			after, err = time.Parse(time.RFC3339, p.CreatedAfter)
+		}
+		if err != nil || !mark.After(after) {
+			return p.CreatedAfter
+		}
+	}
+	return mark.UTC().Format(time.RFC3339Nano)
+}
+
+// overwrite returns true if the resources of kind that already exist in mk.io are replaced
+func (p *Pipeline) overwrite(kind string) bool {
+	if p.Overwrite {
+		return true
+	}
+	_, marked := p.ChangedSince[kind]
+	return p.OverwriteChanged && marked
+}
+
+// importKinds returns the selected resource types in ImportOrder
+func (p *Pipeline) importKinds() []string {
+	selected := map[string]bool{
+		CONTENTKEYPOLICIES: p.Kinds.ContentKeyPolicies,
+		ASSETS:             p.Kinds.Assets,
+		ASSETFILTERS:       p.Kinds.AssetFilters,
+		STREAMINGPOLICIES:  p.Kinds.StreamingPolicies,
+		STREAMINGLOCATORS:  p.Kinds.StreamingLocators,
+		STREAMINGENDPOINTS: p.Kinds.StreamingEndpoints,
+	}
+	kinds := []string{}
+	for _, kind := range ImportOrder {
+		if selected[kind] {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// Import writes the selected resources into the Destination. Each resource is imported as soon as the resources
+// it uses are: Asset Filters after their Asset, StreamingPolicies after their ContentKeyPolicy and StreamingLocators
+// after their Asset, StreamingPolicy and ContentKeyPolicy
+func (p *Pipeline) Import(ctx context.Context, contents MigrationFileContents) ([]Result, error) {
+	if p.Destination == nil {
+		return []Result{}, fmt.Errorf("import Error: no mk.io subscription to import into")
+	}
+	// Importing the secret fields as they are in the file would break playback
+	if contents.Header != nil && contents.Header.Secrets != nil {
+		return []Result{}, fmt.Errorf("import Error: the secrets of the migration file are %v", contents.Header.Secrets.Mode)
+	}
+
+	p.Transformations.Apply(&contents)
+	retries := retryStats(p.Destination)
+
+	log.Info("Importing resources")
+	s := newImportScheduler(ctx, p.Workers, p.Checkpoint)
+
+	if p.Kinds.ContentKeyPolicies {
+		for _, ckp := range contents.ContentKeyPolicies {
+			s.Add(p.contentKeyPolicyNode(ctx, ckp))
+		}
+	}
+	if p.Kinds.Assets {
+		for _, asset := range contents.Assets {
+			s.Add(p.assetNode(ctx, asset))
+		}
+	}
+	if p.Kinds.AssetFilters {
+		for assetName, filters := range contents.AssetFilters {
+			for _, assetFilter := range filters {
+				s.Add(p.assetFilterNode(ctx, assetName, assetFilter))
+			}
+		}
+	}
+	if p.Kinds.StreamingPolicies {
+		for _, sp := range contents.StreamingPolicies {
+			s.Add(p.streamingPolicyNode(ctx, sp))
+		}
+	}
+	if p.Kinds.StreamingLocators {
+		for _, sl := range contents.StreamingLocators {
+			s.Add(p.streamingLocatorNode(ctx, sl))
+		}
+	}
+	if p.Kinds.StreamingEndpoints {
+		for _, se := range contents.StreamingEndpoints {
+			s.Add(p.streamingEndpointNode(ctx, se))
+		}
+	}
+
+	s.Close()
+	timings := s.Wait(p.importKinds())
+	addRetries(timings, IMPORT, retries, retryStats(p.Destination))
+	return timings, nil
+}
+
+func (p *Pipeline) contentKeyPolicyNode(ctx context.Context, ckp *armmediaservices.ContentKeyPolicy) *importNode {
+	fpCkp := toFPContentKeyPolicy(ckp, p.FairplayAmsCompatibility)
+	return &importNode{kind: CONTENTKEYPOLICIES, name: *ckp.Name, run: func() (bool, error) {
+		return importContentKeyPolicy(ctx, p.Destination.contentKeyPoliciesClient, fpCkp, p.overwrite(CONTENTKEYPOLICIES), p.SkipUnchanged, p.Checkpoint, p.Snapshots)
+	}}
+}
+
+func (p *Pipeline) assetNode(ctx context.Context, asset *armmediaservices.Asset) *importNode {
+	return &importNode{kind: ASSETS, name: *asset.Name, run: func() (bool, error) {
+		return importAsset(ctx, p.Destination.assetsClient, asset, p.overwrite(ASSETS), p.SkipUnchanged, p.Checkpoint, p.Snapshots)
+	}}
+}
+
+// Asset Filters require their asset
+func (p *Pipeline) assetFilterNode(ctx context.Context, assetName string, assetFilter *armmediaservices.AssetFilter) *importNode {
+	return &importNode{kind: ASSETFILTERS, assetName: assetName, name: *assetFilter.Name,
+		deps: []string{checkpointKey(ASSETS, "", assetName)},
+		run: func() (bool, error) {
+			return importAssetFilter(ctx, p.Destination.assetFiltersClient, assetName, assetFilter, p.overwrite(ASSETFILTERS), p.SkipUnchanged, p.Checkpoint, p.Snapshots)
+		}}
+}
+
+func (p *Pipeline) streamingPolicyNode(ctx context.Context, sp *armmediaservices.StreamingPolicy) *importNode {
+	return &importNode{kind: STREAMINGPOLICIES, name: *sp.Name, deps: streamingPolicyDeps(sp), run: func() (bool, error) {
+		return importStreamingPolicy(ctx, p.Destination.streamingPoliciesClient, sp, p.overwrite(STREAMINGPOLICIES), p.SkipUnchanged, p.Checkpoint, p.Snapshots)
+	}}
+}
+
+// StreamingLocators require their asset, StreamingPolicy and ContentKeyPolicy
+func (p *Pipeline) streamingLocatorNode(ctx context.Context, sl *armmediaservices.StreamingLocator) *importNode {
+	return &importNode{kind: STREAMINGLOCATORS, name: *sl.Name, deps: locatorDeps(sl), run: func() (bool, error) {
+		return importStreamingLocator(ctx, p.Destination.streamingLocatorsClient, sl, p.overwrite(STREAMINGLOCATORS), p.SkipUnchanged, p.Checkpoint, p.Snapshots)
+	}}
+}
+
+func (p *Pipeline) streamingEndpointNode(ctx context.Context, se *armmediaservices.StreamingEndpoint) *importNode {
+	return &importNode{kind: STREAMINGENDPOINTS, name: *se.Name, run: func() (bool, error) {
+		return importStreamingEndpoint(ctx, p.Destination.streamingEndpointsClient, se, p.overwrite(STREAMINGENDPOINTS), p.SkipUnchanged, p.unsupportedCdn, p.Checkpoint, p.Snapshots)
+	}}
+}
+
+// Validate checks that the imported StreamingLocators exist in the Destination and produce output
+func (p *Pipeline) Validate(ctx context.Context, contents MigrationFileContents) error {
+	if p.Destination == nil {
+		return fmt.Errorf("validation Error: no mk.io subscription to validate")
+	}
+	return ValidateStreamingLocators(ctx, p.Destination.streamingLocatorsClient, p.Destination.streamingEndpointsClient, contents.StreamingLocators)
+}
+
+// retryStats returns the retry statistics of the mk.io clients of provider by resource type. Azure retries on its
+// own, so other providers have none
+func retryStats(provider SourceProvider) map[string]mkiosdk.RetryStats {
+	m, ok := provider.(*MkioServiceProvider)
+	if !ok || m == nil {
+		return nil
+	}
+	return m.RetryStats()
+}
+
+// addRetries sets the Retries of the results of operation to the retries made for their resource type between the
+// before and after statistics
+func addRetries(timings []Result, operation string, before map[string]mkiosdk.RetryStats, after map[string]mkiosdk.RetryStats) {
+	for i, v := range timings {
+		if v.Operation == operation {
+			timings[i].Retries = int(after[v.Resource].Retries - before[v.Resource].Retries)
+		}
+	}
+}
+
+// Failures returns the import failures of all results
+func Failures(timings []Result) []ImportFailure {
+	failures := []ImportFailure{}
+	for _, v := range timings {
+		if v.Operation == IMPORT {
+			failures = append(failures, v.Failures...)
+		}
+	}
+	return failures
+}
+
+// errNothingToDo is returned by a resourceJob that found nothing to do. It is counted as skipped
+var errNothingToDo = errors.New("nothing to do")
+
+// resourceJob is an operation on a single resource run by runResourceJobs
+type resourceJob struct {
+	assetName string
+	name      string
+	run       func() error
+}
+
+// resourceJobWorker - Do the work of resource jobs
+func resourceJobWorker(kind string, wg *sync.WaitGroup, jobs <-chan resourceJob, successChan chan<- string, skippedChan chan<- string, failedChan chan<- ImportFailure) {
+	for job := range jobs {
+		err := job.run()
+		if errors.Is(err, errNothingToDo) {
+			skippedChan <- job.name
+		} else if err != nil {
+			log.Errorf("unable to %v %v: %v", kind, job.name, err)
+			failedChan <- newImportFailure(kind, job.assetName, job.name, err)
+		} else {
+			successChan <- job.name
+		}
+		wg.Done()
+	}
+}
+
+// runResourceJobs runs the jobs of one resource type on a pool of workers and collects the statistics
+func runResourceJobs(kind string, operation string, jobList []resourceJob, workers int) Result {
+	start := time.Now()
+	if workers < 1 {
+		workers = 1
+	}
+
+	// Waitgroup to wait for all goroutines to finish
+	wg := new(sync.WaitGroup)
+
+	// Create channels to communicate between workers
+	successChan := make(chan string, len(jobList))
+	skippedChan := make(chan string, len(jobList))
+	failedChan := make(chan ImportFailure, len(jobList))
+	jobs := make(chan resourceJob, len(jobList))
+
+	for w := 1; w <= workers; w++ {
+		go resourceJobWorker(kind, wg, jobs, successChan, skippedChan, failedChan)
+	}
+	for _, job := range jobList {
+		wg.Add(1)
+		jobs <- job
+	}
+	wg.Wait()
+
+	close(jobs)
+	close(successChan)
+	close(skippedChan)
+	close(failedChan)
+
+	result := Result{Resource: kind, Operation: operation, Failures: []ImportFailure{}}
+	for range successChan {
+		result.Migrated++
+	}
+	for range skippedChan {
+		result.Skipped++
+	}
+	for f := range failedChan {
+		result.Failures = append(result.Failures, f)
+	}
+	result.Duration = time.Since(start)
+
+	return result
+}
@@ -0,0 +1,220 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
+	log "github.com/sirupsen/logrus"
+)
+
+// PlanAction is what an import would do with a single resource
+type PlanAction string
+
+const (
+	// ActionCreate - the resource does not exist in mk.io and would be created
+	ActionCreate PlanAction = "create"
+	// ActionSkip - the resource already exists, or was handled by a previous run, and would not be touched
+	ActionSkip PlanAction = "skip"
+	// ActionReplace - the resource exists and would be deleted and recreated because of overwrite
+	ActionReplace PlanAction = "replace"
+	// ActionUpdate - the resource exists and would be updated in place because of overwrite
+	ActionUpdate PlanAction = "update"
+	// ActionFail - the lookup failed, so import would fail the resource rather than guess whether it exists
+	ActionFail PlanAction = "fail"
+)
+
+// PlanActions lists the plan actions in the order they are reported
+var PlanActions = []PlanAction{ActionCreate, ActionUpdate, ActionReplace, ActionSkip, ActionFail}
+
+// PlanItem is the planned action for a single resource
+type PlanItem struct {
+	Kind      string     `json:"kind"`
+	AssetName string     `json:"assetName,omitempty"`
+	Name      string     `json:"name"`
+	Action    PlanAction `json:"action"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// String returns the name of the resource, prefixed with its asset for Asset Filters
+func (i PlanItem) String() string {
+	if i.AssetName != "" {
+		return i.AssetName + "/" + i.Name
+	}
+	return i.Name
+}
+
+// Plan lists what an import would do, without changing anything in mk.io
+type Plan struct {
+	Items []PlanItem `json:"items"`
+}
+
+// Totals counts the planned actions of each resource type
+func (p Plan) Totals() map[string]map[PlanAction]int {
+	totals := map[string]map[PlanAction]int{}
+	for _, item := range p.Items {
+		if totals[item.Kind] == nil {
+			totals[item.Kind] = map[PlanAction]int{}
+		}
+		totals[item.Kind][item.Action]++
+	}
+	return totals
+}
+
+// planJob is a single Get lookup. replace is set for resources mk.io can't update, which import deletes first
+type planJob struct {
+	kind      string
+	assetName string
+	name      string
+	replace   bool
+	get       func() error
+}
+
+// planWorker runs the Get lookups and decides the action import would take, mirroring the import workers
+func planWorker(overwrite func(kind string) bool, checkpoint *CheckpointStore, wg *sync.WaitGroup, jobs <-chan planJob, items chan<- PlanItem) {
+	for job := range jobs {
+		item := PlanItem{Kind: job.kind, AssetName: job.assetName, Name: job.name}
+
+		if checkpoint.Done(job.kind, job.assetName, job.name) {
+			item.Action = ActionSkip
+			item.Reason = "completed in a previous run"
+			items <- item
+			wg.Done()
+			continue
+		}
+
+		err := job.get()
+		found := err == nil
+
+		switch {
+		case err != nil && !mkiosdk.IsNotFound(err):
+			item.Action = ActionFail
+			item.Reason = fmt.Sprintf("lookup failed: %v", err)
+		case !found:
+			item.Action = ActionCreate
+		case !overwrite(job.kind):
+			item.Action = ActionSkip
+		case job.replace:
+			item.Action = ActionReplace
+		default:
+			item.Action = ActionUpdate
+		}
+		if item.Reason == "" && found {
+			item.Reason = "exists"
+		}
+		items <- item
+		wg.Done()
+	}
+}
+
+// Plan performs only the Get lookups an import would do and reports the action it would take for each resource.
+// Nothing is created or deleted.
+func (p *Pipeline) Plan(ctx context.Context, contents MigrationFileContents) (Plan, error) {
+	plan := Plan{}
+
+	if p.Destination == nil {
+		return plan, fmt.Errorf("plan Error: no mk.io subscription to plan against")
+	}
+	dest := p.Destination
+
+	// Build the lookups in the same order as Import
+	jobList := []planJob{}
+	if p.Kinds.ContentKeyPolicies {
+		for _, ckp := range contents.ContentKeyPolicies {
+			name := *ckp.Name
+			jobList = append(jobList, planJob{kind: CONTENTKEYPOLICIES, name: name, replace: true, get: func() error {
+				_, err := dest.contentKeyPoliciesClient.Get(ctx, name, nil)
+				return err
+			}})
+		}
+	}
+	if p.Kinds.Assets {
+		for _, asset := range contents.Assets {
+			name := *asset.Name
+			jobList = append(jobList, planJob{kind: ASSETS, name: name, get: func() error {
+				_, err := dest.assetsClient.Get(ctx, name, nil)
+				return err
+			}})
+		}
+	}
+	if p.Kinds.AssetFilters {
+		for assetName, filters := range contents.AssetFilters {
+			for _, assetFilter := range filters {
+				assetName, name := assetName, *assetFilter.Name
+				jobList = append(jobList, planJob{kind: ASSETFILTERS, assetName: assetName, name: name, get: func() error {
+					_, err := dest.assetFiltersClient.Get(ctx, assetName, name, nil)
+					return err
+				}})
+			}
+		}
+	}
+	if p.Kinds.StreamingPolicies {
+		for _, sp := range contents.StreamingPolicies {
+			name := *sp.Name
+			jobList = append(jobList, planJob{kind: STREAMINGPOLICIES, name: name, replace: true, get: func() error {
+				_, err := dest.streamingPoliciesClient.Get(ctx, name, nil)
+				return err
+			}})
+		}
+	}
+	if p.Kinds.StreamingLocators {
+		for _, sl := range contents.StreamingLocators {
+			name := *sl.Name
+			jobList = append(jobList, planJob{kind: STREAMINGLOCATORS, name: name, replace: true, get: func() error {
+				_, err := dest.streamingLocatorsClient.Get(ctx, name, nil)
+				return err
+			}})
+		}
+	}
+	if p.Kinds.StreamingEndpoints {
+		for _, se := range contents.StreamingEndpoints {
+			name := *se.Name
+			jobList = append(jobList, planJob{kind: STREAMINGENDPOINTS, name: name, replace: true, get: func() error {
+				_, err := dest.streamingEndpointsClient.Get(ctx, name, nil)
+				return err
+			}})
+		}
+	}
+
+	log.Infof("Planning import of %d resources", len(jobList))
+
+	// Waitgroup to wait for all goroutines to finish
+	wg := new(sync.WaitGroup)
+
+	// Create channels to communicate between workers
+	items := make(chan PlanItem, len(jobList))
+	jobs := make(chan planJob, len(jobList))
+
+	workers := p.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 1; w <= workers; w++ {
+		log.Debugf("Starting plan worker %d", w)
+		go planWorker(p.overwrite, p.Checkpoint, wg, jobs, items)
+	}
+
+	// Remember the position of each resource so the plan keeps the import order
+	order := map[string]int{}
+	for i, job := range jobList {
+		order[checkpointKey(job.kind, job.assetName, job.name)] = i
+		wg.Add(1)
+		jobs <- job
+	}
+
+	log.Info("Waiting for plan workers to finish")
+	wg.Wait()
+	close(jobs)
+	close(items)
+
+	for item := range items {
+		plan.Items = append(plan.Items, item)
+	}
+	sort.SliceStable(plan.Items, func(i, j int) bool {
+		return order[checkpointKey(plan.Items[i].Kind, plan.Items[i].AssetName, plan.Items[i].Name)] < order[checkpointKey(plan.Items[j].Kind, plan.Items[j].AssetName, plan.Items[j].Name)]
+	})
+
+	return plan, nil
+}
@@ -0,0 +1,99 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
+	log "github.com/sirupsen/logrus"
+)
+
+// ROLLBACK is the operation reported in a Result by Rollback
+const ROLLBACK = "rollback"
+
+// RollbackOrder is the order resources are deleted in. Dependents go before the resources they use
+var RollbackOrder = []string{STREAMINGLOCATORS, ASSETFILTERS, ASSETS, STREAMINGPOLICIES, CONTENTKEYPOLICIES, STREAMINGENDPOINTS}
+
+// RollbackEntries returns the journal entries of the resources an import created, grouped in RollbackOrder.
+// Resources that existed before the import, whether overwritten or not, are left out.
+func RollbackEntries(entries []CheckpointEntry) map[string][]CheckpointEntry {
+	created := map[string][]CheckpointEntry{}
+	for _, entry := range entries {
+		if entry.Status == StatusImported && entry.Action == ImportCreated {
+			created[entry.Kind] = append(created[entry.Kind], entry)
+		}
+	}
+	for _, list := range created {
+		sort.Slice(list, func(i, j int) bool {
+			return checkpointKey(list[i].Kind, list[i].AssetName, list[i].Name) < checkpointKey(list[j].Kind, list[j].AssetName, list[j].Name)
+		})
+	}
+	return created
+}
+
+// deleteResource deletes a single resource from the Destination
+func (p *Pipeline) deleteResource(ctx context.Context, kind string, assetName string, name string) error {
+	dest := p.Destination
+	var err error
+	switch kind {
+	case STREAMINGLOCATORS:
+		_, err = dest.streamingLocatorsClient.Delete(ctx, name, nil)
+	case ASSETFILTERS:
+		_, err = dest.assetFiltersClient.Delete(ctx, assetName, name, nil)
+	case ASSETS:
+		_, err = dest.assetsClient.Delete(ctx, name, nil)
+	case STREAMINGPOLICIES:
+		_, err = dest.streamingPoliciesClient.Delete(ctx, name, nil)
+	case CONTENTKEYPOLICIES:
+		_, err = dest.contentKeyPoliciesClient.Delete(ctx, name, nil)
+	case STREAMINGENDPOINTS:
+		_, err = dest.streamingEndpointsClient.Delete(ctx, name, nil)
+	default:
+		err = fmt.Errorf("unknown resource type %v", kind)
+	}
+	return err
+}
+
+// Rollback deletes the resources the journal of an import recorded as created, in RollbackOrder
+func (p *Pipeline) Rollback(ctx context.Context, entries []CheckpointEntry) ([]Result, error) {
+	timings := []Result{}
+
+	if p.Destination == nil {
+		return timings, fmt.Errorf("rollback Error: no mk.io subscription to roll back")
+	}
+	if err := CheckJournalSubscription(entries, p.Destination.subscriptionName); err != nil {
+		return timings, fmt.Errorf("rollback Error: %v", err)
+	}
+
+	retries := retryStats(p.Destination)
+	created := RollbackEntries(entries)
+	for _, kind := range RollbackOrder {
+		list := created[kind]
+		if len(list) == 0 {
+			continue
+		}
+		log.Infof("Rolling back %d %v", len(list), kind)
+
+		jobs := []resourceJob{}
+		for _, entry := range list {
+			entry := entry
+			jobs = append(jobs, resourceJob{assetName: entry.AssetName, name: entry.Name, run: func() error {
+				log.Debugf("Deleting %v from MKIO: %v", entry.Kind, entry.Name)
+				err := p.deleteResource(ctx, entry.Kind, entry.AssetName, entry.Name)
+				// Already gone, nothing to roll back
+				if mkiosdk.IsNotFound(err) {
+					return errNothingToDo
+				}
+				return err
+			}})
+		}
+		result := runResourceJobs(kind, ROLLBACK, jobs, p.Workers)
+		log.Infof("Deleted %d %v, %d already gone, %d failed", result.Migrated, kind, result.Skipped, len(result.Failures))
+
+		timings = append(timings, result)
+	}
+
+	addRetries(timings, ROLLBACK, retries, retryStats(p.Destination))
+	return timings, nil
+}
@@ -0,0 +1,117 @@
+package migrate
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRollbackEntries(t *testing.T) {
+	entries := []CheckpointEntry{
+		{Kind: ASSETS, Name: "a2", Status: StatusImported, Action: ImportCreated},
+		{Kind: ASSETS, Name: "a1", Status: StatusImported, Action: ImportCreated},
+		{Kind: ASSETS, Name: "a3", Status: StatusImported, Action: ImportOverwritten},
+		{Kind: ASSETS, Name: "a4", Status: StatusSkipped, Action: ImportExisting},
+		{Kind: ASSETS, Name: "a5", Status: StatusFailed},
+		{Kind: ASSETFILTERS, AssetName: "a1", Name: "f1", Status: StatusImported, Action: ImportCreated},
+		{Kind: STREAMINGLOCATORS, Name: "l1", Status: StatusImported, Action: ImportCreated},
+	}
+	created := RollbackEntries(entries)
+
+	want := map[string]string{ASSETS: "a1,a2", ASSETFILTERS: "a1/f1", STREAMINGLOCATORS: "l1"}
+	if len(created) != len(want) {
+		t.Errorf("rollback of %v, want %v", created, want)
+	}
+	for kind, names := range want {
+		got := []string{}
+		for _, entry := range created[kind] {
+			got = append(got, entry.String())
+		}
+		if strings.Join(got, ",") != names {
+			t.Errorf("rollback of %v = %v, want %v", kind, got, names)
+		}
+	}
+}
+
+func TestCheckJournalSubscription(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []CheckpointEntry
+		wantErr bool
+	}{
+		{name: "same subscription", entries: []CheckpointEntry{{Name: "a1", Subscription: "sub"}}},
+		{name: "recorded before subscriptions were", entries: []CheckpointEntry{{Name: "a1"}}},
+		{name: "other subscription", entries: []CheckpointEntry{{Name: "a1", Subscription: "sub"}, {Name: "a2", Subscription: "other"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckJournalSubscription(tt.entries, "sub"); (err != nil) != tt.wantErr {
+				t.Errorf("CheckJournalSubscription() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRollback(t *testing.T) {
+	var mu sync.Mutex
+	deleted := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodDelete {
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		path := strings.TrimPrefix(req.URL.Path, "/api/ams/sub/")
+		if strings.HasSuffix(path, "/gone") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		deleted = append(deleted, path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	destination, err := NewMkioServiceProvider(context.Background(), "sub", "token", srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewMkioServiceProvider: %v", err)
+	}
+	p := &Pipeline{Destination: destination, Workers: 1}
+
+	entries := []CheckpointEntry{
+		{Kind: CONTENTKEYPOLICIES, Name: "ckp1", Status: StatusImported, Action: ImportCreated, Subscription: "sub"},
+		{Kind: ASSETS, Name: "a1", Status: StatusImported, Action: ImportCreated, Subscription: "sub"},
+		{Kind: ASSETS, Name: "a2", Status: StatusImported, Action: ImportOverwritten, Subscription: "sub"},
+		{Kind: ASSETFILTERS, AssetName: "a1", Name: "f1", Status: StatusImported, Action: ImportCreated, Subscription: "sub"},
+		{Kind: STREAMINGLOCATORS, Name: "l1", Status: StatusImported, Action: ImportCreated, Subscription: "sub"},
+		{Kind: STREAMINGLOCATORS, Name: "gone", Status: StatusImported, Action: ImportCreated, Subscription: "sub"},
+	}
+	timings, err := p.Rollback(context.Background(), entries)
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+
+	// Resources go before the resources they use
+	want := "streamingLocators/l1,assets/a1/assetFilters/f1,assets/a1,contentKeyPolicies/ckp1"
+	if got := strings.Join(deleted, ","); got != want {
+		t.Errorf("deleted %v, want %v", got, want)
+	}
+	for _, r := range timings {
+		if len(r.Failures) > 0 {
+			t.Errorf("%v failures %+v", r.Resource, r.Failures)
+		}
+		if r.Resource == STREAMINGLOCATORS && (r.Migrated != 1 || r.Skipped != 1) {
+			t.Errorf("rolled back %d locators and %d already gone, want 1 and 1", r.Migrated, r.Skipped)
+		}
+	}
+
+	// A journal of another subscription isn't rolled back
+	entries[0].Subscription = "other"
+	if _, err := p.Rollback(context.Background(), entries); err == nil {
+		t.Errorf("rolled back the journal of another subscription")
+	}
+}
@@ -0,0 +1,343 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+	log "github.com/sirupsen/logrus"
+)
+
+// nodeState is where an importNode is in the scheduler
+type nodeState int
+
+const (
+	nodePending nodeState = iota
+	nodeRunning
+	nodeSucceeded
+	nodeFailed
+)
+
+// importNode is a single resource to import, together with the resources that must be in mk.io before it
+type importNode struct {
+	kind      string
+	assetName string
+	name      string
+	// deps are the checkpoint keys of the resources this one uses
+	deps []string
+	// run imports the resource. Returns true if it already existed and was skipped
+	run func() (bool, error)
+
+	state      nodeState
+	waiting    int
+	dependants []*importNode
+}
+
+func (n *importNode) key() string {
+	return checkpointKey(n.kind, n.assetName, n.name)
+}
+
+// String returns the resource type and name, prefixed with its asset for Asset Filters
+func (n *importNode) String() string {
+	if n.assetName != "" {
+		return n.kind + " " + n.assetName + "/" + n.name
+	}
+	return n.kind + " " + n.name
+}
+
+// importScheduler imports resources on a pool of workers as soon as the resources they depend on are imported,
+// instead of one resource type after the other. Dependants of a resource that failed are not imported and
+// fail as "blocked by" it.
+//
+// Resources can be added while others are being imported. A dependency that is never added is assumed to be in
+// mk.io already, so resources waiting on one start once Close is called.
+//
+// Once ctx is cancelled, resources that haven't started fail as interrupted. They aren't recorded in the checkpoint
+// store, so a resumed import picks them up.
+type importScheduler struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	wg   sync.WaitGroup
+	ctx  context.Context
+
+	checkpoint *CheckpointStore
+	nodes      map[string]*importNode
+	// missing holds the nodes waiting on a dependency that hasn't been added (yet)
+	missing map[string][]*importNode
+	queue   []*importNode
+	// unfinished counts the nodes added but not succeeded or failed
+	unfinished int
+	closed     bool
+	// interrupted counts the nodes that never started because ctx was cancelled
+	interrupted int
+
+	results map[string]*Result
+	// Duration of a resource type runs from its first start to its last finish
+	starts map[string]time.Time
+	ends   map[string]time.Time
+}
+
+// newImportScheduler starts a scheduler with the given number of workers
+func newImportScheduler(ctx context.Context, workers int, checkpoint *CheckpointStore) *importScheduler {
+	if workers < 1 {
+		workers = 1
+	}
+	s := &importScheduler{
+		ctx:        ctx,
+		checkpoint: checkpoint,
+		nodes:      map[string]*importNode{},
+		missing:    map[string][]*importNode{},
+		results:    map[string]*Result{},
+		starts:     map[string]time.Time{},
+		ends:       map[string]time.Time{},
+	}
+	s.cond = sync.NewCond(&s.mu)
+
+	for w := 1; w <= workers; w++ {
+		s.wg.Add(1)
+		go s.worker()
+	}
+	return s
+}
+
+// result returns the statistics of a resource type. Must be called with the lock held
+func (s *importScheduler) result(kind string) *Result {
+	r, ok := s.results[kind]
+	if !ok {
+		r = &Result{Resource: kind, Operation: IMPORT, Failures: []ImportFailure{}}
+		s.results[kind] = r
+	}
+	return r
+}
+
+// Add schedules a resource. It is imported once all its dependencies have been imported
+func (s *importScheduler) Add(n *importNode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := n.key()
+	if _, ok := s.nodes[key]; ok {
+		log.Warnf("ignoring duplicate %v", n)
+		return
+	}
+	s.nodes[key] = n
+	s.unfinished++
+	s.result(n.kind)
+
+	// Resources added earlier may be waiting for this one
+	n.dependants = append(n.dependants, s.missing[key]...)
+	delete(s.missing, key)
+
+	// Handled by a previous run. Counts as skipped, and its dependants can go ahead
+	if s.checkpoint.Done(n.kind, n.assetName, n.name) {
+		log.Debugf("Skipping %v completed in a previous run", n)
+		s.result(n.kind).Skipped++
+		s.finish(n, nodeSucceeded)
+		return
+	}
+
+	for _, dep := range n.deps {
+		d, ok := s.nodes[dep]
+		if !ok {
+			n.waiting++
+			s.missing[dep] = append(s.missing[dep], n)
+			continue
+		}
+		switch d.state {
+		case nodeSucceeded:
+		case nodeFailed:
+			s.block(n, d)
+			return
+		default:
+			n.waiting++
+			d.dependants = append(d.dependants, n)
+		}
+	}
+
+	if n.waiting == 0 {
+		s.dispatch(n)
+	}
+}
+
+// Close tells the scheduler no more resources will be added. Dependencies that were never added are assumed to exist
+func (s *importScheduler) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for dep, waiting := range s.missing {
+		log.Debugf("%v is not part of this import, assuming it exists in mk.io", dep)
+		for _, n := range waiting {
+			s.release(n)
+		}
+	}
+	s.missing = map[string][]*importNode{}
+	s.cond.Broadcast()
+}
+
+// Wait waits for all resources to be imported and returns the statistics of the given resource types
+func (s *importScheduler) Wait(kinds []string) []Result {
+	s.wg.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timings := []Result{}
+	for _, kind := range kinds {
+		r := *s.result(kind)
+		if start, ok := s.starts[kind]; ok {
+			r.Duration = s.ends[kind].Sub(start)
+		}
+		log.Infof("Imported %d %v, skipped %d, %d failed", r.Migrated, kind, r.Skipped, len(r.Failures))
+		timings = append(timings, r)
+	}
+	if s.interrupted > 0 {
+		log.Warnf("Import interrupted, %d resources were not started", s.interrupted)
+	}
+	return timings
+}
+
+// dispatch queues a node for the workers. Must be called with the lock held
+func (s *importScheduler) dispatch(n *importNode) {
+	n.state = nodeRunning
+	s.queue = append(s.queue, n)
+	s.cond.Signal()
+}
+
+// release marks one dependency of a pending node as satisfied. Must be called with the lock held
+func (s *importScheduler) release(n *importNode) {
+	if n.state != nodePending {
+		return
+	}
+	n.waiting--
+	if n.waiting == 0 {
+		s.dispatch(n)
+	}
+}
+
+// block fails a pending node because a dependency failed. Must be called with the lock held
+func (s *importScheduler) block(n *importNode, dep *importNode) {
+	if n.state != nodePending {
+		return
+	}
+	if s.ctx.Err() != nil {
+		s.interrupt(n)
+		return
+	}
+	err := fmt.Errorf("blocked by %v", dep)
+	log.Errorf("not importing %v: %v", n, err)
+	s.checkpoint.Record(n.kind, n.assetName, n.name, StatusFailed, "", err)
+	r := s.result(n.kind)
+	r.Failures = append(r.Failures, newImportFailure(n.kind, n.assetName, n.name, err))
+	s.finish(n, nodeFailed)
+}
+
+// interrupt fails a node that won't be started because the import was cancelled. Must be called with the lock held
+func (s *importScheduler) interrupt(n *importNode) {
+	log.Debugf("not importing %v: interrupted", n)
+	s.interrupted++
+	r := s.result(n.kind)
+	r.Failures = append(r.Failures, newImportFailure(n.kind, n.assetName, n.name, fmt.Errorf("interrupted: %v", s.ctx.Err())))
+	s.finish(n, nodeFailed)
+}
+
+// finish records the end state of a node and releases or blocks its dependants. Must be called with the lock held
+func (s *importScheduler) finish(n *importNode, state nodeState) {
+	n.state = state
+	// Only the state is needed from now on. Let the resource go
+	n.run = nil
+	s.unfinished--
+	for _, d := range n.dependants {
+		if state == nodeSucceeded {
+			s.release(d)
+		} else {
+			s.block(d, n)
+		}
+	}
+	n.dependants = nil
+	if s.unfinished == 0 {
+		s.cond.Broadcast()
+	}
+}
+
+// next waits for a node to import. Returns nil once everything has been imported
+func (s *importScheduler) next() *importNode {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for len(s.queue) == 0 {
+		if s.closed && s.unfinished == 0 {
+			return nil
+		}
+		s.cond.Wait()
+	}
+	n := s.queue[0]
+	s.queue = s.queue[1:]
+	if _, ok := s.starts[n.kind]; !ok {
+		s.starts[n.kind] = time.Now()
+	}
+	return n
+}
+
+// worker imports nodes until the scheduler is done
+func (s *importScheduler) worker() {
+	defer s.wg.Done()
+
+	for n := s.next(); n != nil; n = s.next() {
+		if s.ctx.Err() != nil {
+			s.mu.Lock()
+			s.interrupt(n)
+			s.ends[n.kind] = time.Now()
+			s.mu.Unlock()
+			continue
+		}
+		skipped, err := n.run()
+
+		s.mu.Lock()
+		r := s.result(n.kind)
+		if err != nil {
+			r.Failures = append(r.Failures, newImportFailure(n.kind, n.assetName, n.name, err))
+			s.finish(n, nodeFailed)
+		} else {
+			if skipped {
+				r.Skipped++
+			} else {
+				r.Migrated++
+			}
+			s.finish(n, nodeSucceeded)
+		}
+		s.ends[n.kind] = time.Now()
+		s.mu.Unlock()
+	}
+}
+
+// locatorDeps returns the checkpoint keys of the resources a StreamingLocator uses.
+// Predefined StreamingPolicies are built into mk.io and aren't imported
+func locatorDeps(sl *armmediaservices.StreamingLocator) []string {
+	deps := []string{}
+	if sl.Properties == nil {
+		return deps
+	}
+	if sl.Properties.AssetName != nil {
+		deps = append(deps, checkpointKey(ASSETS, "", *sl.Properties.AssetName))
+	}
+	if sl.Properties.StreamingPolicyName != nil && !strings.HasPrefix(*sl.Properties.StreamingPolicyName, "Predefined_") {
+		deps = append(deps, checkpointKey(STREAMINGPOLICIES, "", *sl.Properties.StreamingPolicyName))
+	}
+	if sl.Properties.DefaultContentKeyPolicyName != nil {
+		deps = append(deps, checkpointKey(CONTENTKEYPOLICIES, "", *sl.Properties.DefaultContentKeyPolicyName))
+	}
+	return deps
+}
+
+// streamingPolicyDeps returns the checkpoint keys of the resources a StreamingPolicy uses
+func streamingPolicyDeps(sp *armmediaservices.StreamingPolicy) []string {
+	deps := []string{}
+	if sp.Properties != nil && sp.Properties.DefaultContentKeyPolicyName != nil {
+		deps = append(deps, checkpointKey(CONTENTKEYPOLICIES, "", *sp.Properties.DefaultContentKeyPolicyName))
+	}
+	return deps
+}
@@ -0,0 +1,177 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testNode is a resource of a scheduler test. fail makes its import fail
+type testNode struct {
+	kind string
+	name string
+	deps []string
+	fail bool
+}
+
+func TestImportSchedulerOrdering(t *testing.T) {
+	asset := checkpointKey(ASSETS, "", "a1")
+	policy := checkpointKey(STREAMINGPOLICIES, "", "sp1")
+	ckp := checkpointKey(CONTENTKEYPOLICIES, "", "ckp1")
+
+	tests := []struct {
+		name  string
+		nodes []testNode
+		// done are the checkpoint keys a previous run completed
+		done []string
+		// ran are the resources imported, in no particular order
+		ran []string
+		// blocked are the resources that fail because of a dependency, and failed the ones whose import fails
+		blocked []string
+		failed  []string
+	}{
+		{
+			name: "dependants added first wait for their dependencies",
+			nodes: []testNode{
+				{kind: STREAMINGLOCATORS, name: "l1", deps: []string{asset, policy, ckp}},
+				{kind: STREAMINGPOLICIES, name: "sp1", deps: []string{ckp}},
+				{kind: ASSETS, name: "a1"},
+				{kind: CONTENTKEYPOLICIES, name: "ckp1"},
+			},
+			ran: []string{"l1", "sp1", "a1", "ckp1"},
+		},
+		{
+			name: "dependencies added first",
+			nodes: []testNode{
+				{kind: CONTENTKEYPOLICIES, name: "ckp1"},
+				{kind: STREAMINGPOLICIES, name: "sp1", deps: []string{ckp}},
+				{kind: ASSETS, name: "a1"},
+				{kind: STREAMINGLOCATORS, name: "l1", deps: []string{asset, policy}},
+			},
+			ran: []string{"ckp1", "sp1", "a1", "l1"},
+		},
+		{
+			name: "a failed dependency blocks its dependants transitively",
+			nodes: []testNode{
+				{kind: STREAMINGLOCATORS, name: "l1", deps: []string{asset, policy}},
+				{kind: STREAMINGPOLICIES, name: "sp1", deps: []string{ckp}},
+				{kind: ASSETS, name: "a1"},
+				{kind: CONTENTKEYPOLICIES, name: "ckp1", fail: true},
+			},
+			ran:     []string{"a1", "ckp1"},
+			failed:  []string{"ckp1"},
+			blocked: []string{"sp1", "l1"},
+		},
+		{
+			name: "a dependency added after it failed blocks straight away",
+			nodes: []testNode{
+				{kind: ASSETS, name: "a1", fail: true},
+				{kind: STREAMINGLOCATORS, name: "l1", deps: []string{asset}},
+			},
+			ran:     []string{"a1"},
+			failed:  []string{"a1"},
+			blocked: []string{"l1"},
+		},
+		{
+			name: "dependencies that are not part of the import are assumed to exist",
+			nodes: []testNode{
+				{kind: STREAMINGLOCATORS, name: "l1", deps: []string{asset, policy}},
+			},
+			ran: []string{"l1"},
+		},
+		{
+			name: "dependencies completed by a previous run release their dependants",
+			nodes: []testNode{
+				{kind: ASSETS, name: "a1"},
+				{kind: STREAMINGLOCATORS, name: "l1", deps: []string{asset}},
+			},
+			done: []string{asset},
+			ran:  []string{"l1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var checkpoint *CheckpointStore
+			if len(tt.done) > 0 {
+				checkpoint = &CheckpointStore{entries: map[string]CheckpointEntry{}}
+				for _, key := range tt.done {
+					checkpoint.entries[key] = CheckpointEntry{Status: StatusImported}
+				}
+			}
+
+			var mu sync.Mutex
+			// finished holds the checkpoint keys of the resources imported, in the order they finished
+			finished := []string{}
+			deps := map[string][]string{}
+			kinds := map[string]bool{}
+			s := newImportScheduler(context.Background(), 4, checkpoint)
+			for _, tn := range tt.nodes {
+				tn := tn
+				n := &importNode{kind: tn.kind, name: tn.name, deps: tn.deps}
+				deps[n.key()] = tn.deps
+				kinds[tn.kind] = true
+				n.run = func() (bool, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					finished = append(finished, checkpointKey(tn.kind, "", tn.name))
+					if tn.fail {
+						return false, fmt.Errorf("failed")
+					}
+					return false, nil
+				}
+				s.Add(n)
+			}
+			s.Close()
+			kindList := []string{}
+			for kind := range kinds {
+				kindList = append(kindList, kind)
+			}
+			timings := s.Wait(kindList)
+
+			position := map[string]int{}
+			for i, key := range finished {
+				position[key] = i
+			}
+			ran := map[string]bool{}
+			for _, key := range finished {
+				ran[key[strings.LastIndex(key, "/")+1:]] = true
+				for _, dep := range deps[key] {
+					if p, ok := position[dep]; ok && p > position[key] {
+						t.Errorf("%v was imported before its dependency %v", key, dep)
+					}
+				}
+			}
+			if len(ran) != len(tt.ran) {
+				t.Errorf("imported %v, want %v", finished, tt.ran)
+			}
+			for _, name := range tt.ran {
+				if !ran[name] {
+					t.Errorf("%v was not imported", name)
+				}
+			}
+
+			failures := map[string]string{}
+			for _, r := range timings {
+				for _, f := range r.Failures {
+					failures[f.Name] = f.Error
+				}
+			}
+			if len(failures) != len(tt.failed)+len(tt.blocked) {
+				t.Errorf("failures %v, want %v failed and %v blocked", failures, tt.failed, tt.blocked)
+			}
+			for _, name := range tt.failed {
+				if failures[name] != "failed" {
+					t.Errorf("%v failed with %q, want its own error", name, failures[name])
+				}
+			}
+			for _, name := range tt.blocked {
+				if !strings.HasPrefix(failures[name], "blocked by") {
+					t.Errorf("%v failed with %q, want blocked", name, failures[name])
+				}
+			}
+		})
+	}
+}
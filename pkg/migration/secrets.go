@@ -0,0 +1,424 @@
+package migrate
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// Ways the secrets of a migration file can be protected
+const (
+	SecretsEncrypted = "encrypted"
+	SecretsRedacted  = "redacted"
+)
+
+// secretsCipher is the cipher of the secret fields, under a data key wrapped for the recipients
+const secretsCipher = "AES-256-GCM"
+
+// sealedPrefix marks a secret field value that was encrypted. The kind and name of the resource and the path of the
+// field are authenticated with it, so encrypted values can't be moved to another field or resource
+const sealedPrefix = "enc:v2:"
+
+// SecretsEnvelope describes how the secrets of a migration file were protected. It is part of the header
+type SecretsEnvelope struct {
+	Mode   string `json:"mode"`
+	Cipher string `json:"cipher,omitempty"`
+	// Key is the data key the secret fields are encrypted with, itself encrypted with age for the recipients
+	Key string `json:"key,omitempty"`
+	// Fields is the number of secret fields protected. Not known for the journal of a streaming migration
+	Fields int `json:"fields,omitempty"`
+}
+
+// SecretKeys are the keys used to encrypt and decrypt the secrets of migration files: a passphrase, or age X25519
+// recipients to encrypt and identity files to decrypt
+type SecretKeys struct {
+	Passphrase string
+	// Recipients are age public keys, age1...
+	Recipients []string
+	// IdentityFiles hold age private keys, AGE-SECRET-KEY-1..., one per line
+	IdentityFiles []string
+}
+
+// recipients returns the age recipients the data key is encrypted for
+func (k SecretKeys) recipients() ([]age.Recipient, error) {
+	if k.Passphrase != "" && len(k.Recipients) > 0 {
+		return nil, fmt.Errorf("secrets can be encrypted with a passphrase or with recipients, not both")
+	}
+	if k.Passphrase != "" {
+		r, err := age.NewScryptRecipient(k.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		return []age.Recipient{r}, nil
+	}
+	if len(k.Recipients) == 0 {
+		return nil, fmt.Errorf("encrypting secrets needs a passphrase or a recipient")
+	}
+	recipients := []age.Recipient{}
+	for _, v := range k.Recipients {
+		r, err := age.ParseX25519Recipient(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %v", v, err)
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// identities returns the age identities that can decrypt the data key
+func (k SecretKeys) identities() ([]age.Identity, error) {
+	identities := []age.Identity{}
+	if k.Passphrase != "" {
+		i, err := age.NewScryptIdentity(k.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, i)
+	}
+	for _, fileName := range k.IdentityFiles {
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open identity file: %v", err)
+		}
+		ids, err := age.ParseIdentities(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read identity file %v: %v", fileName, err)
+		}
+		identities = append(identities, ids...)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("the secrets of the migration file are encrypted. Set MIGRATION_PASSPHRASE or use --identity")
+	}
+	return identities, nil
+}
+
+// secretField is a secret value in a resource, either a string or raw bytes
+type secretField struct {
+	str   **string
+	bytes *[]byte
+	// aad identifies the field: <kind>/<resource name>/<path of the field>
+	aad string
+}
+
+func (f secretField) get() ([]byte, bool) {
+	if f.str != nil {
+		if *f.str == nil {
+			return nil, false
+		}
+		return []byte(**f.str), true
+	}
+	if *f.bytes == nil {
+		return nil, false
+	}
+	return *f.bytes, true
+}
+
+func (f secretField) set(value []byte) {
+	if f.str != nil {
+		s := string(value)
+		*f.str = &s
+		return
+	}
+	*f.bytes = value
+}
+
+func (f secretField) clear() {
+	if f.str != nil {
+		*f.str = nil
+		return
+	}
+	*f.bytes = nil
+}
+
+// secretFields returns the secret fields of a resource: content key values of StreamingLocators, and the FairPlay
+// ASK, PFX and PFX password and the symmetric token signing keys of ContentKeyPolicies
+func secretFields(resource interface{}) []secretField {
+	fields := []secretField{}
+	switch v := resource.(type) {
+	case *armmediaservices.StreamingLocator:
+		if v.Properties == nil {
+			return fields
+		}
+		prefix := STREAMINGLOCATORS + "/" + resourceName(v.Name)
+		for i, key := range v.Properties.ContentKeys {
+			if key != nil {
+				fields = append(fields, secretField{str: &key.Value, aad: fmt.Sprintf("%v/contentKeys/%d/value", prefix, i)})
+			}
+		}
+	case *armmediaservices.ContentKeyPolicy:
+		if v.Properties == nil {
+			return fields
+		}
+		for i, option := range v.Properties.Options {
+			if option == nil {
+				continue
+			}
+			prefix := fmt.Sprintf("%v/%v/options/%d", CONTENTKEYPOLICIES, resourceName(v.Name), i)
+			if fp, ok := option.Configuration.(*armmediaservices.ContentKeyPolicyFairPlayConfiguration); ok {
+				fields = append(fields,
+					secretField{bytes: &fp.Ask, aad: prefix + "/configuration/ask"},
+					secretField{str: &fp.FairPlayPfx, aad: prefix + "/configuration/fairPlayPfx"},
+					secretField{str: &fp.FairPlayPfxPassword, aad: prefix + "/configuration/fairPlayPfxPassword"},
+				)
+			}
+			if token, ok := option.Restriction.(*armmediaservices.ContentKeyPolicyTokenRestriction); ok {
+				if symmetric, ok := token.PrimaryVerificationKey.(*armmediaservices.ContentKeyPolicySymmetricTokenKey); ok {
+					fields = append(fields, secretField{bytes: &symmetric.KeyValue, aad: prefix + "/restriction/primaryVerificationKey/keyValue"})
+				}
+				for j, key := range token.AlternateVerificationKeys {
+					if symmetric, ok := key.(*armmediaservices.ContentKeyPolicySymmetricTokenKey); ok {
+						fields = append(fields, secretField{bytes: &symmetric.KeyValue, aad: fmt.Sprintf("%v/restriction/alternateVerificationKeys/%d/keyValue", prefix, j)})
+					}
+				}
+			}
+		}
+	}
+	return fields
+}
+
+// secretFields returns the secret fields of every resource in the contents
+func (contents MigrationFileContents) secretFields() []secretField {
+	fields := []secretField{}
+	for _, v := range contents.ContentKeyPolicies {
+		fields = append(fields, secretFields(v)...)
+	}
+	for _, v := range contents.StreamingLocators {
+		fields = append(fields, secretFields(v)...)
+	}
+	return fields
+}
+
+// SecretSealer encrypts or redacts the secret fields of resources
+type SecretSealer struct {
+	envelope SecretsEnvelope
+	// aead is nil when redacting
+	aead cipher.AEAD
+}
+
+// NewSecretEncrypter returns a sealer that encrypts secret fields with a new data key, wrapped for keys
+func NewSecretEncrypter(keys SecretKeys) (*SecretSealer, error) {
+	recipients, err := keys.recipients()
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("unable to generate data key: %v", err)
+	}
+	aead, err := newSecretsAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped := &bytes.Buffer{}
+	w, err := age.Encrypt(wrapped, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt data key: %v", err)
+	}
+	if _, err := w.Write(dataKey); err != nil {
+		return nil, fmt.Errorf("unable to encrypt data key: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("unable to encrypt data key: %v", err)
+	}
+
+	return &SecretSealer{
+		envelope: SecretsEnvelope{Mode: SecretsEncrypted, Cipher: secretsCipher, Key: base64.StdEncoding.EncodeToString(wrapped.Bytes())},
+		aead:     aead,
+	}, nil
+}
+
+// NewSecretRedacter returns a sealer that removes secret fields
+func NewSecretRedacter() *SecretSealer {
+	return &SecretSealer{envelope: SecretsEnvelope{Mode: SecretsRedacted}}
+}
+
+// Envelope returns the envelope to put in the header of a file written with the sealer
+func (s *SecretSealer) Envelope() *SecretsEnvelope {
+	envelope := s.envelope
+	return &envelope
+}
+
+// Seal returns a copy of the resource with its secret fields encrypted or removed, and the number of fields changed.
+// The resource itself is left alone, it may still be needed in plaintext
+func (s *SecretSealer) Seal(resource interface{}) (interface{}, int, error) {
+	if len(secretFields(resource)) == 0 {
+		return resource, 0, nil
+	}
+	resource, err := copyResource(resource)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	count := 0
+	for _, field := range secretFields(resource) {
+		value, ok := field.get()
+		if !ok {
+			continue
+		}
+		count++
+		if s.aead == nil {
+			field.clear()
+			continue
+		}
+		nonce := make([]byte, s.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, 0, fmt.Errorf("unable to generate nonce: %v", err)
+		}
+		sealed := s.aead.Seal(nonce, nonce, value, []byte(field.aad))
+		field.set([]byte(sealedPrefix + base64.StdEncoding.EncodeToString(sealed)))
+	}
+	return resource, count, nil
+}
+
+// SealSecrets returns a copy of the contents with the secret fields of every resource encrypted or removed. The header
+// records how, and its checksums are those of the sealed resources. Contents without secrets are returned as they are
+func (contents MigrationFileContents) SealSecrets(s *SecretSealer) (MigrationFileContents, error) {
+	if contents.Header == nil {
+		return contents, fmt.Errorf("migration file has no header, run migrate-file upgrade first")
+	}
+	if contents.Header.Secrets != nil {
+		return contents, fmt.Errorf("the secrets of the migration file are already %v", contents.Header.Secrets.Mode)
+	}
+
+	sealed := contents
+	count := 0
+	sealed.ContentKeyPolicies = make([]*armmediaservices.ContentKeyPolicy, len(contents.ContentKeyPolicies))
+	for i, v := range contents.ContentKeyPolicies {
+		resource, n, err := s.Seal(v)
+		if err != nil {
+			return contents, fmt.Errorf("unable to seal content key policy %v: %v", resourceName(v.Name), err)
+		}
+		sealed.ContentKeyPolicies[i] = resource.(*armmediaservices.ContentKeyPolicy)
+		count += n
+	}
+	sealed.StreamingLocators = make([]*armmediaservices.StreamingLocator, len(contents.StreamingLocators))
+	for i, v := range contents.StreamingLocators {
+		resource, n, err := s.Seal(v)
+		if err != nil {
+			return contents, fmt.Errorf("unable to seal streaming locator %v: %v", resourceName(v.Name), err)
+		}
+		sealed.StreamingLocators[i] = resource.(*armmediaservices.StreamingLocator)
+		count += n
+	}
+	if count == 0 {
+		return contents, nil
+	}
+
+	header := *contents.Header
+	header.Secrets = s.Envelope()
+	header.Secrets.Fields = count
+	if err := sealed.SetHeader(header); err != nil {
+		return contents, err
+	}
+	return sealed, nil
+}
+
+// DecryptSecrets decrypts the secret fields of contents read from a file with encrypted secrets, in place. The header
+// is updated to match the plaintext resources. Contents without encrypted secrets are left alone
+func (contents *MigrationFileContents) DecryptSecrets(keys SecretKeys) error {
+	if contents.Header == nil || contents.Header.Secrets == nil || contents.Header.Secrets.Mode != SecretsEncrypted {
+		return nil
+	}
+	aead, err := openDataKey(contents.Header.Secrets, keys)
+	if err != nil {
+		return err
+	}
+	if err := openFields(aead, contents.secretFields()); err != nil {
+		return err
+	}
+
+	header := *contents.Header
+	header.Secrets = nil
+	return contents.SetHeader(header)
+}
+
+// openDataKey decrypts the data key of an envelope with keys and returns its cipher
+func openDataKey(envelope *SecretsEnvelope, keys SecretKeys) (cipher.AEAD, error) {
+	if envelope.Cipher != secretsCipher {
+		return nil, fmt.Errorf("unknown secrets cipher %q", envelope.Cipher)
+	}
+
+	identities, err := keys.identities()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(envelope.Key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key in header: %v", err)
+	}
+	r, err := age.Decrypt(bytes.NewReader(wrapped), identities...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt data key: %v", err)
+	}
+	dataKey, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt data key: %v", err)
+	}
+	return newSecretsAEAD(dataKey)
+}
+
+// openFields decrypts secret fields in place
+func openFields(aead cipher.AEAD, fields []secretField) error {
+	for _, field := range fields {
+		value, ok := field.get()
+		if !ok {
+			continue
+		}
+		if !bytes.HasPrefix(value, []byte(sealedPrefix)) {
+			return fmt.Errorf("secret field %v isn't encrypted", field.aad)
+		}
+		sealed, err := base64.StdEncoding.DecodeString(string(value[len(sealedPrefix):]))
+		if err != nil || len(sealed) < aead.NonceSize() {
+			return fmt.Errorf("invalid encrypted secret field %v", field.aad)
+		}
+		plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(field.aad))
+		if err != nil {
+			return fmt.Errorf("unable to decrypt secret field %v: %v", field.aad, err)
+		}
+		field.set(plaintext)
+	}
+	return nil
+}
+
+// newSecretsAEAD returns the cipher of the secret fields for a data key
+func newSecretsAEAD(dataKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data key: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// copyResource returns a deep copy of a resource
+func copyResource(resource interface{}) (interface{}, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var c interface{}
+	switch resource.(type) {
+	case *armmediaservices.StreamingLocator:
+		c = &armmediaservices.StreamingLocator{}
+	case *armmediaservices.ContentKeyPolicy:
+		c = &armmediaservices.ContentKeyPolicy{}
+	default:
+		return nil, fmt.Errorf("unable to copy %T", resource)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,217 @@
+package migrate
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// testSecretContents returns contents with two locators with content keys and a policy with FairPlay and token keys
+func testSecretContents(t *testing.T) MigrationFileContents {
+	contents := MigrationFileContents{
+		ContentKeyPolicies: []*armmediaservices.ContentKeyPolicy{{
+			Name: to.Ptr("ckp1"),
+			Properties: &armmediaservices.ContentKeyPolicyProperties{
+				Options: []*armmediaservices.ContentKeyPolicyOption{{
+					Configuration: &armmediaservices.ContentKeyPolicyFairPlayConfiguration{
+						ODataType:           to.Ptr("#Microsoft.Media.ContentKeyPolicyFairPlayConfiguration"),
+						Ask:                 []byte("application secret key"),
+						FairPlayPfx:         to.Ptr("pfx"),
+						FairPlayPfxPassword: to.Ptr("pfx password"),
+					},
+					Restriction: &armmediaservices.ContentKeyPolicyTokenRestriction{
+						ODataType: to.Ptr("#Microsoft.Media.ContentKeyPolicyTokenRestriction"),
+						PrimaryVerificationKey: &armmediaservices.ContentKeyPolicySymmetricTokenKey{
+							ODataType: to.Ptr("#Microsoft.Media.ContentKeyPolicySymmetricTokenKey"),
+							KeyValue:  []byte("primary key"),
+						},
+						AlternateVerificationKeys: []armmediaservices.ContentKeyPolicyRestrictionTokenKeyClassification{
+							&armmediaservices.ContentKeyPolicySymmetricTokenKey{
+								ODataType: to.Ptr("#Microsoft.Media.ContentKeyPolicySymmetricTokenKey"),
+								KeyValue:  []byte("alternate key"),
+							},
+						},
+					},
+				}},
+			},
+		}},
+		StreamingLocators: []*armmediaservices.StreamingLocator{
+			{Name: to.Ptr("l1"), Properties: &armmediaservices.StreamingLocatorProperties{
+				ContentKeys: []*armmediaservices.StreamingLocatorContentKey{{ID: to.Ptr("k1"), Value: to.Ptr("key one")}},
+			}},
+			{Name: to.Ptr("l2"), Properties: &armmediaservices.StreamingLocatorProperties{
+				ContentKeys: []*armmediaservices.StreamingLocatorContentKey{{ID: to.Ptr("k2"), Value: to.Ptr("key two")}},
+			}},
+		},
+	}
+	if err := contents.SetHeader(MigrationFileHeader{SchemaVersion: SchemaVersion}); err != nil {
+		t.Fatalf("SetHeader: %v", err)
+	}
+	return contents
+}
+
+// testSecretKeys returns keys for a new age identity, written to a temporary identity file
+func testSecretKeys(t *testing.T) SecretKeys {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("GenerateX25519Identity: %v", err)
+	}
+	fileName := filepath.Join(t.TempDir(), "identity.txt")
+	if err := os.WriteFile(fileName, []byte(identity.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return SecretKeys{Recipients: []string{identity.Recipient().String()}, IdentityFiles: []string{fileName}}
+}
+
+func TestSealSecretsRoundTrip(t *testing.T) {
+	keys := testSecretKeys(t)
+	contents := testSecretContents(t)
+	plaintext, _ := json.Marshal(contents)
+
+	sealer, err := NewSecretEncrypter(keys)
+	if err != nil {
+		t.Fatalf("NewSecretEncrypter: %v", err)
+	}
+	sealed, err := contents.SealSecrets(sealer)
+	if err != nil {
+		t.Fatalf("SealSecrets: %v", err)
+	}
+	if got, _ := json.Marshal(contents); !bytes.Equal(got, plaintext) {
+		t.Errorf("SealSecrets changed the contents it was given")
+	}
+	if sealed.Header.Secrets == nil || sealed.Header.Secrets.Mode != SecretsEncrypted || sealed.Header.Secrets.Fields != 7 {
+		t.Errorf("sealed header secrets = %+v, want 7 encrypted fields", sealed.Header.Secrets)
+	}
+	for _, field := range sealed.secretFields() {
+		value, _ := field.get()
+		if !strings.HasPrefix(string(value), sealedPrefix) {
+			t.Errorf("%v = %q, want it encrypted", field.aad, value)
+		}
+	}
+
+	// Through a file, like the commands do
+	data, err := json.Marshal(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := MigrationFileContents{}
+	if err := json.Unmarshal(data, &read); err != nil {
+		t.Fatal(err)
+	}
+	if err := read.DecryptSecrets(keys); err != nil {
+		t.Fatalf("DecryptSecrets: %v", err)
+	}
+	if got, _ := json.Marshal(read); !bytes.Equal(got, plaintext) {
+		t.Errorf("decrypted\n%s\nwant\n%s", got, plaintext)
+	}
+}
+
+func TestDecryptSecretsErrors(t *testing.T) {
+	keys := testSecretKeys(t)
+
+	tests := []struct {
+		name string
+		// tamper changes the sealed contents before they are decrypted
+		tamper  func(contents *MigrationFileContents)
+		keys    SecretKeys
+		wantErr string
+	}{
+		{
+			name:   "untouched",
+			tamper: func(contents *MigrationFileContents) {},
+			keys:   keys,
+		},
+		{
+			name: "value moved to another resource",
+			tamper: func(contents *MigrationFileContents) {
+				l1, l2 := contents.StreamingLocators[0].Properties.ContentKeys[0], contents.StreamingLocators[1].Properties.ContentKeys[0]
+				l1.Value, l2.Value = l2.Value, l1.Value
+			},
+			keys:    keys,
+			wantErr: "unable to decrypt secret field streamingLocators/l1/contentKeys/0/value",
+		},
+		{
+			name: "value moved to another field",
+			tamper: func(contents *MigrationFileContents) {
+				token := contents.ContentKeyPolicies[0].Properties.Options[0].Restriction.(*armmediaservices.ContentKeyPolicyTokenRestriction)
+				primary := token.PrimaryVerificationKey.(*armmediaservices.ContentKeyPolicySymmetricTokenKey)
+				alternate := token.AlternateVerificationKeys[0].(*armmediaservices.ContentKeyPolicySymmetricTokenKey)
+				primary.KeyValue, alternate.KeyValue = alternate.KeyValue, primary.KeyValue
+			},
+			keys:    keys,
+			wantErr: "unable to decrypt secret field contentKeyPolicies/ckp1/options/0/restriction/primaryVerificationKey/keyValue",
+		},
+		{
+			name: "value not encrypted",
+			tamper: func(contents *MigrationFileContents) {
+				contents.StreamingLocators[1].Properties.ContentKeys[0].Value = to.Ptr("key two")
+			},
+			keys:    keys,
+			wantErr: "secret field streamingLocators/l2/contentKeys/0/value isn't encrypted",
+		},
+		{
+			name:    "wrong identity",
+			tamper:  func(contents *MigrationFileContents) {},
+			keys:    testSecretKeys(t),
+			wantErr: "unable to decrypt data key",
+		},
+		{
+			name:    "no keys",
+			tamper:  func(contents *MigrationFileContents) {},
+			wantErr: "the secrets of the migration file are encrypted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealer, err := NewSecretEncrypter(keys)
+			if err != nil {
+				t.Fatalf("NewSecretEncrypter: %v", err)
+			}
+			sealed, err := testSecretContents(t).SealSecrets(sealer)
+			if err != nil {
+				t.Fatalf("SealSecrets: %v", err)
+			}
+			tt.tamper(&sealed)
+
+			err = sealed.DecryptSecrets(tt.keys)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("DecryptSecrets: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("DecryptSecrets error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	contents := testSecretContents(t)
+	redacted, err := contents.SealSecrets(NewSecretRedacter())
+	if err != nil {
+		t.Fatalf("SealSecrets: %v", err)
+	}
+	if redacted.Header.Secrets == nil || redacted.Header.Secrets.Mode != SecretsRedacted || redacted.Header.Secrets.Fields != 7 {
+		t.Errorf("redacted header secrets = %+v, want 7 redacted fields", redacted.Header.Secrets)
+	}
+	for _, field := range redacted.secretFields() {
+		if value, ok := field.get(); ok {
+			t.Errorf("%v = %q, want it removed", field.aad, value)
+		}
+	}
+	for _, field := range contents.secretFields() {
+		if _, ok := field.get(); !ok {
+			t.Errorf("%v was removed from the contents SealSecrets was given", field.aad)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"crypto/cipher"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+	log "github.com/sirupsen/logrus"
+)
+
+// RESTORE is the operation reported in a Result by RestoreSnapshots
+const RESTORE = "restore"
+
+// SnapshotEntry is the state of a resource in mk.io before an import overwrote it
+type SnapshotEntry struct {
+	Kind      string          `json:"kind"`
+	AssetName string          `json:"assetName,omitempty"`
+	Name      string          `json:"name"`
+	Time      time.Time       `json:"time"`
+	Data      json.RawMessage `json:"data"`
+	// Secrets is how the secrets of Data were encrypted, nil if they are in plaintext
+	Secrets *SecretsEnvelope `json:"secrets,omitempty"`
+}
+
+// String returns the name of the resource, prefixed with its asset for Asset Filters
+func (e SnapshotEntry) String() string {
+	if e.AssetName != "" {
+		return e.AssetName + "/" + e.Name
+	}
+	return e.Name
+}
+
+// SnapshotStore keeps a copy of every resource an import is about to overwrite, so --overwrite can be undone.
+// Snapshots are appended to the file as JSON lines and the file is never truncated, so the originals
+// survive any number of overwriting runs. A nil *SnapshotStore is valid and saves nothing.
+type SnapshotStore struct {
+	mu   sync.Mutex
+	file *os.File
+	// sealer encrypts the secrets of the snapshots, nil to keep them in plaintext
+	sealer *SecretSealer
+	// warned is set once the plaintext secrets warning was logged
+	warned bool
+}
+
+// OpenSnapshotStore opens the snapshot file for appending. The secrets of the snapshots are encrypted with sealer, or
+// kept in plaintext if it is nil, in which case only the owner can read the file
+func OpenSnapshotStore(fileName string, sealer *SecretSealer) (*SnapshotStore, error) {
+	if sealer != nil && sealer.Envelope().Mode != SecretsEncrypted {
+		// A redacted snapshot couldn't be restored
+		return nil, fmt.Errorf("snapshot secrets can only be encrypted")
+	}
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open snapshot file %v: %v", fileName, err)
+	}
+	// The file may predate the store
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to restrict snapshot file %v: %v", fileName, err)
+	}
+	return &SnapshotStore{file: f, sealer: sealer}, nil
+}
+
+// Save writes the current state of a resource to the snapshot file. The resource must not be overwritten if this fails
+func (s *SnapshotStore) Save(kind string, assetName string, name string, resource interface{}) error {
+	if s == nil {
+		return nil
+	}
+
+	// Secrets are only found through pointers
+	switch v := resource.(type) {
+	case armmediaservices.StreamingLocator:
+		resource = &v
+	case armmediaservices.ContentKeyPolicy:
+		resource = &v
+	}
+	entry := SnapshotEntry{
+		Kind:      kind,
+		AssetName: assetName,
+		Name:      name,
+		Time:      time.Now().UTC(),
+	}
+	if s.sealer != nil {
+		sealed, count, err := s.sealer.Seal(resource)
+		if err != nil {
+			return fmt.Errorf("unable to encrypt snapshot of %v %v: %v", kind, name, err)
+		}
+		resource = sealed
+		if count > 0 {
+			entry.Secrets = s.sealer.Envelope()
+		}
+	} else if len(secretFields(resource)) > 0 {
+		s.warnPlaintext()
+	}
+
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return fmt.Errorf("unable to marshal snapshot of %v %v: %v", kind, name, err)
+	}
+	entry.Data = data
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("unable to marshal snapshot of %v %v: %v", kind, name, err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.file.Write(line)
+	if err != nil {
+		return fmt.Errorf("unable to write snapshot of %v %v: %v", kind, name, err)
+	}
+	// The original is about to be deleted. Make sure the copy is on disk first
+	return s.file.Sync()
+}
+
+// warnPlaintext warns, once, that the snapshot file holds secrets in plaintext
+func (s *SnapshotStore) warnPlaintext() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.warned {
+		s.warned = true
+		log.Warnf("Snapshot file %v holds content keys and key policy secrets in plaintext, use --encrypt-secrets to encrypt them", s.file.Name())
+	}
+}
+
+// saveExisting keeps a copy of a resource found in mk.io before it is overwritten. lookupErr is the error of the lookup
+// that returned the resource. Without a copy the resource must not be overwritten
+func (s *SnapshotStore) saveExisting(kind string, assetName string, name string, resource interface{}, lookupErr error) error {
+	if s == nil {
+		return nil
+	}
+	if lookupErr != nil {
+		return fmt.Errorf("unable to snapshot %v %v before overwrite: %v", kind, name, lookupErr)
+	}
+	return s.Save(kind, assetName, name, resource)
+}
+
+// Close closes the snapshot file
+func (s *SnapshotStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+// ReadSnapshots reads a snapshot file. When a resource was overwritten more than once, the oldest snapshot is the
+// original and is the one returned. Encrypted secrets are decrypted with keys
+func ReadSnapshots(fileName string, keys SecretKeys) ([]SnapshotEntry, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open snapshot file %v: %v", fileName, err)
+	}
+	defer f.Close()
+
+	snapshots := []SnapshotEntry{}
+	seen := map[string]bool{}
+	// Every run encrypts with its own data key
+	aeads := map[string]cipher.AEAD{}
+	scanner := bufio.NewScanner(f)
+	// Resources with many tracks or options can be large
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		entry := SnapshotEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			log.Warnf("ignoring unreadable snapshot entry: %v", err)
+			continue
+		}
+		key := checkpointKey(entry.Kind, entry.AssetName, entry.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if entry.Secrets != nil {
+			if err := openSnapshot(&entry, keys, aeads); err != nil {
+				return nil, fmt.Errorf("unable to decrypt snapshot of %v %v: %v", entry.Kind, entry, err)
+			}
+		}
+		snapshots = append(snapshots, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read snapshot file %v: %v", fileName, err)
+	}
+
+	return snapshots, nil
+}
+
+// openSnapshot decrypts the secrets of a snapshot. The cipher of each data key is kept in aeads
+func openSnapshot(entry *SnapshotEntry, keys SecretKeys, aeads map[string]cipher.AEAD) error {
+	var resource interface{}
+	switch entry.Kind {
+	case STREAMINGLOCATORS:
+		resource = &armmediaservices.StreamingLocator{}
+	case CONTENTKEYPOLICIES:
+		resource = &armmediaservices.ContentKeyPolicy{}
+	default:
+		return fmt.Errorf("%v have no secrets", entry.Kind)
+	}
+	if err := json.Unmarshal(entry.Data, resource); err != nil {
+		return err
+	}
+
+	aead, ok := aeads[entry.Secrets.Key]
+	if !ok {
+		var err error
+		aead, err = openDataKey(entry.Secrets, keys)
+		if err != nil {
+			return err
+		}
+		aeads[entry.Secrets.Key] = aead
+	}
+	if err := openFields(aead, secretFields(resource)); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return err
+	}
+	entry.Data = data
+	entry.Secrets = nil
+	return nil
+}
+
+// restoreResource puts a snapshot back into the Destination. Resources mk.io can't update are deleted first
+func (p *Pipeline) restoreResource(ctx context.Context, entry SnapshotEntry) error {
+	dest := p.Destination
+
+	// Delete whatever the import left in place of the original. Nothing there is fine
+	deleteFirst := func() error {
+		err := p.deleteResource(ctx, entry.Kind, entry.AssetName, entry.Name)
+		if err != nil && !mkiosdk.IsNotFound(err) {
+			return fmt.Errorf("unable to delete current %v %v: %v", entry.Kind, entry.Name, err)
+		}
+		return nil
+	}
+
+	var err error
+	switch entry.Kind {
+	case ASSETS:
+		asset := &armmediaservices.Asset{}
+		if err = json.Unmarshal(entry.Data, asset); err == nil {
+			_, err = dest.assetsClient.CreateOrUpdate(ctx, entry.Name, asset, nil)
+		}
+	case ASSETFILTERS:
+		assetFilter := &armmediaservices.AssetFilter{}
+		if err = json.Unmarshal(entry.Data, assetFilter); err == nil {
+			_, err = dest.assetFiltersClient.CreateOrUpdate(ctx, entry.AssetName, entry.Name, assetFilter, nil)
+		}
+	case STREAMINGPOLICIES:
+		sp := armmediaservices.StreamingPolicy{}
+		if err = json.Unmarshal(entry.Data, &sp); err == nil {
+			if err = deleteFirst(); err == nil {
+				_, err = dest.streamingPoliciesClient.CreateOrUpdate(ctx, entry.Name, sp, nil)
+			}
+		}
+	case STREAMINGLOCATORS:
+		sl := armmediaservices.StreamingLocator{}
+		if err = json.Unmarshal(entry.Data, &sl); err == nil {
+			if err = deleteFirst(); err == nil {
+				_, err = dest.streamingLocatorsClient.CreateOrUpdate(ctx, entry.Name, sl, nil)
+			}
+		}
+	case STREAMINGENDPOINTS:
+		se := armmediaservices.StreamingEndpoint{}
+		if err = json.Unmarshal(entry.Data, &se); err == nil {
+			if err = deleteFirst(); err == nil {
+				_, err = dest.streamingEndpointsClient.CreateOrUpdate(ctx, entry.Name, se, nil)
+			}
+		}
+	case CONTENTKEYPOLICIES:
+		ckp := armmediaservices.ContentKeyPolicy{}
+		if err = json.Unmarshal(entry.Data, &ckp); err == nil && ckp.Properties == nil {
+			err = fmt.Errorf("snapshot of ContentKeyPolicy %v has no properties", entry.Name)
+		}
+		if err == nil {
+			// fairPlayAmsCompatibility isn't part of the AMS model, so mk.io falls back to its default
+			if err = deleteFirst(); err == nil {
+				_, err = dest.contentKeyPoliciesClient.CreateOrUpdate(ctx, entry.Name, &mkiosdk.FPContentKeyPolicy{
+					ContentKeyPolicy: ckp,
+					FPProperties:     &mkiosdk.FPContentKeyPolicyProperties{ContentKeyPolicyProperties: *ckp.Properties},
+				}, nil)
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown resource type %v", entry.Kind)
+	}
+	return err
+}
+
+// RestoreSnapshots puts the snapshots taken before an overwrite back into the Destination, in import order
+func (p *Pipeline) RestoreSnapshots(ctx context.Context, snapshots []SnapshotEntry) ([]Result, error) {
+	timings := []Result{}
+
+	if p.Destination == nil {
+		return timings, fmt.Errorf("restore Error: no mk.io subscription to restore into")
+	}
+
+	retries := retryStats(p.Destination)
+	byKind := map[string][]SnapshotEntry{}
+	for _, entry := range snapshots {
+		byKind[entry.Kind] = append(byKind[entry.Kind], entry)
+	}
+
+	for _, kind := range ImportOrder {
+		list := byKind[kind]
+		if len(list) == 0 {
+			continue
+		}
+		log.Infof("Restoring %d %v", len(list), kind)
+
+		jobs := []resourceJob{}
+		for _, entry := range list {
+			entry := entry
+			jobs = append(jobs, resourceJob{assetName: entry.AssetName, name: entry.Name, run: func() error {
+				log.Debugf("Restoring %v in MKIO: %v", entry.Kind, entry)
+				return p.restoreResource(ctx, entry)
+			}})
+		}
+		result := runResourceJobs(kind, RESTORE, jobs, p.Workers)
+		log.Infof("Restored %d %v, %d failed", result.Migrated, kind, len(result.Failures))
+
+		timings = append(timings, result)
+	}
+
+	addRetries(timings, RESTORE, retries, retryStats(p.Destination))
+	return timings, nil
+}
@@ -0,0 +1,199 @@
+package migrate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// SplitOptions select how Split divides a migration file. Exactly one of them must be set
+type SplitOptions struct {
+	// AssetsPerFile puts this many assets in each part, in the order of the file
+	AssetsPerFile int
+	// DateBoundaries split the assets by created date. n boundaries give n+1 parts
+	DateBoundaries []time.Time
+	// Prefixes split the assets by name. An asset goes to the longest prefix it starts with, the rest to "other"
+	Prefixes []string
+}
+
+// Shard is a part of a split migration file
+type Shard struct {
+	// Name describes the assets in the shard, e.g. part-001, from-2023-06-01 or prefix-news
+	Name     string
+	Contents MigrationFileContents
+}
+
+// Split divides the contents into shards. Each asset is kept together with its Asset Filters and Streaming Locators.
+// Content Key Policies, Streaming Policies and Streaming Endpoints are used by every shard. They go in the first
+// shard, which must be imported first. Empty shards are left out
+func (contents MigrationFileContents) Split(opts SplitOptions) ([]Shard, error) {
+	set := 0
+	if opts.AssetsPerFile > 0 {
+		set++
+	}
+	if len(opts.DateBoundaries) > 0 {
+		set++
+	}
+	if len(opts.Prefixes) > 0 {
+		set++
+	}
+	if set != 1 {
+		return nil, fmt.Errorf("split needs exactly one of assets per file, date boundaries or prefixes")
+	}
+
+	// Every asset name used in the file, in order of appearance. Filters and locators may use assets that
+	// aren't in the file
+	assetNames := []string{}
+	created := map[string]time.Time{}
+	seen := map[string]bool{}
+	addName := func(name string, t *time.Time) {
+		if !seen[name] {
+			seen[name] = true
+			assetNames = append(assetNames, name)
+		}
+		if t != nil && created[name].IsZero() {
+			created[name] = *t
+		}
+	}
+	for _, v := range contents.Assets {
+		var t *time.Time
+		if v.Properties != nil {
+			t = v.Properties.Created
+		}
+		addName(resourceName(v.Name), t)
+	}
+	filterAssets := make([]string, 0, len(contents.AssetFilters))
+	for assetName := range contents.AssetFilters {
+		filterAssets = append(filterAssets, assetName)
+	}
+	sort.Strings(filterAssets)
+	for _, v := range filterAssets {
+		addName(v, nil)
+	}
+	for _, v := range contents.StreamingLocators {
+		if v.Properties != nil && v.Properties.AssetName != nil {
+			addName(*v.Properties.AssetName, v.Properties.Created)
+		}
+	}
+
+	// Work out the shard of each asset
+	shardNames := []string{}
+	shardOf := map[string]string{}
+	switch {
+	case opts.AssetsPerFile > 0:
+		for i, name := range assetNames {
+			shardOf[name] = fmt.Sprintf("part-%03d", i/opts.AssetsPerFile+1)
+		}
+		for i := 0; i*opts.AssetsPerFile < len(assetNames); i++ {
+			shardNames = append(shardNames, fmt.Sprintf("part-%03d", i+1))
+		}
+
+	case len(opts.DateBoundaries) > 0:
+		boundaries := append([]time.Time{}, opts.DateBoundaries...)
+		sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].Before(boundaries[j]) })
+		shardNames = append(shardNames, "before-"+boundaries[0].Format("2006-01-02"))
+		for i := 1; i < len(boundaries); i++ {
+			shardNames = append(shardNames, boundaries[i-1].Format("2006-01-02")+"-to-"+boundaries[i].Format("2006-01-02"))
+		}
+		shardNames = append(shardNames, "from-"+boundaries[len(boundaries)-1].Format("2006-01-02"))
+		for _, name := range assetNames {
+			// Assets without a created date go with the oldest
+			i := sort.Search(len(boundaries), func(i int) bool { return created[name].Before(boundaries[i]) })
+			shardOf[name] = shardNames[i]
+		}
+
+	default:
+		prefixes := append([]string{}, opts.Prefixes...)
+		sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+		for _, v := range opts.Prefixes {
+			shardNames = append(shardNames, "prefix-"+v)
+		}
+		shardNames = append(shardNames, "other")
+		for _, name := range assetNames {
+			shardOf[name] = "other"
+			for _, prefix := range prefixes {
+				if strings.HasPrefix(name, prefix) {
+					shardOf[name] = "prefix-" + prefix
+					break
+				}
+			}
+		}
+	}
+
+	parts := map[string]*MigrationFileContents{}
+	for _, v := range shardNames {
+		parts[v] = &MigrationFileContents{}
+	}
+	for _, v := range contents.Assets {
+		part := parts[shardOf[resourceName(v.Name)]]
+		part.Assets = append(part.Assets, v)
+	}
+	for _, assetName := range filterAssets {
+		part := parts[shardOf[assetName]]
+		if part.AssetFilters == nil {
+			part.AssetFilters = map[string][]*armmediaservices.AssetFilter{}
+		}
+		part.AssetFilters[assetName] = contents.AssetFilters[assetName]
+	}
+	orphans := []*armmediaservices.StreamingLocator{}
+	for _, v := range contents.StreamingLocators {
+		if v.Properties == nil || v.Properties.AssetName == nil {
+			orphans = append(orphans, v)
+			continue
+		}
+		part := parts[shardOf[*v.Properties.AssetName]]
+		part.StreamingLocators = append(part.StreamingLocators, v)
+	}
+
+	shards := []Shard{}
+	for _, name := range shardNames {
+		part := parts[name]
+		if len(part.Assets) == 0 && len(part.AssetFilters) == 0 && len(part.StreamingLocators) == 0 {
+			continue
+		}
+		shards = append(shards, Shard{Name: name, Contents: *part})
+	}
+	if len(shards) == 0 {
+		name := "part-001"
+		if len(shardNames) > 0 {
+			name = shardNames[0]
+		}
+		shards = append(shards, Shard{Name: name})
+	}
+
+	// The resources every shard may use go in the first one
+	first := &shards[0].Contents
+	first.ContentKeyPolicies = contents.ContentKeyPolicies
+	first.StreamingPolicies = contents.StreamingPolicies
+	first.StreamingEndpoints = contents.StreamingEndpoints
+	first.StreamingLocators = append(first.StreamingLocators, orphans...)
+
+	for i := range shards {
+		if err := shards[i].Contents.setShardHeader(contents.Header); err != nil {
+			return nil, err
+		}
+	}
+	return shards, nil
+}
+
+// setShardHeader gives a part of a migration file the header of the whole file, with its own counts and checksums
+func (contents *MigrationFileContents) setShardHeader(header *MigrationFileHeader) error {
+	if header == nil {
+		return nil
+	}
+	h := *header
+	if h.Secrets != nil {
+		envelope := *h.Secrets
+		envelope.Fields = 0
+		for _, field := range contents.secretFields() {
+			if _, ok := field.get(); ok {
+				envelope.Fields++
+			}
+		}
+		h.Secrets = &envelope
+	}
+	return contents.SetHeader(h)
+}
@@ -0,0 +1,180 @@
+package migrate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// testSplitContents returns contents with assets created on different days, filters and locators of some of them, a
+// locator of an asset that isn't in the file and one without an asset
+func testSplitContents() MigrationFileContents {
+	day := func(d int) *time.Time { return to.Ptr(time.Date(2023, 6, d, 12, 0, 0, 0, time.UTC)) }
+	asset := func(name string, created *time.Time) *armmediaservices.Asset {
+		return &armmediaservices.Asset{Name: to.Ptr(name), Properties: &armmediaservices.AssetProperties{Created: created}}
+	}
+	locator := func(name string, assetName *string) *armmediaservices.StreamingLocator {
+		return &armmediaservices.StreamingLocator{Name: to.Ptr(name), Properties: &armmediaservices.StreamingLocatorProperties{AssetName: assetName}}
+	}
+	return MigrationFileContents{
+		Header: &MigrationFileHeader{SchemaVersion: SchemaVersion},
+		Assets: []*armmediaservices.Asset{
+			asset("news-1", day(1)),
+			asset("news-2", day(10)),
+			asset("sport-1", day(20)),
+			asset("misc", nil),
+		},
+		AssetFilters: map[string][]*armmediaservices.AssetFilter{
+			"news-1":  {{Name: to.Ptr("f1")}, {Name: to.Ptr("f2")}},
+			"sport-1": {{Name: to.Ptr("f3")}},
+		},
+		StreamingLocators: []*armmediaservices.StreamingLocator{
+			locator("l1", to.Ptr("news-2")),
+			locator("l2", to.Ptr("sport-1")),
+			locator("l3", to.Ptr("gone")),
+			locator("l4", nil),
+		},
+		ContentKeyPolicies: []*armmediaservices.ContentKeyPolicy{{Name: to.Ptr("ckp1")}},
+		StreamingPolicies:  []*armmediaservices.StreamingPolicy{{Name: to.Ptr("sp1")}},
+		StreamingEndpoints: []*armmediaservices.StreamingEndpoint{{Name: to.Ptr("se1")}},
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		opts SplitOptions
+		// shards maps the name of each shard to the assets, including those only used by filters or locators, in it
+		shards  map[string][]string
+		order   []string
+		wantErr bool
+	}{
+		{
+			name:    "no option",
+			wantErr: true,
+		},
+		{
+			name:    "more than one option",
+			opts:    SplitOptions{AssetsPerFile: 2, Prefixes: []string{"news"}},
+			wantErr: true,
+		},
+		{
+			name: "assets per file",
+			opts: SplitOptions{AssetsPerFile: 2},
+			shards: map[string][]string{
+				"part-001": {"news-1", "news-2"},
+				"part-002": {"sport-1", "misc"},
+				"part-003": {"gone"},
+			},
+			order: []string{"part-001", "part-002", "part-003"},
+		},
+		{
+			name: "date boundaries",
+			opts: SplitOptions{DateBoundaries: []time.Time{
+				time.Date(2023, 6, 15, 0, 0, 0, 0, time.UTC),
+				time.Date(2023, 6, 5, 0, 0, 0, 0, time.UTC),
+			}},
+			shards: map[string][]string{
+				"before-2023-06-05":        {"news-1", "misc", "gone"},
+				"2023-06-05-to-2023-06-15": {"news-2"},
+				"from-2023-06-15":          {"sport-1"},
+			},
+			order: []string{"before-2023-06-05", "2023-06-05-to-2023-06-15", "from-2023-06-15"},
+		},
+		{
+			name: "longest prefix wins",
+			opts: SplitOptions{Prefixes: []string{"news", "news-2", "weather"}},
+			shards: map[string][]string{
+				"prefix-news":   {"news-1"},
+				"prefix-news-2": {"news-2"},
+				"other":         {"sport-1", "misc", "gone"},
+			},
+			order: []string{"prefix-news", "prefix-news-2", "other"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contents := testSplitContents()
+			shards, err := contents.Split(tt.opts)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Split succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Split: %v", err)
+			}
+
+			names := []string{}
+			for _, shard := range shards {
+				names = append(names, shard.Name)
+			}
+			if len(names) != len(tt.order) {
+				t.Fatalf("shards %v, want %v", names, tt.order)
+			}
+			for i := range names {
+				if names[i] != tt.order[i] {
+					t.Fatalf("shards %v, want %v", names, tt.order)
+				}
+			}
+
+			// Where each asset went, from the asset itself, its filters and its locators
+			shardOf := map[string]string{}
+			place := func(assetName string, shard string) {
+				if s, ok := shardOf[assetName]; ok && s != shard {
+					t.Errorf("resources of asset %v are in %v and %v", assetName, s, shard)
+				}
+				shardOf[assetName] = shard
+			}
+			counts := map[string]int{}
+			for i, shard := range shards {
+				c := shard.Contents
+				for _, v := range c.Assets {
+					place(*v.Name, shard.Name)
+				}
+				for assetName, filters := range c.AssetFilters {
+					place(assetName, shard.Name)
+					counts[ASSETFILTERS] += len(filters)
+				}
+				for _, v := range c.StreamingLocators {
+					if v.Properties.AssetName != nil {
+						place(*v.Properties.AssetName, shard.Name)
+					} else if i != 0 {
+						t.Errorf("locator %v without an asset is in %v, want it in the first shard", *v.Name, shard.Name)
+					}
+				}
+				counts[ASSETS] += len(c.Assets)
+				counts[STREAMINGLOCATORS] += len(c.StreamingLocators)
+				counts[CONTENTKEYPOLICIES] += len(c.ContentKeyPolicies)
+				counts[STREAMINGPOLICIES] += len(c.StreamingPolicies)
+				counts[STREAMINGENDPOINTS] += len(c.StreamingEndpoints)
+				if i > 0 && len(c.ContentKeyPolicies)+len(c.StreamingPolicies)+len(c.StreamingEndpoints) > 0 {
+					t.Errorf("shard %v has resources shared by every shard, want them in the first one", shard.Name)
+				}
+
+				if c.Header == nil || c.Header.Counts[ASSETS] != len(c.Assets) {
+					t.Errorf("shard %v header %+v doesn't count its own assets", shard.Name, c.Header)
+				}
+			}
+
+			for shard, assetNames := range tt.shards {
+				for _, assetName := range assetNames {
+					if shardOf[assetName] != shard {
+						t.Errorf("asset %v is in %q, want %q", assetName, shardOf[assetName], shard)
+					}
+				}
+			}
+			// Nothing is lost or duplicated
+			want := map[string]int{ASSETS: 4, ASSETFILTERS: 3, STREAMINGLOCATORS: 4, CONTENTKEYPOLICIES: 1, STREAMINGPOLICIES: 1, STREAMINGENDPOINTS: 1}
+			for kind, n := range want {
+				if counts[kind] != n {
+					t.Errorf("shards have %d %v, want %d", counts[kind], kind, n)
+				}
+			}
+		})
+	}
+}
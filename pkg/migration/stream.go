@@ -0,0 +1,289 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+	log "github.com/sirupsen/logrus"
+)
+
+// ExportJournal records every resource a streaming migration exports, in the order they were exported. The journal
+// is an NDJSON migration file, so it can be imported again. A nil *ExportJournal is valid and records nothing.
+type ExportJournal struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *NDJSONWriter
+	// sealer protects the secrets of the resources written, if set
+	sealer *SecretSealer
+}
+
+// CreateExportJournal creates the journal file, truncating any previous one. Secrets are encrypted or redacted
+// with sealer, nil to write them as they are
+func CreateExportJournal(fileName string, sealer *SecretSealer) (*ExportJournal, error) {
+	// Without a sealer the journal holds secrets, so only the owner can read it
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create export journal %v: %v", fileName, err)
+	}
+	return &ExportJournal{file: f, writer: NewNDJSONWriter(f), sealer: sealer}, nil
+}
+
+// Write appends a resource to the journal
+func (j *ExportJournal) Write(kind string, assetName string, resource interface{}) error {
+	if j == nil {
+		return nil
+	}
+	if j.sealer != nil {
+		sealed, _, err := j.sealer.Seal(resource)
+		if err != nil {
+			return fmt.Errorf("unable to protect the secrets of %v: %v", kind, err)
+		}
+		resource = sealed
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	err := j.writer.Write(kind, assetName, resource)
+	if err != nil {
+		return fmt.Errorf("unable to write %v to export journal: %v", kind, err)
+	}
+	return nil
+}
+
+// WriteHeader writes the header of the journal. It must be written before any resource
+func (j *ExportJournal) WriteHeader(header *MigrationFileHeader) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.sealer != nil {
+		h := *header
+		h.Secrets = j.sealer.Envelope()
+		header = &h
+	}
+	return j.writer.WriteHeader(header)
+}
+
+// Close flushes the journal to disk and closes it
+func (j *ExportJournal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.writer.Flush(); err != nil {
+		j.file.Close()
+		return fmt.Errorf("unable to write export journal: %v", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		j.file.Close()
+		return fmt.Errorf("unable to write export journal: %v", err)
+	}
+	return j.file.Close()
+}
+
+// exportedItem is a single resource on its way from the Source to the journal and the import
+type exportedItem struct {
+	kind      string
+	assetName string
+	resource  interface{}
+}
+
+// Stream exports the selected resources from the Source and imports each one into the Destination as soon as it
+// has been read, without collecting them in a MigrationFileContents first. Every exported resource is also written
+// to the journal. Assets and StreamingLocators are read a page at a time, so the first ones are imported while the
+// rest are still being exported.
+func (p *Pipeline) Stream(ctx context.Context, journal *ExportJournal) ([]Result, error) {
+	timings := []Result{}
+
+	if p.Source == nil {
+		return timings, fmt.Errorf("export Error: no source to export from")
+	}
+	if p.Destination == nil {
+		return timings, fmt.Errorf("import Error: no mk.io subscription to import into")
+	}
+	// A couple of simple checks to avoid bad things
+	if p.Kinds.AssetFilters && !p.Kinds.Assets {
+		return timings, fmt.Errorf("AssetFilter export requires Asset export")
+	}
+
+	workers := p.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	exportRetries := retryStats(p.Source)
+	importRetries := retryStats(p.Destination)
+
+	// Keep the export a little ahead of the import, but not too far
+	items := make(chan exportedItem, workers*10)
+	exportTimings := []Result{}
+	go func() {
+		defer close(items)
+		exportTimings = p.exportItems(ctx, items)
+	}()
+
+	log.Info("Importing resources as they are exported")
+	s := newImportScheduler(ctx, p.Workers, p.Checkpoint)
+	for item := range items {
+		if err := journal.Write(item.kind, item.assetName, item.resource); err != nil {
+			log.Errorf("%v", err)
+		}
+
+		switch v := item.resource.(type) {
+		case *armmediaservices.ContentKeyPolicy:
+			s.Add(p.contentKeyPolicyNode(ctx, v))
+		case *armmediaservices.Asset:
+			p.Transformations.applyAsset(v)
+			s.Add(p.assetNode(ctx, v))
+		case *armmediaservices.AssetFilter:
+			s.Add(p.assetFilterNode(ctx, item.assetName, v))
+		case *armmediaservices.StreamingPolicy:
+			s.Add(p.streamingPolicyNode(ctx, v))
+		case *armmediaservices.StreamingLocator:
+			s.Add(p.streamingLocatorNode(ctx, v))
+		case *armmediaservices.StreamingEndpoint:
+			p.Transformations.applyStreamingEndpoint(v)
+			s.Add(p.streamingEndpointNode(ctx, v))
+		}
+	}
+	s.Close()
+	importTimings := s.Wait(p.importKinds())
+	addRetries(exportTimings, EXPORT, exportRetries, retryStats(p.Source))
+	addRetries(importTimings, IMPORT, importRetries, retryStats(p.Destination))
+
+	timings = append(timings, exportTimings...)
+	timings = append(timings, importTimings...)
+	return timings, nil
+}
+
+// exportItems reads the selected resources from the Source and sends them to items one at a time. Resources other
+// resources depend on are exported first, so their dependants don't have to wait for the end of the export.
+func (p *Pipeline) exportItems(ctx context.Context, items chan<- exportedItem) []Result {
+	timings := []Result{}
+
+	// Handle ContentKeyPolicies. These are used by StreamingPolicies and StreamingLocators
+	if p.Kinds.ContentKeyPolicies {
+		start := time.Now()
+		ckp, err := p.Source.ExportContentKeyPolicies(ctx, p.CreatedBefore, p.CreatedAfter)
+		if err != nil {
+			log.Errorf("error exporting content key policies: %v", err)
+		}
+		for _, v := range ckp {
+			items <- exportedItem{kind: CONTENTKEYPOLICIES, resource: v}
+		}
+		timings = append(timings, Result{Resource: CONTENTKEYPOLICIES, Operation: EXPORT, Duration: time.Since(start), Migrated: len(ckp), Err: err})
+	}
+
+	// Handle Streaming Policies. These are used by StreamingLocators
+	if p.Kinds.StreamingPolicies {
+		start := time.Now()
+		sp, err := p.Source.ExportStreamingPolicies(ctx, p.CreatedBefore, p.CreatedAfter)
+		if err != nil {
+			log.Errorf("error exporting streaming policies: %v", err)
+		}
+		for _, v := range sp {
+			items <- exportedItem{kind: STREAMINGPOLICIES, resource: v}
+		}
+		timings = append(timings, Result{Resource: STREAMINGPOLICIES, Operation: EXPORT, Duration: time.Since(start), Migrated: len(sp), Err: err})
+	}
+
+	// Handle Assets, and their Asset Filters a page at a time
+	if p.Kinds.Assets {
+		start := time.Now()
+		filtersDuration := time.Duration(0)
+		assetCount := 0
+		filterCount := 0
+		var filtersErr error
+		err := p.Source.StreamAssets(ctx, p.CreatedBefore, p.CreatedAfter, func(page []*armmediaservices.Asset) error {
+			for _, v := range page {
+				items <- exportedItem{kind: ASSETS, resource: v}
+			}
+			assetCount += len(page)
+			log.Infof("Exported %d assets so far", assetCount)
+
+			if p.Kinds.AssetFilters {
+				filtersStart := time.Now()
+				assetFilters, err := p.Source.ExportAssetFilters(ctx, page, p.Workers)
+				if err != nil {
+					log.Errorf("error exporting asset filters: %v", err)
+					if filtersErr == nil {
+						filtersErr = err
+					}
+				}
+				for assetName, filters := range assetFilters {
+					for _, v := range filters {
+						items <- exportedItem{kind: ASSETFILTERS, assetName: assetName, resource: v}
+					}
+					filterCount += len(filters)
+				}
+				filtersDuration += time.Since(filtersStart)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Errorf("error exporting assets: %v", err)
+		}
+		timings = append(timings, Result{Resource: ASSETS, Operation: EXPORT, Duration: time.Since(start) - filtersDuration, Migrated: assetCount, Err: err})
+		if p.Kinds.AssetFilters {
+			timings = append(timings, Result{Resource: ASSETFILTERS, Operation: EXPORT, Duration: filtersDuration, Migrated: filterCount, Err: filtersErr})
+		}
+	}
+
+	// Handle StreamingLocators, and their Content Keys a page at a time
+	if p.Kinds.StreamingLocators {
+		start := time.Now()
+		keysDuration := time.Duration(0)
+		count := 0
+		keysCount := 0
+		var keysErr error
+		err := p.Source.StreamStreamingLocators(ctx, p.CreatedBefore, p.CreatedAfter, func(page []*armmediaservices.StreamingLocator) error {
+			count += len(page)
+			keysStart := time.Now()
+			page, err := p.Source.ExportContentKeys(ctx, page, p.Workers)
+			if err != nil {
+				log.Errorf("error exporting streaming locators Content Keys: %v", err)
+				if keysErr == nil {
+					keysErr = err
+				}
+			}
+			// Without their keys mk.io would generate new ones, so they are neither written nor imported
+			page = withoutFailedContentKeys(page, err)
+			keysDuration += time.Since(keysStart)
+
+			for _, v := range page {
+				items <- exportedItem{kind: STREAMINGLOCATORS, resource: v}
+			}
+			keysCount += len(page)
+			log.Infof("Exported %d streaming locators so far", count)
+			return nil
+		})
+		if err != nil {
+			log.Errorf("error exporting streaming locators: %v", err)
+		}
+		timings = append(timings, Result{Resource: STREAMINGLOCATORS, Operation: EXPORT, Duration: time.Since(start) - keysDuration, Migrated: count, Err: err})
+		timings = append(timings, Result{Resource: CONTENTKEYS, Operation: EXPORT, Duration: keysDuration, Migrated: keysCount, Err: keysErr})
+	}
+
+	// Handle StreamingEndpoints.
+	if p.Kinds.StreamingEndpoints {
+		start := time.Now()
+		se, err := p.Source.ExportStreamingEndpoints(ctx)
+		if err != nil {
+			log.Errorf("error exporting streaming endpoints: %v", err)
+		}
+		for _, v := range se {
+			items <- exportedItem{kind: STREAMINGENDPOINTS, resource: v}
+		}
+		timings = append(timings, Result{Resource: STREAMINGENDPOINTS, Operation: EXPORT, Duration: time.Since(start), Migrated: len(se), Err: err})
+	}
+
+	return timings
+}
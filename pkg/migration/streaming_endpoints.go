@@ -3,7 +3,6 @@ package migrate
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
@@ -36,86 +35,127 @@ func ExportMkStreamingEndpoints(ctx context.Context, client *mkiosdk.StreamingEn
 	return se, nil
 }
 
-// ImportStreamingEndpoints reads a file containing StreamingEndpoints in JSON format. Insert each asset into MKIO
-func ImportStreamingEndpoints(ctx context.Context, client *mkiosdk.StreamingEndpointsClient, streamingEndpoints []*armmediaservices.StreamingEndpoint, overwrite bool) (int, int, []string, error) {
-	log.Info("Importing Streaming Endpoints")
+// CdnPolicy is what import does with a StreamingEndpoint whose CDN provider mk.io doesn't support
+type CdnPolicy string
+
+const (
+	// CdnPrompt asks with Pipeline.ConfirmCdn whether to change the CDN provider to Akamai, and keeps it otherwise
+	CdnPrompt CdnPolicy = "prompt"
+	// CdnAkamai changes the CDN provider to Akamai
+	CdnAkamai CdnPolicy = "akamai"
+	// CdnSkip leaves the StreamingEndpoint out
+	CdnSkip CdnPolicy = "skip"
+	// CdnFail fails the import of the StreamingEndpoint
+	CdnFail CdnPolicy = "fail"
+)
+
+// CdnPolicies are the valid CdnPolicy values
+var CdnPolicies = []string{string(CdnPrompt), string(CdnAkamai), string(CdnSkip), string(CdnFail)}
+
+// unsupportedCdn applies UnsupportedCdn to a StreamingEndpoint whose CDN provider mk.io doesn't support. Returns
+// false if the StreamingEndpoint is left out
+func (p *Pipeline) unsupportedCdn(se *armmediaservices.StreamingEndpoint) (bool, error) {
+	provider := *se.Properties.CdnProvider
+	switch p.UnsupportedCdn {
+	case CdnAkamai:
+	case CdnSkip:
+		log.Warnf("Skipping StreamingEndpoint %v, mk.io doesn't support CDN provider %v", *se.Name, provider)
+		return false, nil
+	case CdnPrompt:
+		if p.ConfirmCdn == nil {
+			return false, fmt.Errorf("no way to ask whether to change CDN provider %v of StreamingEndpoint %v to Akamai", provider, *se.Name)
+		}
+		if !p.ConfirmCdn(*se.Name, provider) {
+			return true, nil
+		}
+	default:
+		return false, fmt.Errorf("mk.io doesn't support CDN provider %v of StreamingEndpoint %v", provider, *se.Name)
+	}
+	log.Infof("Setting CDN Provider to StandardAkamai for %v", *se.Name)
+	akamai := "StandardAkamai"
+	se.Properties.CdnProvider = &akamai
+	return true, nil
+}
 
-	// Some values to output at the end
-	successCount := 0
-	skipped := 0
-	failedSE := []string{}
+// importStreamingEndpoint imports a single StreamingEndpoint into MKIO and records the outcome in the checkpoint store.
+// Returns true if the StreamingEndpoint already existed and was skipped. unsupportedCdn decides what happens to
+// StreamingEndpoints with a CDN provider mk.io doesn't support
+func importStreamingEndpoint(ctx context.Context, client *mkiosdk.StreamingEndpointsClient, se *armmediaservices.StreamingEndpoint, overwrite bool, skipUnchanged bool, unsupportedCdn func(*armmediaservices.StreamingEndpoint) (bool, error), checkpoint *CheckpointStore, snapshots *SnapshotStore) (bool, error) {
+	// Check if StreamingEndpoint already exists. We can't update them, so need to delete and recreate
+	existing, err := client.Get(ctx, *se.Name, nil)
+	found := err == nil
+	if err != nil && !mkiosdk.IsNotFound(err) {
+		log.Errorf("unable to look up StreamingEndpoint %v: %v", *se.Name, err)
+		checkpoint.Record(STREAMINGENDPOINTS, "", *se.Name, StatusFailed, "", err)
+		return false, err
+	}
+
+	if found && !overwrite {
+		// Found something and we're not overwriting. We should skip it
+		checkpoint.Record(STREAMINGENDPOINTS, "", *se.Name, StatusSkipped, ImportExisting, nil)
+		return true, nil
+	}
+
+	// Location mismatch between Azure and MKIO
+	if *se.Location == "East US" {
+		log.Debugf("Location mismatch for %v. Setting to eastus", *se.Name)
+		eastus := "eastus"
+		se.Location = &eastus
+	} else if *se.Location == "West US 2" {
+		log.Debugf("Location mismatch for %v. Setting to westus2", *se.Name)
+		westus := "westus2"
+		se.Location = &westus
+	} else if *se.Location == "West Europe" {
+		log.Debugf("Location mismatch for %v. Setting to westeurope", *se.Name)
+		westeurope := "westeurope"
+		se.Location = &westeurope
+	}
 
-	// Create each streamingEndpoint
-	for _, se := range streamingEndpoints {
-		found := true
-		// Check if StreamingEndpoint already exists. We can't update them, so need to delete and recreate
-		_, err := client.Get(ctx, *se.Name, nil)
+	// Not supported CDN Provider. Decided before anything is deleted
+	if se.Properties.CdnProvider != nil && *se.Properties.CdnProvider != "Akamai" && *se.Properties.CdnProvider != "StandardAkamai" {
+		keep, err := unsupportedCdn(se)
 		if err != nil {
-			// We are looking for a not found error. If we get this we can add w/o incident
-			if strings.Contains(err.Error(), "not found") {
-				found = false
-			}
+			log.Errorf("unable to import streamingEndpoint %v: %v", *se.Name, err)
+			checkpoint.Record(STREAMINGENDPOINTS, "", *se.Name, StatusFailed, "", err)
+			return false, err
 		}
-
-		if found && !overwrite {
-			// Found something and we're not overwriting. We should skip it
-			skipped++
-			continue
+		if !keep {
+			checkpoint.Record(STREAMINGENDPOINTS, "", *se.Name, StatusSkipped, "", nil)
+			return true, nil
 		}
+	}
 
-		if found && overwrite {
-			// it exists, but we're overwriting, so we should delete it
-			_, err := client.Delete(ctx, *se.Name, nil)
-			if err != nil {
-				log.Errorf("unable to delete old StreamingEndpoint %v for overwrite: %v", *se.Name, err)
-			}
-		}
+	if found && skipUnchanged && sameResource(&existing.StreamingEndpoint, se) {
+		log.Debugf("StreamingEndpoint is the same in MKIO, skipping: %v", *se.Name)
+		checkpoint.Record(STREAMINGENDPOINTS, "", *se.Name, StatusSkipped, ImportExisting, nil)
+		return true, nil
+	}
 
-		// We don't have an existing resource... We can create one
-		log.Debugf("Creating StreamingEndpoint in MKIO: %v", *se.Name)
-
-		// Location mismatch between Azure and MKIO
-		if *se.Location == "East US" {
-			log.Debugf("Location mismatch for %v. Setting to eastus", *se.Name)
-			eastus := "eastus"
-			se.Location = &eastus
-		} else if *se.Location == "West US 2" {
-			log.Debugf("Location mismatch for %v. Setting to westus2", *se.Name)
-			westus := "westus2"
-			se.Location = &westus
-		} else if *se.Location == "West Europe" {
-			log.Debugf("Location mismatch for %v. Setting to westeurope", *se.Name)
-			westeurope := "westeurope"
-			se.Location = &westeurope
+	if found && overwrite {
+		// Keep a copy of the StreamingEndpoint we're about to delete
+		err = snapshots.saveExisting(STREAMINGENDPOINTS, "", *se.Name, existing.StreamingEndpoint, err)
+		if err != nil {
+			log.Errorf("not overwriting StreamingEndpoint %v: %v", *se.Name, err)
+			checkpoint.Record(STREAMINGENDPOINTS, "", *se.Name, StatusFailed, "", err)
+			return false, err
 		}
 
-		// Not supported CDN Provider. Set to Akamai, with user input
-		if se.Properties.CdnProvider != nil && *se.Properties.CdnProvider != "Akamai" {
-			log.Info("CDN Provider mismatch. User input required")
-			var setProvider string
-			fmt.Printf("CDN Provider mismatch for %v. Change to Akamai [y/N]\n", *se.Name)
-			fmt.Scan(&setProvider)
-			if setProvider == "y" || setProvider == "Y" {
-				log.Infof("Setting CDN Provider to StandardAkamai for %v", *se.Name)
-				akamai := "StandardAkamai"
-				se.Properties.CdnProvider = &akamai
-			}
-		}
-		_, err = client.CreateOrUpdate(ctx, *se.Name, *se, nil)
+		// it exists, but we're overwriting, so we should delete it
+		_, err := client.Delete(ctx, *se.Name, nil)
 		if err != nil {
-			failedSE = append(failedSE, *se.Name)
-
-			log.Errorf("unable to import streamingEndpoint %v: %v", *se.Name, err)
-		} else {
-			successCount++
+			log.Errorf("unable to delete old StreamingEndpoint %v for overwrite: %v", *se.Name, err)
 		}
 	}
 
-	log.Infof("Skipped %d existing streamingEndpoints", skipped)
-	log.Infof("Imported %d streamingEndpoints", successCount)
+	// We don't have an existing resource... We can create one
+	log.Debugf("Creating StreamingEndpoint in MKIO: %v", *se.Name)
 
-	if len(failedSE) > 0 {
-		return successCount, skipped, failedSE, fmt.Errorf("failed to import %d StreamingEndpoints: %v", len(failedSE), failedSE)
+	_, err = client.CreateOrUpdate(ctx, *se.Name, *se, nil)
+	if err != nil {
+		log.Errorf("unable to import streamingEndpoint %v: %v", *se.Name, err)
+		checkpoint.Record(STREAMINGENDPOINTS, "", *se.Name, StatusFailed, "", err)
+		return false, err
 	}
-	return successCount, skipped, failedSE, nil
+	checkpoint.Record(STREAMINGENDPOINTS, "", *se.Name, StatusImported, importAction(found), nil)
+	return false, nil
 }
@@ -2,6 +2,7 @@ package migrate
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -77,12 +78,45 @@ func ExportAzContentKeys(ctx context.Context, azSp *AzureServiceProvider, stream
 	}
 
 	if len(skipped) > 0 {
-		return streamingLocators, fmt.Errorf("failed to export %d Content Keys: %v", len(skipped), skipped)
+		return streamingLocators, &ContentKeysError{Locators: skipped}
 	}
 
 	return streamingLocators, nil
 }
 
+// ContentKeysError is returned when the Content Keys of some StreamingLocators couldn't be exported. Those
+// StreamingLocators must not be imported, mk.io would give them new keys and the content would stop playing
+type ContentKeysError struct {
+	Locators []string
+}
+
+func (e *ContentKeysError) Error() string {
+	return fmt.Sprintf("failed to export %d Content Keys: %v", len(e.Locators), e.Locators)
+}
+
+// withoutFailedContentKeys drops the StreamingLocators whose Content Keys couldn't be exported. All of them are
+// dropped if err doesn't say which failed
+func withoutFailedContentKeys(streamingLocators []*armmediaservices.StreamingLocator, err error) []*armmediaservices.StreamingLocator {
+	if err == nil {
+		return streamingLocators
+	}
+	keysErr := &ContentKeysError{}
+	if !errors.As(err, &keysErr) {
+		return []*armmediaservices.StreamingLocator{}
+	}
+	failed := map[string]bool{}
+	for _, name := range keysErr.Locators {
+		failed[name] = true
+	}
+	kept := []*armmediaservices.StreamingLocator{}
+	for _, sl := range streamingLocators {
+		if !failed[*sl.Name] {
+			kept = append(kept, sl)
+		}
+	}
+	return kept
+}
+
 // ExportMkStreamingLocators creates a file containing all StreamingLocators from a mk.io Subscription
 func ExportMkStreamingLocators(ctx context.Context, client *mkiosdk.StreamingLocatorsClient, before string, after string) ([]*armmediaservices.StreamingLocator, error) {
 	log.Info("Exporting Streaming Locators")
@@ -96,118 +130,65 @@ func ExportMkStreamingLocators(ctx context.Context, client *mkiosdk.StreamingLoc
 	return sl, nil
 }
 
-// ImportStreamingLocatorWorker - Do the work to import Streaming Locators into MKIO
-func ImportStreamingLocatorWorker(ctx context.Context, client *mkiosdk.StreamingLocatorsClient, overwrite bool, wg *sync.WaitGroup, jobs <-chan *armmediaservices.StreamingLocator, successChan chan<- string, skippedChan chan<- string, failedChan chan<- string) {
-	for sl := range jobs {
-		found := true
-		// Check if StreamingLocator already exists. We can't update them, so need to delete and recreate
-		_, err := client.Get(ctx, *sl.Name, nil)
-		if err != nil {
-			// We are looking for a not found error. If we get this we can add w/o incident
-			if strings.Contains(err.Error(), "not found") {
-				found = false
-			}
-		}
-
-		if found && !overwrite {
-			// Found something and we're not overwriting. We should skip it
-			log.Debugf("Skipping Existing StreamingLocator: %v", *sl.Name)
-			skippedChan <- *sl.Name
-			wg.Done()
-			continue
-		}
-
-		if found && overwrite {
-			// it exists, but we're overwriting, so we should delete it
-			log.Debugf("Deleting existing StreamingLocator: %v", *sl.Name)
-			_, err := client.Delete(ctx, *sl.Name, nil)
-			if err != nil {
-				log.Errorf("unable to delete old StreamingLocator %v for overwrite: %v", *sl.Name, err)
-				failedChan <- *sl.Name
-			}
-		}
+// importStreamingLocator imports a single StreamingLocator into MKIO and records the outcome in the checkpoint store.
+// Returns true if the StreamingLocator already existed and was skipped.
+func importStreamingLocator(ctx context.Context, client *mkiosdk.StreamingLocatorsClient, sl *armmediaservices.StreamingLocator, overwrite bool, skipUnchanged bool, checkpoint *CheckpointStore, snapshots *SnapshotStore) (bool, error) {
+	// Check if StreamingLocator already exists. We can't update them, so need to delete and recreate
+	existing, err := client.Get(ctx, *sl.Name, nil)
+	found := err == nil
+	if err != nil && !mkiosdk.IsNotFound(err) {
+		log.Errorf("unable to look up StreamingLocator %v: %v", *sl.Name, err)
+		checkpoint.Record(STREAMINGLOCATORS, "", *sl.Name, StatusFailed, "", err)
+		return false, err
+	}
 
-		// We don't have an existing resource... We can create one
-		log.Debugf("Creating StreamingLocator in MKIO: %v", *sl.Name)
+	if found && !overwrite {
+		// Found something and we're not overwriting. We should skip it
+		log.Debugf("Skipping Existing StreamingLocator: %v", *sl.Name)
+		checkpoint.Record(STREAMINGLOCATORS, "", *sl.Name, StatusSkipped, ImportExisting, nil)
+		return true, nil
+	}
+	if found && skipUnchanged && sameResource(&existing.StreamingLocator, sl) {
+		log.Debugf("StreamingLocator is the same in MKIO, skipping: %v", *sl.Name)
+		checkpoint.Record(STREAMINGLOCATORS, "", *sl.Name, StatusSkipped, ImportExisting, nil)
+		return true, nil
+	}
 
-		if strings.HasPrefix(*sl.Properties.StreamingPolicyName, "Predefined_") {
-			log.Infof("removing customer ContentKeys from StreamingLocator with Predefined Streaming Policy: %v", *sl.Name)
-			sl.Properties.ContentKeys = nil
+	if found && overwrite {
+		// Keep a copy of the StreamingLocator we're about to delete
+		err = snapshots.saveExisting(STREAMINGLOCATORS, "", *sl.Name, existing.StreamingLocator, nil)
+		if err != nil {
+			log.Errorf("not overwriting StreamingLocator %v: %v", *sl.Name, err)
+			checkpoint.Record(STREAMINGLOCATORS, "", *sl.Name, StatusFailed, "", err)
+			return false, err
 		}
 
-		_, err = client.CreateOrUpdate(ctx, *sl.Name, *sl, nil)
+		// it exists, but we're overwriting, so we should delete it
+		log.Debugf("Deleting existing StreamingLocator: %v", *sl.Name)
+		_, err := client.Delete(ctx, *sl.Name, nil)
 		if err != nil {
-			failedChan <- *sl.Name
-
-			log.Errorf("unable to import streamingLocator %v: %v", *sl.Name, err)
-		} else {
-			successChan <- *sl.Name
+			log.Errorf("unable to delete old StreamingLocator %v for overwrite: %v", *sl.Name, err)
+			checkpoint.Record(STREAMINGLOCATORS, "", *sl.Name, StatusFailed, "", err)
+			return false, err
 		}
-		wg.Done()
 	}
-}
-
-// ImportStreamingLocators reads a file containing StreamingLocators in JSON format. Insert each asset into MKIO
-func ImportStreamingLocators(ctx context.Context, client *mkiosdk.StreamingLocatorsClient, streamingLocators []*armmediaservices.StreamingLocator, overwrite bool, workers int) (int, int, []string, error) {
 
-	log.Info("Importing Streaming Locators")
+	// We don't have an existing resource... We can create one
+	log.Debugf("Creating StreamingLocator in MKIO: %v", *sl.Name)
 
-	// Waitgroup to wait for all goroutines to finish
-	wg := new(sync.WaitGroup)
-
-	// Create channels to communicate between workers
-	successChan := make(chan string, len(streamingLocators))
-	skippedChan := make(chan string, len(streamingLocators))
-	failedChan := make(chan string, len(streamingLocators))
-	jobs := make(chan *armmediaservices.StreamingLocator, len(streamingLocators))
-
-	// Setup worker pool. This will start X workers to handle jobs
-	for w := 1; w <= workers; w++ {
-		log.Infof("Starting Streaming Locator worker %d", w)
-		go ImportStreamingLocatorWorker(ctx, client, overwrite, wg, jobs, successChan, skippedChan, failedChan)
-	}
-
-	failedSL := []string{}
-	skipped := 0
-	successCount := 0
-
-	// Create each StreamingLocator
-	for _, sl := range streamingLocators {
-		wg.Add(1)
-		jobs <- sl
-	}
-
-	log.Info("Waiting for Streaming Locator workers to finish")
-	wg.Wait()
-	log.Info("Done importing Streaming Locators")
-
-	close(jobs)
-	close(successChan)
-	close(skippedChan)
-	close(failedChan)
-	for f := range successChan {
-		if f != "" {
-			successCount++
-		}
-	}
-	for result := range skippedChan {
-		if result != "" {
-			skipped++
-		}
-	}
-	for result := range failedChan {
-		if result != "" {
-			failedSL = append(failedSL, result)
-		}
+	if strings.HasPrefix(*sl.Properties.StreamingPolicyName, "Predefined_") {
+		log.Infof("removing customer ContentKeys from StreamingLocator with Predefined Streaming Policy: %v", *sl.Name)
+		sl.Properties.ContentKeys = nil
 	}
 
-	log.Infof("Skipped %d existing streamingLocators", skipped)
-	log.Infof("Imported %d streamingLocators", successCount)
-
-	if len(failedSL) > 0 {
-		return successCount, skipped, failedSL, fmt.Errorf("failed to import %d StreamingLocators: %v", len(failedSL), failedSL)
+	_, err = client.CreateOrUpdate(ctx, *sl.Name, *sl, nil)
+	if err != nil {
+		log.Errorf("unable to import streamingLocator %v: %v", *sl.Name, err)
+		checkpoint.Record(STREAMINGLOCATORS, "", *sl.Name, StatusFailed, "", err)
+		return false, err
 	}
-	return successCount, skipped, failedSL, nil
+	checkpoint.Record(STREAMINGLOCATORS, "", *sl.Name, StatusImported, importAction(found), nil)
+	return false, nil
 }
 
 // ValidateStreamingLocators validates that streaming locators exist in MKIO and produce output.
@@ -241,18 +222,15 @@ func ValidateStreamingLocators(ctx context.Context, slClient *mkiosdk.StreamingL
 	httpClient := &http.Client{}
 
 	for _, sl := range streamingLocators {
-		found := true
 		// Check if streaming locator exists in MKIO
 		_, err := slClient.Get(ctx, *sl.Name, nil)
-		if err != nil {
-			if strings.Contains(err.Error(), "not found") {
-				found = false
-				// Probably important that we expect it but can't find it
-				missingSL = append(missingSL, *sl.Name)
-			}
-		}
-
-		if found {
+		if mkiosdk.IsNotFound(err) {
+			// Probably important that we expect it but can't find it
+			missingSL = append(missingSL, *sl.Name)
+		} else if err != nil {
+			log.Errorf("unable to look up streamingLocator %v: %v", *sl.Name, err)
+			failedSL = append(failedSL, *sl.Name)
+		} else {
 			log.Debugf("Found StreamingLocator in MKIO: %s", *sl.Name)
 			resp, err := slClient.ListPaths(ctx, *sl.Name, nil)
 			if err != nil {
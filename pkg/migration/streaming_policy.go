@@ -3,8 +3,6 @@ package migrate
 import (
 	"context"
 	"fmt"
-	"strings"
-	"sync"
 
 	"dev.azure.com/mediakind/mkio/ams-migration-tool.git/pkg/mkiosdk"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
@@ -37,111 +35,56 @@ func ExportMkStreamingPolicies(ctx context.Context, client *mkiosdk.StreamingPol
 	return sl, nil
 }
 
-// ImportStreamingPolicyWorker - Do the work to import a StreamingPolicy into MKIO
-func ImportStreamingPolicyWorker(ctx context.Context, client *mkiosdk.StreamingPoliciesClient, overwrite bool, wg *sync.WaitGroup, jobs chan *armmediaservices.StreamingPolicy, successChan chan string, skippedChan chan string, failedChan chan string) {
-
-	// Create each streamingPolicy
-	for sp := range jobs {
-		log.Debugf("Importing StreamingPolicy in MKIO: %v", *sp.Name)
-
-		found := true
-		// Check if StreamingPolicy already exists. We can't update them, so need to delete and recreate
-		_, err := client.Get(ctx, *sp.Name, nil)
-		if err != nil {
-			// We are looking for a not found error. If we get this we can add w/o incident
-			if strings.Contains(err.Error(), "not found") {
-				found = false
-			}
-		}
-
-		if found && !overwrite {
-			// Found something and we're not overwriting. We should skip it
-			skippedChan <- *sp.Name
-			wg.Done()
-			continue
-		}
-
-		if found && overwrite {
-			// it exists, but we're overwriting, so we should delete it
-			_, err := client.Delete(ctx, *sp.Name, nil)
-			if err != nil {
-				log.Errorf("unable to delete old StreamingPolicy %v for overwrite: %v", *sp.Name, err)
-			}
-		}
-
-		// We don't have an existing resource... We can create one
-		log.Debugf("Creating StreamingPolicy in MKIO: %v", *sp.Name)
-
-		_, err = client.CreateOrUpdate(ctx, *sp.Name, *sp, nil)
-		if err != nil {
-			failedChan <- *sp.Name
-			log.Errorf("unable to import streamingPolicy %v: %v", *sp.Name, err)
-		} else {
-			successChan <- *sp.Name
-		}
-		wg.Done()
+// importStreamingPolicy imports a single StreamingPolicy into MKIO and records the outcome in the checkpoint store.
+// Returns true if the StreamingPolicy already existed and was skipped.
+func importStreamingPolicy(ctx context.Context, client *mkiosdk.StreamingPoliciesClient, sp *armmediaservices.StreamingPolicy, overwrite bool, skipUnchanged bool, checkpoint *CheckpointStore, snapshots *SnapshotStore) (bool, error) {
+	log.Debugf("Importing StreamingPolicy in MKIO: %v", *sp.Name)
+
+	// Check if StreamingPolicy already exists. We can't update them, so need to delete and recreate
+	existing, err := client.Get(ctx, *sp.Name, nil)
+	found := err == nil
+	if err != nil && !mkiosdk.IsNotFound(err) {
+		log.Errorf("unable to look up StreamingPolicy %v: %v", *sp.Name, err)
+		checkpoint.Record(STREAMINGPOLICIES, "", *sp.Name, StatusFailed, "", err)
+		return false, err
 	}
-}
 
-// ImportStreamingPolicies reads a file containing StreamingPolicies in JSON format. Insert each streaming policy into MKIO
-func ImportStreamingPolicies(ctx context.Context, client *mkiosdk.StreamingPoliciesClient, streamingPolicies []*armmediaservices.StreamingPolicy, overwrite bool, workers int) (int, int, []string, error) {
-	log.Info("Importing Streaming Policy")
-
-	// Waitgroup to wait for all goroutines to finish
-	wg := new(sync.WaitGroup)
-
-	// Create channels to communicate between workers
-	successChan := make(chan string, len(streamingPolicies))
-	skippedChan := make(chan string, len(streamingPolicies))
-	failedChan := make(chan string, len(streamingPolicies))
-	jobs := make(chan *armmediaservices.StreamingPolicy, len(streamingPolicies))
-
-	// Setup worker pool. This will start X workers to handle jobs
-	for w := 1; w <= workers; w++ {
-		log.Infof("Starting StreamingPolicy worker %d", w)
-		go ImportStreamingPolicyWorker(ctx, client, overwrite, wg, jobs, successChan, skippedChan, failedChan)
+	if found && !overwrite {
+		// Found something and we're not overwriting. We should skip it
+		checkpoint.Record(STREAMINGPOLICIES, "", *sp.Name, StatusSkipped, ImportExisting, nil)
+		return true, nil
 	}
-
-	// Create each streamingPolicy
-	for _, sp := range streamingPolicies {
-		wg.Add(1)
-		jobs <- sp
+	if found && skipUnchanged && sameResource(&existing.StreamingPolicy, sp) {
+		log.Debugf("StreamingPolicy is the same in MKIO, skipping: %v", *sp.Name)
+		checkpoint.Record(STREAMINGPOLICIES, "", *sp.Name, StatusSkipped, ImportExisting, nil)
+		return true, nil
 	}
 
-	// Some values to output at the end
-	successCount := 0
-	skipped := 0
-	failedSP := []string{}
-
-	log.Info("Waiting for Streaming Policy workers to finish")
-	wg.Wait()
-	log.Info("Done importing Streaming Policies")
-
-	close(jobs)
-	close(successChan)
-	close(skippedChan)
-	close(failedChan)
-	for f := range successChan {
-		if f != "" {
-			successCount++
-		}
-	}
-	for result := range skippedChan {
-		if result != "" {
-			skipped++
+	if found && overwrite {
+		// Keep a copy of the StreamingPolicy we're about to delete
+		err = snapshots.saveExisting(STREAMINGPOLICIES, "", *sp.Name, existing.StreamingPolicy, err)
+		if err != nil {
+			log.Errorf("not overwriting StreamingPolicy %v: %v", *sp.Name, err)
+			checkpoint.Record(STREAMINGPOLICIES, "", *sp.Name, StatusFailed, "", err)
+			return false, err
 		}
-	}
-	for result := range failedChan {
-		if result != "" {
-			failedSP = append(failedSP, result)
+
+		// it exists, but we're overwriting, so we should delete it
+		_, err := client.Delete(ctx, *sp.Name, nil)
+		if err != nil {
+			log.Errorf("unable to delete old StreamingPolicy %v for overwrite: %v", *sp.Name, err)
 		}
 	}
 
-	log.Infof("Skipped %d existing streamingPolicy", skipped)
-	log.Infof("Imported %d streamingPolicies", successCount)
+	// We don't have an existing resource... We can create one
+	log.Debugf("Creating StreamingPolicy in MKIO: %v", *sp.Name)
 
-	if len(failedSP) > 0 {
-		return successCount, skipped, failedSP, fmt.Errorf("failed to import %d StreamingPolicies: %v", len(failedSP), failedSP)
+	_, err = client.CreateOrUpdate(ctx, *sp.Name, *sp, nil)
+	if err != nil {
+		log.Errorf("unable to import streamingPolicy %v: %v", *sp.Name, err)
+		checkpoint.Record(STREAMINGPOLICIES, "", *sp.Name, StatusFailed, "", err)
+		return false, err
 	}
-	return successCount, skipped, failedSP, nil
+	checkpoint.Record(STREAMINGPOLICIES, "", *sp.Name, StatusImported, importAction(found), nil)
+	return false, nil
 }
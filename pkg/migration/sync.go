@@ -0,0 +1,209 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SyncStatus is the state of a Syncer, as reported by its health endpoints
+type SyncStatus struct {
+	Source string `json:"source"`
+	// Cycles counts the sync cycles run so far, Running is set while one is in progress
+	Cycles  int  `json:"cycles"`
+	Running bool `json:"running"`
+	// Ready is set once a cycle has completed without errors, and cleared by a cycle that fails
+	Ready       bool       `json:"ready"`
+	Stopping    bool       `json:"stopping"`
+	LastStart   *time.Time `json:"lastStart,omitempty"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+	// Migrated and Failed are the resources imported and failed by the last cycle
+	Migrated int `json:"migrated"`
+	Failed   int `json:"failed"`
+	// Watermarks are the current watermarks of the source, per resource type
+	Watermarks map[string]time.Time `json:"watermarks"`
+}
+
+// Syncer keeps mk.io in sync with a source during a cut-over. Every interval it exports the resources created or
+// modified since its watermarks, updates them in mk.io unless they are the same there, and moves the watermarks on
+type Syncer struct {
+	pipeline      *Pipeline
+	source        MigrationSource
+	watermarks    Watermarks
+	watermarkFile string
+	interval      time.Duration
+
+	mu     sync.Mutex
+	status SyncStatus
+}
+
+// NewSyncer creates a Syncer for the Source and Destination of p. The watermarks are read from and saved to
+// watermarkFile, under source
+func NewSyncer(p *Pipeline, source MigrationSource, watermarkFile string, interval time.Duration) (*Syncer, error) {
+	if p.Source == nil || p.Destination == nil {
+		return nil, fmt.Errorf("sync needs a source and an mk.io subscription to import into")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("sync interval must be positive, got %v", interval)
+	}
+	watermarks, err := ReadWatermarks(watermarkFile)
+	if err != nil {
+		return nil, err
+	}
+	// Resources mk.io already has as they are in the source aren't replaced
+	p.SkipUnchanged = true
+	return &Syncer{
+		pipeline:      p,
+		source:        source,
+		watermarks:    watermarks,
+		watermarkFile: watermarkFile,
+		interval:      interval,
+		status:        SyncStatus{Source: source.String(), Watermarks: watermarks.Since(source)},
+	}, nil
+}
+
+// Run syncs every interval until ctx is done. A cycle in progress is cancelled with ctx. Its watermarks don't move,
+// so the next run picks up what it didn't finish
+func (s *Syncer) Run(ctx context.Context) {
+	// Report that we are going away as soon as we are asked to
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		s.status.Stopping = true
+		s.mu.Unlock()
+	}()
+
+	for {
+		s.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Info("Sync stopped")
+			return
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// RunOnce runs a single sync cycle
+func (s *Syncer) RunOnce(ctx context.Context) {
+	start := time.Now().UTC()
+	s.mu.Lock()
+	s.status.Cycles++
+	s.status.Running = true
+	s.status.LastStart = &start
+	cycle := s.status.Cycles
+	s.mu.Unlock()
+
+	log.Infof("Sync cycle %d of %v", cycle, s.source)
+	results, err := s.sync(ctx)
+
+	migrated, failed := 0, 0
+	for _, v := range results {
+		if v.Operation != IMPORT {
+			continue
+		}
+		migrated += v.Migrated
+		failed += len(v.Failures)
+		for _, f := range v.Failures {
+			log.Errorf("sync cycle %d: failed to import %v %v: %v", cycle, f.Kind, f, f.Error)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = false
+	s.status.Migrated = migrated
+	s.status.Failed = failed
+	s.status.Watermarks = s.watermarks.Since(s.source)
+	if err != nil {
+		log.Errorf("sync cycle %d: %v", cycle, err)
+		s.status.Ready = false
+		s.status.LastError = err.Error()
+		return
+	}
+	end := time.Now().UTC()
+	s.status.Ready = true
+	s.status.LastSuccess = &end
+	s.status.LastError = ""
+	log.Infof("Sync cycle %d done: %d imported, %d failed", cycle, migrated, failed)
+}
+
+// sync exports and imports what changed and moves the watermarks on. Failed resources are not an error, their
+// watermarks stay put so they are tried again by the next cycle
+func (s *Syncer) sync(ctx context.Context) ([]Result, error) {
+	p := s.pipeline
+	p.ChangedSince = s.watermarks.Since(s.source)
+	// Until a resource type has been migrated once, what mk.io already has of it is left alone like migrate does
+	p.OverwriteChanged = true
+
+	contents, results, err := p.Export(ctx)
+	if err != nil {
+		return results, err
+	}
+	// Half an export would look like a complete one
+	if ctx.Err() != nil {
+		return results, fmt.Errorf("export interrupted: %v", ctx.Err())
+	}
+	for _, v := range results {
+		if v.Err != nil {
+			return results, fmt.Errorf("unable to export %v: %v", v.Resource, v.Err)
+		}
+	}
+
+	imported, err := p.Import(ctx, contents)
+	results = append(results, imported...)
+	if err != nil {
+		return results, err
+	}
+	if ctx.Err() != nil {
+		return results, fmt.Errorf("import interrupted: %v", ctx.Err())
+	}
+
+	moved := s.watermarks.Advance(s.source, contents, results)
+	if len(moved) > 0 {
+		if err := s.watermarks.Write(s.watermarkFile); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// Status returns the current state of the Syncer
+func (s *Syncer) Status() SyncStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	status.Watermarks = map[string]time.Time{}
+	for k, v := range s.status.Watermarks {
+		status.Watermarks[k] = v
+	}
+	return status
+}
+
+// Handler serves the health endpoints of the Syncer. /healthz fails once it is stopping, /readyz until a cycle has
+// completed without errors and while the last one failed. Both return the SyncStatus
+func (s *Syncer) Handler() http.Handler {
+	write := func(w http.ResponseWriter, ok bool) {
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(s.Status())
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		write(w, !s.Status().Stopping)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		status := s.Status()
+		write(w, status.Ready && !status.Stopping)
+	})
+	return mux
+}
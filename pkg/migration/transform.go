@@ -0,0 +1,58 @@
+package migrate
+
+import (
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+	log "github.com/sirupsen/logrus"
+)
+
+// Transformations are applied to the resources of a migration file before they are imported into mk.io
+type Transformations struct {
+	// StorageAccounts maps AMS storage account names to the storage account names in mk.io
+	StorageAccounts map[string]string `yaml:"storageAccounts" json:"storageAccounts,omitempty"`
+	// Locations maps StreamingEndpoint locations to mk.io locations, e.g. "North Europe": northeurope
+	Locations map[string]string `yaml:"locations" json:"locations,omitempty"`
+}
+
+// Apply rewrites the contents in place
+func (t *Transformations) Apply(contents *MigrationFileContents) {
+	if t == nil {
+		return
+	}
+
+	if len(t.StorageAccounts) > 0 {
+		count := 0
+		for _, asset := range contents.Assets {
+			if t.applyAsset(asset) {
+				count++
+			}
+		}
+		log.Infof("Changed the storage account of %d assets", count)
+	}
+
+	for _, se := range contents.StreamingEndpoints {
+		t.applyStreamingEndpoint(se)
+	}
+}
+
+// applyAsset rewrites the storage account of an asset. Returns true if it was changed
+func (t *Transformations) applyAsset(asset *armmediaservices.Asset) bool {
+	if t == nil || asset.Properties == nil || asset.Properties.StorageAccountName == nil {
+		return false
+	}
+	if name, ok := t.StorageAccounts[*asset.Properties.StorageAccountName]; ok {
+		asset.Properties.StorageAccountName = &name
+		return true
+	}
+	return false
+}
+
+// applyStreamingEndpoint rewrites the location of a StreamingEndpoint
+func (t *Transformations) applyStreamingEndpoint(se *armmediaservices.StreamingEndpoint) {
+	if t == nil || se.Location == nil {
+		return
+	}
+	if location, ok := t.Locations[*se.Location]; ok {
+		log.Debugf("Changing location of StreamingEndpoint %v from %v to %v", *se.Name, *se.Location, location)
+		se.Location = &location
+	}
+}
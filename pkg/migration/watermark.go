@@ -0,0 +1,188 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// Watermarks are the high-water marks of incremental migrations. For each source account and resource type, they
+// hold the time the newest resource migrated so far was created or last modified
+type Watermarks map[string]map[string]time.Time
+
+// String identifies the source account, e.g. azure/<subscription>/<resource group>/<account> or mkio/<subscription>
+func (s MigrationSource) String() string {
+	parts := []string{s.Kind}
+	for _, v := range []string{s.Subscription, s.ResourceGroup, s.AccountName} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// ReadWatermarks reads the watermarks file. A missing file has no watermarks, so everything is migrated
+func ReadWatermarks(fileName string) (Watermarks, error) {
+	w := Watermarks{}
+	data, err := os.ReadFile(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return w, fmt.Errorf("unable to read watermarks %v: %v", fileName, err)
+	}
+	if err := json.Unmarshal(data, &w); err != nil {
+		return w, fmt.Errorf("unable to parse watermarks %v: %v", fileName, err)
+	}
+	return w, nil
+}
+
+// Write saves the watermarks. The file is replaced in one go, so an interrupted write leaves the previous one
+func (w Watermarks) Write(fileName string) error {
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to write watermarks %v: %v", fileName, err)
+	}
+	tmp := fileName + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("unable to write watermarks %v: %v", fileName, err)
+	}
+	if err := os.Rename(tmp, fileName); err != nil {
+		return fmt.Errorf("unable to write watermarks %v: %v", fileName, err)
+	}
+	return nil
+}
+
+// Since returns the watermarks of a source, by resource type. Types that were never migrated have none
+func (w Watermarks) Since(source MigrationSource) map[string]time.Time {
+	since := map[string]time.Time{}
+	for kind, t := range w[source.String()] {
+		since[kind] = t
+	}
+	return since
+}
+
+// Advance moves the watermarks of source up to the newest resources in contents. Only resource types that were
+// exported and imported without failures move, so failed resources are picked up again by the next run.
+// Watermarks never move back. Returns the resource types that moved
+func (w Watermarks) Advance(source MigrationSource, contents MigrationFileContents, results []Result) []string {
+	failed := map[string]bool{}
+	for _, v := range results {
+		kind := v.Resource
+		// Content keys are exported with their StreamingLocators
+		if kind == CONTENTKEYS {
+			kind = STREAMINGLOCATORS
+		}
+		if v.Err != nil || len(v.Failures) > 0 {
+			failed[kind] = true
+		}
+	}
+
+	key := source.String()
+	if w[key] == nil {
+		w[key] = map[string]time.Time{}
+	}
+	moved := []string{}
+	marks := contents.HighWaterMarks()
+	for _, kind := range ImportOrder {
+		t, ok := marks[kind]
+		if !ok || failed[kind] || !t.After(w[key][kind]) {
+			continue
+		}
+		w[key][kind] = t
+		moved = append(moved, kind)
+	}
+	return moved
+}
+
+// HighWaterMarks returns the time the newest resource of each type was created or last modified. Types without
+// resources, or whose resources have no time, are left out
+func (contents MigrationFileContents) HighWaterMarks() map[string]time.Time {
+	marks := map[string]time.Time{}
+	mark := func(kind string, resource interface{}) {
+		if t := modifiedTime(resource); t.After(marks[kind]) {
+			marks[kind] = t
+		}
+	}
+	for _, v := range contents.Assets {
+		mark(ASSETS, v)
+	}
+	for _, filters := range contents.AssetFilters {
+		for _, v := range filters {
+			mark(ASSETFILTERS, v)
+		}
+	}
+	for _, v := range contents.ContentKeyPolicies {
+		mark(CONTENTKEYPOLICIES, v)
+	}
+	for _, v := range contents.StreamingEndpoints {
+		mark(STREAMINGENDPOINTS, v)
+	}
+	for _, v := range contents.StreamingLocators {
+		mark(STREAMINGLOCATORS, v)
+	}
+	for _, v := range contents.StreamingPolicies {
+		mark(STREAMINGPOLICIES, v)
+	}
+	return marks
+}
+
+// ChangedSince returns the resources created or modified after the time given for their type. Types without
+// a time are kept in full. Asset Filters without a time of their own are kept with their Asset
+func (contents MigrationFileContents) ChangedSince(since map[string]time.Time) MigrationFileContents {
+	changed := func(kind string, resource interface{}) bool {
+		mark, ok := since[kind]
+		// The newest resource of the last run is at the mark, and doesn't need to be migrated again
+		return !ok || modifiedTime(resource).After(mark)
+	}
+
+	delta := MigrationFileContents{Header: contents.Header}
+	keptAssets := map[string]bool{}
+	for _, v := range contents.Assets {
+		if changed(ASSETS, v) {
+			delta.Assets = append(delta.Assets, v)
+			keptAssets[resourceName(v.Name)] = true
+		}
+	}
+	_, filtersMarked := since[ASSETFILTERS]
+	for assetName, filters := range contents.AssetFilters {
+		for _, v := range filters {
+			keep := changed(ASSETFILTERS, v)
+			if filtersMarked && modifiedTime(v).IsZero() {
+				keep = keptAssets[assetName]
+			}
+			if keep {
+				if delta.AssetFilters == nil {
+					delta.AssetFilters = map[string][]*armmediaservices.AssetFilter{}
+				}
+				delta.AssetFilters[assetName] = append(delta.AssetFilters[assetName], v)
+			}
+		}
+	}
+	for _, v := range contents.ContentKeyPolicies {
+		if changed(CONTENTKEYPOLICIES, v) {
+			delta.ContentKeyPolicies = append(delta.ContentKeyPolicies, v)
+		}
+	}
+	for _, v := range contents.StreamingEndpoints {
+		if changed(STREAMINGENDPOINTS, v) {
+			delta.StreamingEndpoints = append(delta.StreamingEndpoints, v)
+		}
+	}
+	for _, v := range contents.StreamingLocators {
+		if changed(STREAMINGLOCATORS, v) {
+			delta.StreamingLocators = append(delta.StreamingLocators, v)
+		}
+	}
+	for _, v := range contents.StreamingPolicies {
+		if changed(STREAMINGPOLICIES, v) {
+			delta.StreamingPolicies = append(delta.StreamingPolicies, v)
+		}
+	}
+	return delta
+}
@@ -0,0 +1,222 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// testDay returns a time on the given day of June 2023
+func testDay(d int) time.Time {
+	return time.Date(2023, 6, d, 0, 0, 0, 0, time.UTC)
+}
+
+// testWatermarkContents returns assets a1, a2 and a3 modified on days 1, 2 and 3, a filter of each without a time,
+// a filter f4 of a1 modified on day 4, and locators l1 and l2 created on days 1 and 5
+func testWatermarkContents() MigrationFileContents {
+	contents := MigrationFileContents{AssetFilters: map[string][]*armmediaservices.AssetFilter{}}
+	for d := 1; d <= 3; d++ {
+		name := fmt.Sprintf("a%d", d)
+		contents.Assets = append(contents.Assets, &armmediaservices.Asset{Name: to.Ptr(name), Properties: &armmediaservices.AssetProperties{LastModified: to.Ptr(testDay(d))}})
+		contents.AssetFilters[name] = []*armmediaservices.AssetFilter{{Name: to.Ptr("f" + name)}}
+	}
+	contents.AssetFilters["a1"] = append(contents.AssetFilters["a1"], &armmediaservices.AssetFilter{Name: to.Ptr("f4"), SystemData: &armmediaservices.SystemData{LastModifiedAt: to.Ptr(testDay(4))}})
+	for _, d := range []int{1, 5} {
+		contents.StreamingLocators = append(contents.StreamingLocators, &armmediaservices.StreamingLocator{Name: to.Ptr(fmt.Sprintf("l%d", d)), Properties: &armmediaservices.StreamingLocatorProperties{Created: to.Ptr(testDay(d))}})
+	}
+	return contents
+}
+
+// testNames lists the names of the assets, filters and locators of contents, sorted
+func testNames(contents MigrationFileContents) string {
+	names := []string{}
+	for _, v := range contents.Assets {
+		names = append(names, *v.Name)
+	}
+	for _, filters := range contents.AssetFilters {
+		for _, v := range filters {
+			names = append(names, *v.Name)
+		}
+	}
+	for _, v := range contents.StreamingLocators {
+		names = append(names, *v.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func TestChangedSince(t *testing.T) {
+	tests := []struct {
+		name  string
+		since map[string]time.Time
+		want  string
+	}{
+		{
+			name:  "no watermarks",
+			since: map[string]time.Time{},
+			want:  "a1,a2,a3,f4,fa1,fa2,fa3,l1,l5",
+		},
+		{
+			name:  "the resource at the watermark was migrated already",
+			since: map[string]time.Time{ASSETS: testDay(2), STREAMINGLOCATORS: testDay(5)},
+			want:  "a3,f4,fa1,fa2,fa3",
+		},
+		{
+			name:  "filters without a time follow their asset",
+			since: map[string]time.Time{ASSETS: testDay(2), ASSETFILTERS: testDay(3)},
+			want:  "a3,f4,fa3,l1,l5",
+		},
+		{
+			name:  "filters with a time use their own",
+			since: map[string]time.Time{ASSETFILTERS: testDay(4)},
+			want:  "a1,a2,a3,fa1,fa2,fa3,l1,l5",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testNames(testWatermarkContents().ChangedSince(tt.since)); got != tt.want {
+				t.Errorf("ChangedSince = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	source := MigrationSource{Kind: SourceAzure, Subscription: "s", ResourceGroup: "rg", AccountName: "ams"}
+
+	tests := []struct {
+		name    string
+		initial map[string]time.Time
+		results []Result
+		moved   []string
+		want    map[string]time.Time
+	}{
+		{
+			name:  "first run",
+			moved: []string{ASSETS, ASSETFILTERS, STREAMINGLOCATORS},
+			want:  map[string]time.Time{ASSETS: testDay(3), ASSETFILTERS: testDay(4), STREAMINGLOCATORS: testDay(5)},
+		},
+		{
+			name:    "never moves back",
+			initial: map[string]time.Time{ASSETS: testDay(10), STREAMINGLOCATORS: testDay(5)},
+			moved:   []string{ASSETFILTERS},
+			want:    map[string]time.Time{ASSETS: testDay(10), ASSETFILTERS: testDay(4), STREAMINGLOCATORS: testDay(5)},
+		},
+		{
+			name:    "failed export",
+			results: []Result{{Resource: ASSETS, Operation: EXPORT, Err: fmt.Errorf("failed")}},
+			moved:   []string{ASSETFILTERS, STREAMINGLOCATORS},
+			want:    map[string]time.Time{ASSETFILTERS: testDay(4), STREAMINGLOCATORS: testDay(5)},
+		},
+		{
+			name:    "failed import",
+			results: []Result{{Resource: ASSETFILTERS, Operation: IMPORT, Failures: []ImportFailure{{Kind: ASSETFILTERS, Name: "f4"}}}},
+			moved:   []string{ASSETS, STREAMINGLOCATORS},
+			want:    map[string]time.Time{ASSETS: testDay(3), STREAMINGLOCATORS: testDay(5)},
+		},
+		{
+			name:    "failed content keys hold the locators back",
+			initial: map[string]time.Time{STREAMINGLOCATORS: testDay(1)},
+			results: []Result{{Resource: CONTENTKEYS, Operation: EXPORT, Err: fmt.Errorf("failed")}},
+			moved:   []string{ASSETS, ASSETFILTERS},
+			want:    map[string]time.Time{ASSETS: testDay(3), ASSETFILTERS: testDay(4), STREAMINGLOCATORS: testDay(1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := Watermarks{}
+			if tt.initial != nil {
+				w[source.String()] = tt.initial
+			}
+			moved := w.Advance(source, testWatermarkContents(), tt.results)
+			if strings.Join(moved, ",") != strings.Join(tt.moved, ",") {
+				t.Errorf("moved %v, want %v", moved, tt.moved)
+			}
+			since := w.Since(source)
+			if len(since) != len(tt.want) {
+				t.Errorf("watermarks %v, want %v", since, tt.want)
+			}
+			for kind, mark := range tt.want {
+				if !since[kind].Equal(mark) {
+					t.Errorf("%v watermark %v, want %v", kind, since[kind], mark)
+				}
+			}
+		})
+	}
+}
+
+// watermarkSource is a SourceProvider exporting the Assets and Asset Filters of testWatermarkContents
+type watermarkSource struct {
+	SourceProvider
+	// filtersOf lists the assets the filters were read for
+	filtersOf []string
+}
+
+func (s *watermarkSource) ExportAssets(ctx context.Context, before string, after string) ([]*armmediaservices.Asset, error) {
+	return testWatermarkContents().Assets, nil
+}
+
+func (s *watermarkSource) ExportAssetFilters(ctx context.Context, assets []*armmediaservices.Asset, workers int) (map[string][]*armmediaservices.AssetFilter, error) {
+	all := testWatermarkContents().AssetFilters
+	filters := map[string][]*armmediaservices.AssetFilter{}
+	for _, v := range assets {
+		s.filtersOf = append(s.filtersOf, *v.Name)
+		filters[*v.Name] = all[*v.Name]
+	}
+	return filters, nil
+}
+
+// A filter changed on an unchanged asset is exported
+func TestExportChangedSince(t *testing.T) {
+	source := &watermarkSource{}
+	p := &Pipeline{
+		Source:       source,
+		Kinds:        ResourceKinds{Assets: true, AssetFilters: true},
+		Workers:      1,
+		ChangedSince: map[string]time.Time{ASSETS: testDay(2), ASSETFILTERS: testDay(3)},
+	}
+	contents, _, err := p.Export(context.Background())
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if got := strings.Join(source.filtersOf, ","); got != "a1,a2,a3" {
+		t.Errorf("read the filters of %v, want every asset", got)
+	}
+	if got := testNames(contents); got != "a3,f4,fa3" {
+		t.Errorf("exported %v, want a3,f4,fa3", got)
+	}
+}
+
+func TestOverwriteChanged(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Pipeline
+		want map[string]bool
+	}{
+		{name: "no overwrite", p: Pipeline{ChangedSince: map[string]time.Time{ASSETS: testDay(1)}}, want: map[string]bool{}},
+		{name: "overwrite", p: Pipeline{Overwrite: true}, want: map[string]bool{ASSETS: true, STREAMINGLOCATORS: true}},
+		{
+			name: "only the types migrated before",
+			p:    Pipeline{OverwriteChanged: true, ChangedSince: map[string]time.Time{ASSETS: testDay(1)}},
+			want: map[string]bool{ASSETS: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, kind := range []string{ASSETS, STREAMINGLOCATORS} {
+				if got := tt.p.overwrite(kind); got != tt.want[kind] {
+					t.Errorf("overwrite(%v) = %v, want %v", kind, got, tt.want[kind])
+				}
+			}
+		})
+	}
+}
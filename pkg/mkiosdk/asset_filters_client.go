@@ -1,15 +1,9 @@
 package mkiosdk
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
-	"io"
-	"net/http"
-	"net/url"
-	"strings"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
 )
 
@@ -26,18 +20,9 @@ type AssetFiltersClient struct {
 // options - pass nil to accept the default values.
 func NewAssetFiltersClient(ctx context.Context, subscriptionName string, token string, apiEndpoint string, options *ClientOptions) (*AssetFiltersClient, error) {
 	if options == nil {
-		options = &ClientOptions{
-			host: apiEndpoint,
-		}
-	}
-	hc := &http.Client{}
-	client := &AssetFiltersClient{MkioClient{
-		subscriptionName: subscriptionName,
-		host:             options.host,
-		token:            token,
-		hc:               hc,
-	},
+		options = &ClientOptions{}
 	}
+	client := &AssetFiltersClient{newMkioClient(subscriptionName, token, apiEndpoint, options)}
 
 	// Test that our token is valid
 	err := client.GetProfile(ctx)
@@ -48,171 +33,46 @@ func NewAssetFiltersClient(ctx context.Context, subscriptionName string, token s
 	return client, nil
 }
 
-// CreateOrUpdate - Creates or updates an Asset in the Media Services account
+// filters returns the client of the Asset Filters of an Asset
+func (client *AssetFiltersClient) filters(assetName string) *ResourceClient[armmediaservices.AssetFilter] {
+	return NewResourceClient[armmediaservices.AssetFilter](&client.MkioClient, "assets", assetName, "assetFilters")
+}
+
+// CreateOrUpdate - Creates or updates an Asset Filter in the Media Services account
 // If the operation fails it returns an error type.
-// assetFilterName - The Asset name.
+// assetName - The Asset name.
+// assetFilterName - The Asset Filter name.
 // parameters - The request parameters
 // options - AssetFiltersClientCreateOrUpdateOptions contains the optional parameters for the AssetFiltersClient.CreateOrUpdate method.
 func (client *AssetFiltersClient) CreateOrUpdate(ctx context.Context, assetName string, assetFilterName string, parameters *armmediaservices.AssetFilter, options *armmediaservices.AssetFiltersClientCreateOrUpdateOptions) (armmediaservices.AssetFiltersClientCreateOrUpdateResponse, error) {
-	req, err := client.createOrUpdateCreateRequest(ctx, assetName, assetFilterName, parameters, options)
-	if err != nil {
-		return armmediaservices.AssetFiltersClientCreateOrUpdateResponse{}, err
-	}
-
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
-	if err != nil {
-		// We hit some error we and failed retry loop. Return error
-		return armmediaservices.AssetFiltersClientCreateOrUpdateResponse{}, err
-	}
-
-	return client.createOrUpdateHandleResponse(resp)
-}
-
-// createOrUpdateCreateRequest creates the CreateOrUpdate request.
-func (client *AssetFiltersClient) createOrUpdateCreateRequest(ctx context.Context, assetName string, assetFilterName string, parameters *armmediaservices.AssetFilter, options *armmediaservices.AssetFiltersClientCreateOrUpdateOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/assets/{assetName}/filters/{assetFilterName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{assetName}", url.PathEscape(assetName))
-	urlPath = strings.ReplaceAll(urlPath, "{assetFilterName}", url.PathEscape(assetFilterName))
-	body, err := json.Marshal(parameters)
-	if err != nil {
-		return nil, err
-	}
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-
-	b := bytes.NewReader(body)
-	var rcBody io.ReadCloser
-	if body != nil {
-		rcBody = io.NopCloser(io.ReadSeeker(b))
-	}
-	req, err := http.NewRequest(http.MethodPut, path, rcBody)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{b, req}, nil
-}
-
-// createOrUpdateHandleResponse handles the CreateOrUpdate response.
-func (client *AssetFiltersClient) createOrUpdateHandleResponse(resp *http.Response) (armmediaservices.AssetFiltersClientCreateOrUpdateResponse, error) {
-	result := armmediaservices.AssetFiltersClientCreateOrUpdateResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	assetFilter, err := client.filters(assetName).CreateOrUpdate(ctx, assetFilterName, parameters)
 	if err != nil {
 		return armmediaservices.AssetFiltersClientCreateOrUpdateResponse{}, err
 	}
-	if err := json.Unmarshal(body, &result.AssetFilter); err != nil {
-		return armmediaservices.AssetFiltersClientCreateOrUpdateResponse{}, err
-	}
-	return result, nil
+	return armmediaservices.AssetFiltersClientCreateOrUpdateResponse{AssetFilter: assetFilter}, nil
 }
 
-// Delete - Deletes an Asset in the Media Services account
+// Delete - Deletes an Asset Filter in the Media Services account
 // If the operation fails it returns an ResponseError type.
-// assetFilterName - The Asset name.
+// assetName - The Asset name.
+// assetFilterName - The Asset Filter name.
 // options - AssetFiltersClientDeleteOptions contains the optional parameters for the AssetFiltersClient.Delete method.
-func (client *AssetFiltersClient) Delete(ctx context.Context, assetFilterName string, options *armmediaservices.AssetFiltersClientDeleteOptions) (armmediaservices.AssetFiltersClientDeleteResponse, error) {
-	req, err := client.deleteCreateRequest(ctx, assetFilterName, options)
-	if err != nil {
-		return armmediaservices.AssetFiltersClientDeleteResponse{}, err
-	}
-
-	// Try to do request, handle retries if tooManyRequests
-	_, err = client.DoRequestWithBackoff(req)
-	if err != nil {
-		// We hit some error we and failed retry loop. Return error
-		return armmediaservices.AssetFiltersClientDeleteResponse{}, err
-	}
-
-	return armmediaservices.AssetFiltersClientDeleteResponse{}, nil
+func (client *AssetFiltersClient) Delete(ctx context.Context, assetName string, assetFilterName string, options *armmediaservices.AssetFiltersClientDeleteOptions) (armmediaservices.AssetFiltersClientDeleteResponse, error) {
+	err := client.filters(assetName).Delete(ctx, assetFilterName)
+	return armmediaservices.AssetFiltersClientDeleteResponse{}, err
 }
 
-// deleteCreateRequest creates the Delete request.
-func (client *AssetFiltersClient) deleteCreateRequest(ctx context.Context, assetFilterName string, options *armmediaservices.AssetFiltersClientDeleteOptions) (*Request, error) {
-
-	urlPath := "/api/ams/{subscriptionName}/assetFilters/{assetFilterName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{assetFilterName}", url.PathEscape(assetFilterName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodDelete, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-
-	return &Request{nil, req}, nil
-}
-
-// Get - Get the details of a Asset in the Media Services account
+// Get - Get the details of an Asset Filter in the Media Services account
 // If the operation fails it returns an *ResponseError type.
-// assetFilterName - The Asset name.
-// options - AssetClientGetOptions contains the optional parameters for the AssetClient.Get method.
+// assetName - The Asset name.
+// assetFilterName - The Asset Filter name.
+// options - AssetFiltersClientGetOptions contains the optional parameters for the AssetFiltersClient.Get method.
 func (client *AssetFiltersClient) Get(ctx context.Context, assetName string, assetFilterName string, options *armmediaservices.AssetFiltersClientGetOptions) (armmediaservices.AssetFiltersClientGetResponse, error) {
-	req, err := client.getCreateRequest(ctx, assetName, assetFilterName, options)
+	assetFilter, err := client.filters(assetName).Get(ctx, assetFilterName)
 	if err != nil {
 		return armmediaservices.AssetFiltersClientGetResponse{}, err
 	}
-
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
-	if err != nil {
-		// We hit some error we and failed retry loop. Return error
-		return armmediaservices.AssetFiltersClientGetResponse{}, err
-	}
-
-	return client.getHandleResponse(resp)
-}
-
-// getCreateRequest creates the Get request.
-func (client *AssetFiltersClient) getCreateRequest(ctx context.Context, assetName string, assetFilterName string, options *armmediaservices.AssetFiltersClientGetOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/assets/{assetName}/assetFilters/{assetFilterName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{assetFilterName}", url.PathEscape(assetFilterName))
-	urlPath = strings.ReplaceAll(urlPath, "{assetName}", url.PathEscape(assetName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest(http.MethodGet, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{nil, req}, nil
-}
-
-// getHandleResponse handles the Get response.
-func (client *AssetFiltersClient) getHandleResponse(resp *http.Response) (armmediaservices.AssetFiltersClientGetResponse, error) {
-	result := armmediaservices.AssetFiltersClientGetResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return armmediaservices.AssetFiltersClientGetResponse{}, err
-	}
-	if err := json.Unmarshal(body, &result.AssetFilter); err != nil {
-		return armmediaservices.AssetFiltersClientGetResponse{}, err
-	}
-	return result, nil
+	return armmediaservices.AssetFiltersClientGetResponse{AssetFilter: assetFilter}, nil
 }
 
 // lookupAssetFilters  Get asset filters from mk.io.
@@ -229,80 +89,28 @@ func (client *AssetFiltersClient) LookupAssetFilters(ctx context.Context, assetN
 	return assetFilters, nil
 }
 
+// List - List the Asset Filters of an Asset
+// If the operation fails it returns an *ResponseError type.
+// assetName - The Asset name.
+// options - AssetFiltersClientListOptions contains the optional parameters for the AssetFiltersClient.List method.
 func (client *AssetFiltersClient) List(ctx context.Context, assetName string, options *armmediaservices.AssetFiltersClientListOptions) (armmediaservices.AssetFiltersClientListResponse, error) {
-	skipToken := ""
 	result := armmediaservices.AssetFiltersClientListResponse{}
-
-	for {
-		req, err := client.listCreateRequest(ctx, assetName, options, skipToken)
+	pager := client.NewListPager(assetName, options)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
 		if err != nil {
 			return result, err
 		}
-
-		// Try to do request, handle retries if tooManyRequests
-		resp, err := client.DoRequestWithBackoff(req)
-		if err != nil {
-			// We hit some error we and failed retry loop. Return error
-			return result, err
-		}
-
-		listResp, err := client.listHandleResponse(resp)
-		if err != nil {
-			return result, err
-		}
-		result.AssetFilterCollection.Value = append(result.AssetFilterCollection.Value, listResp.AssetFilterCollection.Value...)
-
-		if listResp.AssetFilterCollection.ODataNextLink == nil {
-			// No more pages. Break the loop
-			break
-		} else {
-			// Mor Pages, Update SkipToken
-			skipToken = strings.Split(*listResp.AssetFilterCollection.ODataNextLink, "skiptoken=")[1]
-		}
+		result.AssetFilterCollection.Value = append(result.AssetFilterCollection.Value, page.Value...)
 	}
 	return result, nil
 }
 
-// listCreateRequest creates the list request.
-func (client *AssetFiltersClient) listCreateRequest(ctx context.Context, assetName string, options *armmediaservices.AssetFiltersClientListOptions, skipToken string) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/assets/{assetName}/assetFilters"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{assetName}", url.PathEscape(assetName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-	// Apply filters to query
-	filter := ""
-	if skipToken != "" {
-		filter = `$skiptoken=` + skipToken
-	}
-	q, err := url.ParseQuery(filter)
-	if err == nil {
-		path = path + "?" + q.Encode()
-	}
-	req, err := http.NewRequest(http.MethodGet, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{nil, req}, nil
-}
-
-// listHandleResponse handles the list response.
-func (client *AssetFiltersClient) listHandleResponse(resp *http.Response) (armmediaservices.AssetFiltersClientListResponse, error) {
-	result := armmediaservices.AssetFiltersClientListResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return armmediaservices.AssetFiltersClientListResponse{}, err
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return armmediaservices.AssetFiltersClientListResponse{}, err
-	}
-	return result, nil
+// NewListPager - Creates a pager to list the Asset Filters of an Asset a page at a time
+// assetName - The Asset name.
+// options - AssetFiltersClientListOptions contains the optional parameters for the AssetFiltersClient.NewListPager method.
+func (client *AssetFiltersClient) NewListPager(assetName string, options *armmediaservices.AssetFiltersClientListOptions) *runtime.Pager[armmediaservices.AssetFiltersClientListResponse] {
+	return newListPager(client.filters(assetName), nil, func(value []*armmediaservices.AssetFilter, nextLink *string) armmediaservices.AssetFiltersClientListResponse {
+		return armmediaservices.AssetFiltersClientListResponse{AssetFilterCollection: armmediaservices.AssetFilterCollection{Value: value, ODataNextLink: nextLink}}
+	})
 }
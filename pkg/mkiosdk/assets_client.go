@@ -1,15 +1,9 @@
 package mkiosdk
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
-	"io"
-	"net/http"
-	"net/url"
-	"strings"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
 )
@@ -18,6 +12,7 @@ import (
 // Don't use this type directly, use NewAssetsClient() instead.
 type AssetsClient struct {
 	MkioClient
+	resources *ResourceClient[armmediaservices.Asset]
 }
 
 // NewAssetsClient creates a new instance of AssetsClient with the specified values.
@@ -27,18 +22,10 @@ type AssetsClient struct {
 // options - pass nil to accept the default values.
 func NewAssetsClient(ctx context.Context, subscriptionName string, token string, apiEndpoint string, options *ClientOptions) (*AssetsClient, error) {
 	if options == nil {
-		options = &ClientOptions{
-			host: apiEndpoint,
-		}
-	}
-	hc := &http.Client{}
-	client := &AssetsClient{MkioClient{
-		subscriptionName: subscriptionName,
-		host:             options.host,
-		token:            token,
-		hc:               hc,
-	},
+		options = &ClientOptions{}
 	}
+	client := &AssetsClient{MkioClient: newMkioClient(subscriptionName, token, apiEndpoint, options)}
+	client.resources = NewResourceClient[armmediaservices.Asset](&client.MkioClient, "assets")
 
 	// Test that our token is valid
 	err := client.GetProfile(ctx)
@@ -55,64 +42,11 @@ func NewAssetsClient(ctx context.Context, subscriptionName string, token string,
 // parameters - The request parameters
 // options - AssetsClientCreateOrUpdateOptions contains the optional parameters for the AssetsClient.CreateOrUpdate method.
 func (client *AssetsClient) CreateOrUpdate(ctx context.Context, assetName string, parameters *armmediaservices.Asset, options *armmediaservices.AssetsClientCreateOrUpdateOptions) (armmediaservices.AssetsClientCreateOrUpdateResponse, error) {
-	req, err := client.createOrUpdateCreateRequest(ctx, assetName, parameters, options)
-	if err != nil {
-		return armmediaservices.AssetsClientCreateOrUpdateResponse{}, err
-	}
-
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
+	asset, err := client.resources.CreateOrUpdate(ctx, assetName, parameters)
 	if err != nil {
 		return armmediaservices.AssetsClientCreateOrUpdateResponse{}, err
 	}
-
-	return client.createOrUpdateHandleResponse(resp)
-}
-
-// createOrUpdateCreateRequest creates the CreateOrUpdate request.
-func (client *AssetsClient) createOrUpdateCreateRequest(ctx context.Context, assetName string, parameters *armmediaservices.Asset, options *armmediaservices.AssetsClientCreateOrUpdateOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/assets/{assetName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{assetName}", url.PathEscape(assetName))
-	body, err := json.Marshal(parameters)
-	if err != nil {
-		return nil, err
-	}
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-
-	b := bytes.NewReader(body)
-	var rcBody io.ReadCloser
-	if body != nil {
-		rcBody = io.NopCloser(io.ReadSeeker(b))
-	}
-
-	req, err := http.NewRequest(http.MethodPut, path, rcBody)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{b, req}, nil
-}
-
-// createOrUpdateHandleResponse handles the CreateOrUpdate response.
-func (client *AssetsClient) createOrUpdateHandleResponse(resp *http.Response) (armmediaservices.AssetsClientCreateOrUpdateResponse, error) {
-	result := armmediaservices.AssetsClientCreateOrUpdateResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return armmediaservices.AssetsClientCreateOrUpdateResponse{}, err
-	}
-	if err := json.Unmarshal(body, &result.Asset); err != nil {
-		return armmediaservices.AssetsClientCreateOrUpdateResponse{}, err
-	}
-	return result, nil
+	return armmediaservices.AssetsClientCreateOrUpdateResponse{Asset: asset}, nil
 }
 
 // Delete - Deletes an Asset in the Media Services account
@@ -120,41 +54,8 @@ func (client *AssetsClient) createOrUpdateHandleResponse(resp *http.Response) (a
 // assetName - The Asset name.
 // options - AssetsClientDeleteOptions contains the optional parameters for the AssetsClient.Delete method.
 func (client *AssetsClient) Delete(ctx context.Context, assetName string, options *armmediaservices.AssetsClientDeleteOptions) (armmediaservices.AssetsClientDeleteResponse, error) {
-	req, err := client.deleteCreateRequest(ctx, assetName, options)
-	if err != nil {
-		return armmediaservices.AssetsClientDeleteResponse{}, err
-	}
-
-	// Try to do request, handle retries if tooManyRequests
-	_, err = client.DoRequestWithBackoff(req)
-	if err != nil {
-		return armmediaservices.AssetsClientDeleteResponse{}, err
-	}
-
-	return armmediaservices.AssetsClientDeleteResponse{}, nil
-}
-
-// deleteCreateRequest creates the Delete request.
-func (client *AssetsClient) deleteCreateRequest(ctx context.Context, assetName string, options *armmediaservices.AssetsClientDeleteOptions) (*Request, error) {
-
-	urlPath := "/api/ams/{subscriptionName}/assets/{assetName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{assetName}", url.PathEscape(assetName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodDelete, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-
-	return &Request{nil, req}, nil
+	err := client.resources.Delete(ctx, assetName)
+	return armmediaservices.AssetsClientDeleteResponse{}, err
 }
 
 // Get - Get the details of a Asset in the Media Services account
@@ -162,143 +63,56 @@ func (client *AssetsClient) deleteCreateRequest(ctx context.Context, assetName s
 // assetName - The Asset name.
 // options - AssetClientGetOptions contains the optional parameters for the AssetClient.Get method.
 func (client *AssetsClient) Get(ctx context.Context, assetName string, options *armmediaservices.AssetsClientGetOptions) (armmediaservices.AssetsClientGetResponse, error) {
-	req, err := client.getCreateRequest(ctx, assetName, options)
-	if err != nil {
-		return armmediaservices.AssetsClientGetResponse{}, err
-	}
-
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
-	if err != nil {
-		return armmediaservices.AssetsClientGetResponse{}, err
-	}
-
-	return client.getHandleResponse(resp)
-}
-
-// getCreateRequest creates the Get request.
-func (client *AssetsClient) getCreateRequest(ctx context.Context, assetName string, options *armmediaservices.AssetsClientGetOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/assets/{assetName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{assetName}", url.PathEscape(assetName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodGet, path, nil)
+	asset, err := client.resources.Get(ctx, assetName)
 	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{nil, req}, nil
-}
-
-// getHandleResponse handles the Get response.
-func (client *AssetsClient) getHandleResponse(resp *http.Response) (armmediaservices.AssetsClientGetResponse, error) {
-	result := armmediaservices.AssetsClientGetResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return armmediaservices.AssetsClientGetResponse{}, err
-	}
-	if err := json.Unmarshal(body, &result.Asset); err != nil {
 		return armmediaservices.AssetsClientGetResponse{}, err
 	}
-	return result, nil
+	return armmediaservices.AssetsClientGetResponse{Asset: asset}, nil
 }
 
 // List - List Assets in the mk.io account
 // If the operation fails it returns an *ResponseError type.
-// options - AssetClientGetOptions contains the optional parameters for the AssetClient.Get method.
+// options - AssetsClientListOptions contains the optional parameters for the AssetsClient.List method.
 func (client *AssetsClient) List(ctx context.Context, options *armmediaservices.AssetsClientListOptions) (armmediaservices.AssetsClientListResponse, error) {
-	skipToken := ""
-	results := armmediaservices.AssetsClientListResponse{}
-
-	for {
-		req, err := client.listCreateRequest(ctx, options, skipToken)
-		if err != nil {
-			return results, err
-		}
-
-		// Try to do request, handle retries if tooManyRequests
-		resp, err := client.DoRequestWithBackoff(req)
-		if err != nil {
-			return results, err
-		}
-
-		listResp, err := client.listHandleResponse(resp)
+	result := armmediaservices.AssetsClientListResponse{}
+	pager := client.NewListPager(options)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
 		if err != nil {
-			return results, err
-		}
-		// Append the results to the response
-		results.AssetCollection.Value = append(results.AssetCollection.Value, listResp.AssetCollection.Value...)
-
-		if listResp.AssetCollection.ODataNextLink == nil {
-			// No more pages. Break the loop
-			break
-		} else {
-			// Mor Pages, Update SkipToken
-			skipToken = strings.Split(*listResp.AssetCollection.ODataNextLink, "skiptoken=")[1]
+			return result, err
 		}
+		result.AssetCollection.Value = append(result.AssetCollection.Value, page.Value...)
 	}
-
-	return results, nil
+	return result, nil
 }
 
-// listCreateRequest creates the list request.
-func (client *AssetsClient) listCreateRequest(ctx context.Context, options *armmediaservices.AssetsClientListOptions, skipToken string) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/assets"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
+// NewListPager - Creates a pager to list Assets in the mk.io account a page at a time
+// options - AssetsClientListOptions contains the optional parameters for the AssetsClient.NewListPager method.
+func (client *AssetsClient) NewListPager(options *armmediaservices.AssetsClientListOptions) *runtime.Pager[armmediaservices.AssetsClientListResponse] {
+	listOptions := &ListOptions{}
+	if options != nil {
+		listOptions = &ListOptions{Filter: options.Filter, Orderby: options.Orderby, Top: options.Top}
 	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-
-	// Apply filters to query
-	filter := ""
-	if skipToken != "" {
-		filter = `$skiptoken=` + skipToken + "&"
-	}
-	if options.Filter != nil {
-		filter = `$filter=` + *options.Filter
-	}
-	q, err := url.ParseQuery(filter)
-	if err == nil {
-		path = path + "?" + q.Encode()
-	}
-
-	req, err := http.NewRequest(http.MethodGet, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{nil, req}, nil
+	return newListPager(client.resources, listOptions, func(value []*armmediaservices.Asset, nextLink *string) armmediaservices.AssetsClientListResponse {
+		return armmediaservices.AssetsClientListResponse{AssetCollection: armmediaservices.AssetCollection{Value: value, ODataNextLink: nextLink}}
+	})
 }
 
-// listHandleResponse handles the List response.
-func (client *AssetsClient) listHandleResponse(resp *http.Response) (armmediaservices.AssetsClientListResponse, error) {
-	result := armmediaservices.AssetsClientListResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+// lookupAssets  Get assets from mk.io
+func (client *AssetsClient) LookupAssets(ctx context.Context, before string, after string) ([]*armmediaservices.Asset, error) {
+	assets := []*armmediaservices.Asset{}
+	err := client.ListAssets(ctx, before, after, func(page []*armmediaservices.Asset) error {
+		assets = append(assets, page...)
+		return nil
+	})
 	if err != nil {
-		return armmediaservices.AssetsClientListResponse{}, err
-	}
-	if err := json.Unmarshal(body, &result.AssetCollection); err != nil {
-		return armmediaservices.AssetsClientListResponse{}, err
+		return nil, err
 	}
-	return result, nil
+	return assets, nil
 }
 
-// lookupAssets  Get assets from mk.io
-func (client *AssetsClient) LookupAssets(ctx context.Context, before string, after string) ([]*armmediaservices.Asset, error) {
+// ListAssets  Get assets from mk.io, passing each page to the page func as it is read
+func (client *AssetsClient) ListAssets(ctx context.Context, before string, after string, page func([]*armmediaservices.Asset) error) error {
 	// Generate the filter
 	filter := generateFilter(before, after)
 
@@ -307,14 +121,17 @@ func (client *AssetsClient) LookupAssets(ctx context.Context, before string, aft
 	if filter != "" {
 		options.Filter = to.Ptr(filter)
 	}
-	req, err := client.List(ctx, options)
-	if err != nil {
-		return nil, err
-	}
-
-	assets := []*armmediaservices.Asset{}
-
-	assets = append(assets, req.AssetCollection.Value...)
+	pager := client.NewListPager(options)
 
-	return assets, nil
+	// Hand each page over before reading the next
+	for pager.More() {
+		nextResult, err := pager.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if err := page(nextResult.Value); err != nil {
+			return err
+		}
+	}
+	return nil
 }
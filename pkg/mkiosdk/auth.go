@@ -0,0 +1,287 @@
+package mkiosdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenProvider supplies the token the mk.io requests are authorized with. Share one between the clients of a
+// subscription, so a token refreshed by one is used by all of them.
+type TokenProvider interface {
+	// Token returns the token to send with the next request
+	Token(ctx context.Context) (string, error)
+	// Refresh is called when mk.io rejected a token with a 401. It returns a token other than rejected, or an
+	// error if there is none. If another request already replaced rejected, the new token is returned right away
+	Refresh(ctx context.Context, rejected string) (string, error)
+}
+
+// ErrTokenNotRefreshed is returned by Refresh when no new token could be found
+var ErrTokenNotRefreshed = errors.New("mk.io rejected the token and no new token is available")
+
+// tokenPollInterval is how often the token file and keyring are read again while waiting for a new token
+const tokenPollInterval = time.Second
+
+// StaticToken is a token that never changes, e.g. from the MKIO_TOKEN environment variable
+type StaticToken string
+
+// Token implements TokenProvider
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// Refresh implements TokenProvider. A static token can't be refreshed
+func (t StaticToken) Refresh(ctx context.Context, rejected string) (string, error) {
+	return "", ErrTokenNotRefreshed
+}
+
+// TokenFile reads the token from a file, and reads it again whenever the file changes, so the token can be rotated
+// during a run. Don't use this type directly, use NewTokenFile() instead.
+type TokenFile struct {
+	path string
+	wait time.Duration
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+// NewTokenFile creates a TokenProvider reading the token from path. When mk.io rejects the token, requests wait up
+// to wait for the file to be replaced with a new token. 0 fails them straight away
+func NewTokenFile(path string, wait time.Duration) *TokenFile {
+	return &TokenFile{path: path, wait: wait}
+}
+
+// Token implements TokenProvider
+func (t *TokenFile) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.read()
+}
+
+// Refresh implements TokenProvider. Waits for the file to hold a token other than rejected. The lock is only held
+// while the file is read, so Token doesn't wait with it
+func (t *TokenFile) Refresh(ctx context.Context, rejected string) (string, error) {
+	return waitForToken(ctx, rejected, t.wait, func() (string, error) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.read()
+	})
+}
+
+// read returns the token in the file, reading it again if the file changed since the last time
+func (t *TokenFile) read() (string, error) {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read mk.io token file: %v", err)
+	}
+	if t.token != "" && info.ModTime().Equal(t.modTime) && info.Size() == t.size {
+		return t.token, nil
+	}
+
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read mk.io token file: %v", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("mk.io token file %v is empty", t.path)
+	}
+	t.token, t.modTime, t.size = token, info.ModTime(), info.Size()
+	return t.token, nil
+}
+
+// KeyringToken reads the token from the OS keyring: the login keychain on macOS, or the Secret Service (e.g. GNOME
+// Keyring or KWallet) on Linux through secret-tool. Don't use this type directly, use NewKeyringToken() instead.
+type KeyringToken struct {
+	service string
+	account string
+	wait    time.Duration
+
+	mu    sync.Mutex
+	token string
+}
+
+// NewKeyringToken creates a TokenProvider reading the password of service and account from the OS keyring. When
+// mk.io rejects the token, requests wait up to wait for a new token to be stored. 0 fails them straight away
+func NewKeyringToken(service string, account string, wait time.Duration) *KeyringToken {
+	return &KeyringToken{service: service, account: account, wait: wait}
+}
+
+// Token implements TokenProvider. The keyring is only read the first time, and when the token is refreshed
+func (k *KeyringToken) Token(ctx context.Context) (string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.token != "" {
+		return k.token, nil
+	}
+	token, err := k.read(ctx)
+	if err != nil {
+		return "", err
+	}
+	k.token = token
+	return token, nil
+}
+
+// Refresh implements TokenProvider. Waits for the keyring to hold a token other than rejected. The lock isn't held
+// while waiting, so Token doesn't wait with it
+func (k *KeyringToken) Refresh(ctx context.Context, rejected string) (string, error) {
+	token, err := waitForToken(ctx, rejected, k.wait, func() (string, error) {
+		k.mu.Lock()
+		current := k.token
+		k.mu.Unlock()
+		if current != "" && current != rejected {
+			// Already refreshed by another request
+			return current, nil
+		}
+		return k.read(ctx)
+	})
+	if err != nil {
+		return "", err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.token = token
+	return token, nil
+}
+
+// read looks the token up in the keyring
+func (k *KeyringToken) read(ctx context.Context) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", k.service, "-a", k.account, "-w")
+	case "linux", "freebsd", "openbsd", "netbsd":
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", k.service, "account", k.account)
+	default:
+		return "", fmt.Errorf("reading the mk.io token from the keyring isn't supported on %v", runtime.GOOS)
+	}
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to read mk.io token %v/%v from the keyring: %v", k.service, k.account, commandError(err, stderr))
+	}
+	token := strings.TrimSpace(string(out))
+	if token == "" {
+		return "", fmt.Errorf("no mk.io token %v/%v in the keyring", k.service, k.account)
+	}
+	return token, nil
+}
+
+// RefreshFunc gets a new mk.io token, e.g. by running a command or calling an identity provider
+type RefreshFunc func(ctx context.Context) (string, error)
+
+// RefreshingToken gets its token from a RefreshFunc, the first time and whenever mk.io rejects it.
+// Don't use this type directly, use NewRefreshingToken() instead.
+type RefreshingToken struct {
+	refresh RefreshFunc
+
+	mu    sync.Mutex
+	token string
+}
+
+// NewRefreshingToken creates a TokenProvider starting with the token initial, which is refreshed with refresh. If
+// initial is empty the first token comes from refresh
+func NewRefreshingToken(initial string, refresh RefreshFunc) *RefreshingToken {
+	return &RefreshingToken{refresh: refresh, token: initial}
+}
+
+// Token implements TokenProvider
+func (r *RefreshingToken) Token(ctx context.Context) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.token != "" {
+		return r.token, nil
+	}
+	return r.get(ctx)
+}
+
+// Refresh implements TokenProvider
+func (r *RefreshingToken) Refresh(ctx context.Context, rejected string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.token != rejected {
+		// Already refreshed by another request
+		return r.token, nil
+	}
+	token, err := r.get(ctx)
+	if err != nil {
+		return "", err
+	}
+	if token == rejected {
+		return "", ErrTokenNotRefreshed
+	}
+	return token, nil
+}
+
+// get calls the RefreshFunc and keeps its token
+func (r *RefreshingToken) get(ctx context.Context) (string, error) {
+	token, err := r.refresh(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to refresh mk.io token: %v", err)
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("unable to refresh mk.io token: got an empty token")
+	}
+	r.token = token
+	return token, nil
+}
+
+// CommandRefresh returns a RefreshFunc running command with the shell, and using what it prints as the token
+func CommandRefresh(command string) RefreshFunc {
+	return func(ctx context.Context) (string, error) {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+		} else {
+			cmd = exec.CommandContext(ctx, "sh", "-c", command)
+		}
+		stderr := &bytes.Buffer{}
+		cmd.Stderr = stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%v: %v", command, commandError(err, stderr))
+		}
+		return string(out), nil
+	}
+}
+
+// commandError adds what a failed command printed to its error
+func commandError(err error, stderr *bytes.Buffer) string {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Sprintf("%v: %v", err, msg)
+	}
+	return err.Error()
+}
+
+// waitForToken calls read until it returns a token other than rejected, for up to wait or until ctx is done
+func waitForToken(ctx context.Context, rejected string, wait time.Duration, read func() (string, error)) (string, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		token, err := read()
+		if err == nil && token != rejected {
+			return token, nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return "", err
+			}
+			return "", ErrTokenNotRefreshed
+		}
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(tokenPollInterval):
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package mkiosdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStaticToken(t *testing.T) {
+	token := StaticToken("t1")
+	if got, err := token.Token(context.Background()); got != "t1" || err != nil {
+		t.Errorf("Token() = %q, %v, want t1", got, err)
+	}
+	if _, err := token.Refresh(context.Background(), "t1"); !errors.Is(err, ErrTokenNotRefreshed) {
+		t.Errorf("Refresh() = %v, want ErrTokenNotRefreshed", err)
+	}
+}
+
+func TestTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	write := func(token string) {
+		if err := os.WriteFile(path, []byte(token), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	tokens := NewTokenFile(path, 0)
+
+	if _, err := tokens.Token(ctx); err == nil {
+		t.Errorf("Token() of a missing file succeeded")
+	}
+	write("  t1\n")
+	if got, err := tokens.Token(ctx); got != "t1" || err != nil {
+		t.Errorf("Token() = %q, %v, want t1", got, err)
+	}
+	if _, err := tokens.Refresh(ctx, "t1"); !errors.Is(err, ErrTokenNotRefreshed) {
+		t.Errorf("Refresh() of an unchanged file = %v, want ErrTokenNotRefreshed", err)
+	}
+
+	write("token2")
+	if got, err := tokens.Refresh(ctx, "t1"); got != "token2" || err != nil {
+		t.Errorf("Refresh() = %q, %v, want token2", got, err)
+	}
+	if got, _ := tokens.Token(ctx); got != "token2" {
+		t.Errorf("Token() after a refresh = %q, want token2", got)
+	}
+
+	write("")
+	if _, err := tokens.Token(ctx); err == nil {
+		t.Errorf("Token() of an empty file succeeded")
+	}
+}
+
+func TestTokenFileRefreshWaits(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("t1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tokens := NewTokenFile(path, time.Minute)
+
+	// The token is replaced while Refresh waits. Token isn't blocked in the meantime
+	go func() {
+		if got, _ := tokens.Token(context.Background()); got != "t1" {
+			t.Errorf("Token() while refreshing = %q, want t1", got)
+		}
+		if err := os.WriteFile(path, []byte("token2"), 0600); err != nil {
+			t.Error(err)
+		}
+	}()
+	if got, err := tokens.Refresh(context.Background(), "t1"); got != "token2" || err != nil {
+		t.Errorf("Refresh() = %q, %v, want token2", got, err)
+	}
+
+	// Cancelling the request stops the wait
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := tokens.Refresh(ctx, "token2"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Refresh() = %v, want the context error", err)
+	}
+	if time.Since(start) > tokenPollInterval*2 {
+		t.Errorf("Refresh() took %v after its context was done", time.Since(start))
+	}
+}
+
+func TestKeyringTokenRefreshedByAnotherRequest(t *testing.T) {
+	k := NewKeyringToken("service", "account", 0)
+	k.token = "new"
+	if got, err := k.Refresh(context.Background(), "old"); got != "new" || err != nil {
+		t.Errorf("Refresh() = %q, %v, want the current token", got, err)
+	}
+}
+
+func TestRefreshingToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		initial  string
+		refresh  []string
+		rejected string
+		want     string
+		wantErr  bool
+	}{
+		{name: "first token comes from refresh", refresh: []string{"t1"}, want: "t1"},
+		{name: "refreshed", initial: "t1", refresh: []string{"t2"}, rejected: "t1", want: "t2"},
+		{name: "already refreshed by another request", initial: "t2", rejected: "t1", want: "t2"},
+		{name: "refresh returns the rejected token", initial: "t1", refresh: []string{"t1"}, rejected: "t1", wantErr: true},
+		{name: "refresh returns nothing", initial: "t1", refresh: []string{" "}, rejected: "t1", wantErr: true},
+		{name: "refresh fails", initial: "t1", rejected: "t1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			tokens := NewRefreshingToken(tt.initial, func(ctx context.Context) (string, error) {
+				if calls >= len(tt.refresh) {
+					return "", fmt.Errorf("no token")
+				}
+				calls++
+				return tt.refresh[calls-1], nil
+			})
+
+			var got string
+			var err error
+			if tt.rejected == "" {
+				got, err = tokens.Token(context.Background())
+			} else {
+				got, err = tokens.Refresh(context.Background(), tt.rejected)
+			}
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// A request rejected with a 401 is sent again with a refreshed token
+func TestRequestReauthenticates(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider TokenProvider
+		requests int
+		status   int
+	}{
+		{name: "refreshed", provider: NewRefreshingToken("old", func(context.Context) (string, error) { return "new", nil }), requests: 2},
+		{name: "can't be refreshed", provider: StaticToken("old"), requests: 1, status: http.StatusUnauthorized},
+		{name: "refreshed token rejected too", provider: NewRefreshingToken("old", func(context.Context) (string, error) { return "expired", nil }), requests: 2, status: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			client := newTestClient(t, func(w http.ResponseWriter, req *http.Request) {
+				requests++
+				if req.Header.Get("x-mkio-token") != "new" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`{}`))
+			}, &ClientOptions{TokenProvider: tt.provider})
+
+			_, err := NewResourceClient[testResource](client, "assets").Get(context.Background(), "a1")
+			if requests != tt.requests {
+				t.Errorf("sent %d requests, want %d", requests, tt.requests)
+			}
+			if got := StatusCode(err); got != tt.status {
+				t.Errorf("error %v has status %d, want %d", err, got, tt.status)
+			}
+		})
+	}
+}
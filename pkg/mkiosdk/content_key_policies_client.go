@@ -1,16 +1,10 @@
 package mkiosdk
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
-	"strings"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
 )
@@ -19,6 +13,7 @@ import (
 // Don't use this type directly, use NewContentKeyPoliciesClient() instead.
 type ContentKeyPoliciesClient struct {
 	MkioClient
+	resources *ResourceClient[armmediaservices.ContentKeyPolicy]
 }
 
 // NewContentKeyPoliciesClient creates a new instance of ContentKeyPoliciesClient with the specified values.
@@ -28,19 +23,11 @@ type ContentKeyPoliciesClient struct {
 // options - pass nil to accept the default values.
 func NewContentKeyPoliciesClient(ctx context.Context, subscriptionName string, token string, apiEndpoint string, options *ClientOptions) (*ContentKeyPoliciesClient, error) {
 	if options == nil {
-		options = &ClientOptions{
-			host: apiEndpoint,
-		}
-	}
-	hc := &http.Client{}
-	client := &ContentKeyPoliciesClient{
-		MkioClient{
-			subscriptionName: subscriptionName,
-			host:             options.host,
-			token:            token,
-			hc:               hc,
-		},
+		options = &ClientOptions{}
 	}
+	client := &ContentKeyPoliciesClient{MkioClient: newMkioClient(subscriptionName, token, apiEndpoint, options)}
+	client.resources = NewResourceClient[armmediaservices.ContentKeyPolicy](&client.MkioClient, "contentKeyPolicies")
+
 	// Test that our token is valid
 	err := client.GetProfile(ctx)
 	if err != nil {
@@ -56,63 +43,11 @@ func NewContentKeyPoliciesClient(ctx context.Context, subscriptionName string, t
 // parameters - The request parameters
 // options - ContentKeyPoliciesClientCreateOrUpdateOptions contains the optional parameters for the ContentKeyPoliciesClient.CreateOrUpdate method.
 func (client *ContentKeyPoliciesClient) CreateOrUpdate(ctx context.Context, contentKeyPolicyName string, parameters *FPContentKeyPolicy, options *armmediaservices.ContentKeyPoliciesClientCreateOrUpdateOptions) (armmediaservices.ContentKeyPoliciesClientCreateOrUpdateResponse, error) {
-	req, err := client.createOrUpdateCreateRequest(ctx, contentKeyPolicyName, parameters, options)
-	if err != nil {
-		return armmediaservices.ContentKeyPoliciesClientCreateOrUpdateResponse{}, err
-	}
-
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
-	if err != nil {
-		return armmediaservices.ContentKeyPoliciesClientCreateOrUpdateResponse{}, err
-	}
-
-	return client.createOrUpdateHandleResponse(resp)
-}
-
-// createOrUpdateCreateRequest creates the CreateOrUpdate request.
-func (client *ContentKeyPoliciesClient) createOrUpdateCreateRequest(ctx context.Context, contentKeyPolicyName string, parameters *FPContentKeyPolicy, options *armmediaservices.ContentKeyPoliciesClientCreateOrUpdateOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/contentKeyPolicies/{contentKeyPolicyName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{contentKeyPolicyName}", url.PathEscape(contentKeyPolicyName))
-	body, err := json.Marshal(parameters)
-	if err != nil {
-		return nil, err
-	}
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-
-	b := bytes.NewReader(body)
-	var rcBody io.ReadCloser
-	if body != nil {
-		rcBody = io.NopCloser(io.ReadSeeker(b))
-	}
-	req, err := http.NewRequest(http.MethodPut, path, rcBody)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{b, req}, nil
-}
-
-// createOrUpdateHandleResponse handles the CreateOrUpdate response.
-func (client *ContentKeyPoliciesClient) createOrUpdateHandleResponse(resp *http.Response) (armmediaservices.ContentKeyPoliciesClientCreateOrUpdateResponse, error) {
-	result := armmediaservices.ContentKeyPoliciesClientCreateOrUpdateResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	ckp, err := client.resources.CreateOrUpdate(ctx, contentKeyPolicyName, parameters)
 	if err != nil {
 		return armmediaservices.ContentKeyPoliciesClientCreateOrUpdateResponse{}, err
 	}
-	if err := json.Unmarshal(body, &result.ContentKeyPolicy); err != nil {
-		return armmediaservices.ContentKeyPoliciesClientCreateOrUpdateResponse{}, err
-	}
-	return result, nil
+	return armmediaservices.ContentKeyPoliciesClientCreateOrUpdateResponse{ContentKeyPolicy: ckp}, nil
 }
 
 // Delete - Deletes an ContentKeyPolicy in the Media Services account
@@ -120,40 +55,8 @@ func (client *ContentKeyPoliciesClient) createOrUpdateHandleResponse(resp *http.
 // contentKeyPolicyName - The ContentKeyPolicy name.
 // options - ContentKeyPoliciesClientDeleteOptions contains the optional parameters for the ContentKeyPoliciesClient.Delete method.
 func (client *ContentKeyPoliciesClient) Delete(ctx context.Context, contentKeyPolicyName string, options *armmediaservices.ContentKeyPoliciesClientDeleteOptions) (armmediaservices.ContentKeyPoliciesClientDeleteResponse, error) {
-	req, err := client.deleteCreateRequest(ctx, contentKeyPolicyName, options)
-	if err != nil {
-		return armmediaservices.ContentKeyPoliciesClientDeleteResponse{}, err
-	}
-	// Try to do request, handle retries if tooManyRequests
-	_, err = client.DoRequestWithBackoff(req)
-	if err != nil {
-		return armmediaservices.ContentKeyPoliciesClientDeleteResponse{}, err
-	}
-
-	return armmediaservices.ContentKeyPoliciesClientDeleteResponse{}, nil
-}
-
-// deleteCreateRequest creates the Delete request.
-func (client *ContentKeyPoliciesClient) deleteCreateRequest(ctx context.Context, contentKeyPolicyName string, options *armmediaservices.ContentKeyPoliciesClientDeleteOptions) (*Request, error) {
-
-	urlPath := "/api/ams/{subscriptionName}/contentKeyPolicies/{contentKeyPolicyName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{contentKeyPolicyName}", url.PathEscape(contentKeyPolicyName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodDelete, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-
-	return &Request{nil, req}, nil
+	err := client.resources.Delete(ctx, contentKeyPolicyName)
+	return armmediaservices.ContentKeyPoliciesClientDeleteResponse{}, err
 }
 
 // Get - Get the details of a ContentKeyPolicy in the Media Services account
@@ -161,191 +64,52 @@ func (client *ContentKeyPoliciesClient) deleteCreateRequest(ctx context.Context,
 // contentKeyPolicyName - The contentKeyPolicy name.
 // options - ContentKeyPoliciesClientGetOptions contains the optional parameters for the ContentKeyPolicisClient.Get method.
 func (client *ContentKeyPoliciesClient) Get(ctx context.Context, contentKeyPolicyName string, options *armmediaservices.ContentKeyPoliciesClientGetOptions) (armmediaservices.ContentKeyPoliciesClientGetResponse, error) {
-	req, err := client.getCreateRequest(ctx, contentKeyPolicyName, options)
-	if err != nil {
-		return armmediaservices.ContentKeyPoliciesClientGetResponse{}, err
-	}
-
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
+	ckp, err := client.resources.Get(ctx, contentKeyPolicyName)
 	if err != nil {
 		return armmediaservices.ContentKeyPoliciesClientGetResponse{}, err
 	}
-
-	return client.getHandleResponse(resp)
-}
-
-// getCreateRequest creates the Get request.
-func (client *ContentKeyPoliciesClient) getCreateRequest(ctx context.Context, contentKeyPolicyName string, options *armmediaservices.ContentKeyPoliciesClientGetOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/contentKeyPolicies/{contentKeyPolicyName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{contentKeyPolicyName}", url.PathEscape(contentKeyPolicyName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodGet, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{nil, req}, nil
+	return armmediaservices.ContentKeyPoliciesClientGetResponse{ContentKeyPolicy: ckp}, nil
 }
 
-// getHandleResponse handles the Get response.
-func (client *ContentKeyPoliciesClient) getHandleResponse(resp *http.Response) (armmediaservices.ContentKeyPoliciesClientGetResponse, error) {
-	result := armmediaservices.ContentKeyPoliciesClientGetResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return armmediaservices.ContentKeyPoliciesClientGetResponse{}, err
-	}
-	if err := json.Unmarshal(body, &result.ContentKeyPolicy); err != nil {
-		return armmediaservices.ContentKeyPoliciesClientGetResponse{}, err
-	}
-	return result, nil
-}
-
-// GetPolicyPropertiesWithSWecrets - Get the details of a ContentKeyPolicy in the mk.io account
+// GetPolicyPropertiesWithSecrets - Get the details of a ContentKeyPolicy in the mk.io account, including its secrets
 // If the operation fails it returns an *ResponseError type.
 // contentKeyPolicyName - The contentKeyPolicy name.
 // options - ContentKeyPoliciesClientGetOptions contains the optional parameters for the ContentKeyPolicisClient.Get method.
 func (client *ContentKeyPoliciesClient) GetPolicyPropertiesWithSecrets(ctx context.Context, contentKeyPolicyName string, options *armmediaservices.ContentKeyPoliciesClientGetOptions) (armmediaservices.ContentKeyPoliciesClientGetResponse, error) {
-	req, err := client.getWithSecretsCreateRequest(ctx, contentKeyPolicyName, options)
-	if err != nil {
-		return armmediaservices.ContentKeyPoliciesClientGetResponse{}, err
-	}
-
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
-	if err != nil {
-		return armmediaservices.ContentKeyPoliciesClientGetResponse{}, err
-	}
-
-	return client.getWithSecretsHandleResponse(resp)
-}
-
-// getCreateRequest creates the Get request.
-func (client *ContentKeyPoliciesClient) getWithSecretsCreateRequest(ctx context.Context, contentKeyPolicyName string, options *armmediaservices.ContentKeyPoliciesClientGetOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/contentKeyPolicies/{contentKeyPolicyName}/getPolicyPropertiesWithSecrets"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{contentKeyPolicyName}", url.PathEscape(contentKeyPolicyName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodPost, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{nil, req}, nil
-}
-
-// getHandleResponse handles the Get response.
-func (client *ContentKeyPoliciesClient) getWithSecretsHandleResponse(resp *http.Response) (armmediaservices.ContentKeyPoliciesClientGetResponse, error) {
 	result := armmediaservices.ContentKeyPoliciesClientGetResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	err := client.resources.Action(ctx, contentKeyPolicyName, "getPolicyPropertiesWithSecrets", &result.ContentKeyPolicy)
 	if err != nil {
 		return armmediaservices.ContentKeyPoliciesClientGetResponse{}, err
 	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return armmediaservices.ContentKeyPoliciesClientGetResponse{}, err
-	}
 	return result, nil
 }
 
 // List - List ContentKeyPolicy in the mk.io account
 // If the operation fails it returns an *ResponseError type.
-// options - ContentKeyPoliciesClientListOptions contains the optional parameters for the ContentKeyPolicisClient.Get method.
+// options - ContentKeyPoliciesClientListOptions contains the optional parameters for the ContentKeyPoliciesClient.List method.
 func (client *ContentKeyPoliciesClient) List(ctx context.Context, options *armmediaservices.ContentKeyPoliciesClientListOptions) (armmediaservices.ContentKeyPoliciesClientListResponse, error) {
-	skipToken := ""
-	results := armmediaservices.ContentKeyPoliciesClientListResponse{}
-
-	for {
-		req, err := client.listCreateRequest(ctx, options, skipToken)
-		if err != nil {
-			return results, err
-		}
-
-		// Try to do request, handle retries if tooManyRequests
-		resp, err := client.DoRequestWithBackoff(req)
-		if err != nil {
-			return results, err
-		}
-
-		listResp, err := client.listHandleResponse(resp)
+	result := armmediaservices.ContentKeyPoliciesClientListResponse{}
+	pager := client.NewListPager(options)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
 		if err != nil {
-			return results, err
-		}
-		results.ContentKeyPolicyCollection.Value = append(results.ContentKeyPolicyCollection.Value, listResp.ContentKeyPolicyCollection.Value...)
-
-		if listResp.ContentKeyPolicyCollection.ODataNextLink == nil {
-			// No more pages. Break the loop
-			break
-		} else {
-			// Mor Pages, Update SkipToken
-			skipToken = strings.Split(*listResp.ContentKeyPolicyCollection.ODataNextLink, "skiptoken=")[1]
+			return result, err
 		}
+		result.ContentKeyPolicyCollection.Value = append(result.ContentKeyPolicyCollection.Value, page.Value...)
 	}
-	return results, nil
-}
-
-// listCreateRequest creates the Get request.
-func (client *ContentKeyPoliciesClient) listCreateRequest(ctx context.Context, options *armmediaservices.ContentKeyPoliciesClientListOptions, skipToken string) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/contentKeyPolicies"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-
-	// Apply filters to query
-	filter := ""
-	if skipToken != "" {
-		filter = `$skiptoken=` + skipToken + "&"
-	}
-	if options.Filter != nil {
-		filter = `$filter=` + *options.Filter
-	}
-	q, err := url.ParseQuery(filter)
-	if err == nil {
-		path = path + "?" + q.Encode()
-	}
-
-	req, err := http.NewRequest(http.MethodGet, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{nil, req}, nil
+	return result, nil
 }
 
-// listHandleResponse handles the list response.
-func (client *ContentKeyPoliciesClient) listHandleResponse(resp *http.Response) (armmediaservices.ContentKeyPoliciesClientListResponse, error) {
-	result := armmediaservices.ContentKeyPoliciesClientListResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return armmediaservices.ContentKeyPoliciesClientListResponse{}, err
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return armmediaservices.ContentKeyPoliciesClientListResponse{}, err
+// NewListPager - Creates a pager to list ContentKeyPolicy in the mk.io account a page at a time
+// options - ContentKeyPoliciesClientListOptions contains the optional parameters for the ContentKeyPoliciesClient.NewListPager method.
+func (client *ContentKeyPoliciesClient) NewListPager(options *armmediaservices.ContentKeyPoliciesClientListOptions) *runtime.Pager[armmediaservices.ContentKeyPoliciesClientListResponse] {
+	listOptions := &ListOptions{}
+	if options != nil {
+		listOptions = &ListOptions{Filter: options.Filter, Orderby: options.Orderby, Top: options.Top}
 	}
-	return result, nil
+	return newListPager(client.resources, listOptions, func(value []*armmediaservices.ContentKeyPolicy, nextLink *string) armmediaservices.ContentKeyPoliciesClientListResponse {
+		return armmediaservices.ContentKeyPoliciesClientListResponse{ContentKeyPolicyCollection: armmediaservices.ContentKeyPolicyCollection{Value: value, ODataNextLink: nextLink}}
+	})
 }
 
 // lookupContentKeyPolicies  Get content key policies from mk.io
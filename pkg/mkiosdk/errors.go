@@ -136,6 +136,47 @@ func (e *ResponseError) Error() string {
 	return msg.String()
 }
 
+// StatusCode returns the HTTP status code of a *ResponseError in the error chain, 0 if there is none
+func StatusCode(err error) int {
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode
+	}
+	return 0
+}
+
+// ErrorCode returns the mk.io error code of a *ResponseError in the error chain, "" if there is none
+func ErrorCode(err error) string {
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.ErrorCode
+	}
+	return ""
+}
+
+// IsNotFound returns true if mk.io said the resource doesn't exist. Other errors, including failed requests,
+// say nothing about whether it exists
+func IsNotFound(err error) bool {
+	return StatusCode(err) == http.StatusNotFound
+}
+
+// IsConflict returns true if mk.io refused the request because of the current state of the resource, e.g. because
+// it already exists or is in use
+func IsConflict(err error) bool {
+	return StatusCode(err) == http.StatusConflict
+}
+
+// IsThrottled returns true if mk.io rate limited the request
+func IsThrottled(err error) bool {
+	return StatusCode(err) == http.StatusTooManyRequests
+}
+
+// IsUnauthorized returns true if mk.io rejected the token. A token that isn't allowed to make the request gets a
+// 403, which a new token doesn't fix
+func IsUnauthorized(err error) bool {
+	return StatusCode(err) == http.StatusUnauthorized
+}
+
 // HasStatusCode returns true if the Response's status code is one of the specified values.
 // Exported as runtime.HasStatusCode().
 func HasStatusCode(resp *http.Response, statusCodes ...int) bool {
@@ -0,0 +1,66 @@
+package mkiosdk
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// testResponseError returns the error of a response with status and body
+func testResponseError(status int, body string) error {
+	req, _ := http.NewRequest(http.MethodGet, "https://api.mk.io/api/ams/sub/assets/a1", nil)
+	return NewResponseError(&http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+		Request:    req,
+	})
+}
+
+func TestResponseErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		status       int
+		code         string
+		notFound     bool
+		conflict     bool
+		throttled    bool
+		unauthorized bool
+	}{
+		{name: "nil"},
+		{name: "not a response", err: fmt.Errorf("connection reset")},
+		{name: "not found", err: testResponseError(http.StatusNotFound, `{"error":{"code":"NotFound"}}`), status: http.StatusNotFound, code: "NotFound", notFound: true},
+		{name: "wrapped", err: fmt.Errorf("get asset: %w", testResponseError(http.StatusNotFound, "")), status: http.StatusNotFound, notFound: true},
+		{name: "odata error", err: testResponseError(http.StatusBadRequest, `{"odata.error":{"code":"BadFilter"}}`), status: http.StatusBadRequest, code: "BadFilter"},
+		{name: "xml error", err: testResponseError(http.StatusConflict, `<Error><Code>InUse</Code></Error>`), status: http.StatusConflict, code: "InUse", conflict: true},
+		{name: "unauthorized", err: testResponseError(http.StatusUnauthorized, ""), status: http.StatusUnauthorized, unauthorized: true},
+		{name: "forbidden", err: testResponseError(http.StatusForbidden, ""), status: http.StatusForbidden},
+		{name: "throttled", err: testResponseError(http.StatusTooManyRequests, "slow down"), status: http.StatusTooManyRequests, throttled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StatusCode(tt.err); got != tt.status {
+				t.Errorf("StatusCode() = %d, want %d", got, tt.status)
+			}
+			if got := ErrorCode(tt.err); got != tt.code {
+				t.Errorf("ErrorCode() = %q, want %q", got, tt.code)
+			}
+			if got := IsNotFound(tt.err); got != tt.notFound {
+				t.Errorf("IsNotFound() = %v, want %v", got, tt.notFound)
+			}
+			if got := IsConflict(tt.err); got != tt.conflict {
+				t.Errorf("IsConflict() = %v, want %v", got, tt.conflict)
+			}
+			if got := IsThrottled(tt.err); got != tt.throttled {
+				t.Errorf("IsThrottled() = %v, want %v", got, tt.throttled)
+			}
+			if got := IsUnauthorized(tt.err); got != tt.unauthorized {
+				t.Errorf("IsUnauthorized() = %v, want %v", got, tt.unauthorized)
+			}
+		})
+	}
+}
@@ -14,8 +14,36 @@ import (
 type MkioClient struct {
 	host             string
 	subscriptionName string
-	token            string
+	auth             TokenProvider
 	hc               *http.Client
+	retry            *RetryPolicy
+	counters         *retryCounters
+	limiter          *RateLimiter
+}
+
+// newMkioClient creates the MkioClient shared by the clients of each resource type
+func newMkioClient(subscriptionName string, token string, apiEndpoint string, options *ClientOptions) MkioClient {
+	retry := DefaultRetryPolicy()
+	if options.Retry != nil {
+		retry = options.Retry
+	}
+	hc := options.HTTPClient
+	if hc == nil {
+		hc = &http.Client{}
+	}
+	var auth TokenProvider = StaticToken(token)
+	if options.TokenProvider != nil {
+		auth = options.TokenProvider
+	}
+	return MkioClient{
+		subscriptionName: subscriptionName,
+		host:             options.hostOr(apiEndpoint),
+		auth:             auth,
+		hc:               hc,
+		retry:            retry,
+		counters:         &retryCounters{},
+		limiter:          options.RateLimiter,
+	}
 }
 
 type Request struct {
@@ -45,17 +73,25 @@ func generateFilter(before string, after string) string {
 
 type ClientOptions struct {
 	host string
+	// Retry is the retry policy of the requests of the client. Nil uses DefaultRetryPolicy
+	Retry *RetryPolicy
+	// RateLimiter limits the requests of the client. Share it between the clients of a subscription. Nil doesn't
+	// limit the requests
+	RateLimiter *RateLimiter
+	// HTTPClient sends the requests, so the clients of a subscription can share their connections. Nil creates one
+	// for the client
+	HTTPClient *http.Client
+	// TokenProvider supplies the token of the requests instead of the token passed to the client, so it can be
+	// refreshed when mk.io rejects it. Share it between the clients of a subscription
+	TokenProvider TokenProvider
 }
 
-// Do backoff to handle rate limiting. Rate limit should last about 1 minute. This is over 2 minutes, so we should be good
-var backoffSchedule = []time.Duration{
-	1 * time.Second,
-	5 * time.Second,
-	10 * time.Second,
-	15 * time.Second,
-	25 * time.Second,
-	30 * time.Second,
-	45 * time.Second,
+// hostOr returns the host of the options, or apiEndpoint if none was set
+func (o *ClientOptions) hostOr(apiEndpoint string) string {
+	if o.host != "" {
+		return o.host
+	}
+	return apiEndpoint
 }
 
 // GetProfile - Get the Media Services account
@@ -65,13 +101,11 @@ func (client *MkioClient) GetProfile(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	resp, err := client.hc.Do(req)
+	resp, err := client.DoRequestWithBackoff(&Request{Request: req})
 	if err != nil {
 		return err
 	}
-	if !HasStatusCode(resp, http.StatusOK, http.StatusCreated) {
-		return NewResponseError(resp)
-	}
+	resp.Body.Close()
 	return nil
 }
 
@@ -82,63 +116,127 @@ func (client *MkioClient) getProfileRequest(ctx context.Context) (*http.Request,
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest(http.MethodGet, path, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
 	return req, nil
 }
 
+// RetryStats returns the number of requests made by the client so far and how many of them were retried
+func (client *MkioClient) RetryStats() RetryStats {
+	if client.counters == nil {
+		return RetryStats{}
+	}
+	return RetryStats{
+		Requests:  client.counters.requests.Load(),
+		Retried:   client.counters.retried.Load(),
+		Retries:   client.counters.retries.Load(),
+		Exhausted: client.counters.exhausted.Load(),
+	}
+}
+
+// DoRequestWithBackoff sends the request with the token of the client, retrying it as the retry policy of the client
+// allows. If mk.io rejects the token, the request is sent once more with a refreshed one. Returns a *ResponseError if
+// the last response wasn't a success
 func (client *MkioClient) DoRequestWithBackoff(request *Request) (*http.Response, error) {
-	var resp *http.Response
-	// loop through backoff schedule. Hopefully we don't actually have to loop, but this will trigger if we get rate limited
-	for _, backoff := range backoffSchedule {
-		var err error
+	policy := client.retry
+	if policy == nil {
+		policy = DefaultRetryPolicy()
+	}
+	counters := client.counters
+	if counters == nil {
+		counters = &retryCounters{}
+	}
+
+	auth := client.auth
+	if auth == nil {
+		auth = StaticToken("")
+	}
+	token, err := auth.Token(request.Context())
+	if err != nil {
+		return nil, err
+	}
+	reauthenticated := false
+
+	for attempt := 1; ; attempt++ {
 		// Rewind the body to apply again
 		if request.body != nil {
 			request.body.Seek(0, 0)
 		}
+		request.Header.Set("x-mkio-token", token)
 
-		resp, err = client.hc.Do(request.Request)
-		if err != nil {
-			// Return an error from the Request
-			return resp, err
+		if err := client.limiter.Wait(request.Context(), request.Method); err != nil {
+			counters.record(attempt-1, false)
+			return nil, err
 		}
-
-		// Check the status code, expectations of response are consistent across functions
-		if request.Method == http.MethodPut {
-			// CreateOrUpdate
-			if HasStatusCode(resp, http.StatusOK, http.StatusCreated) {
-				return resp, nil
-			}
-		} else if request.Method == http.MethodPost {
-			// List Paths
-			if HasStatusCode(resp, http.StatusOK) {
-				return resp, nil
-			}
-		} else if request.Method == http.MethodDelete {
-			// Delete
-			if HasStatusCode(resp, http.StatusOK, http.StatusNoContent) {
-				return resp, nil
-			}
-		} else if request.Method == http.MethodGet {
-			// Get/List
-			if HasStatusCode(resp, http.StatusOK) {
-				return resp, nil
+		resp, err := client.hc.Do(request.Request)
+		if err == nil && succeeded(request.Method, resp) {
+			client.limiter.observe(request.Method, nil)
+			counters.record(attempt-1, false)
+			return resp, nil
+		}
+		// Responses that weren't a success become a *ResponseError, network errors are kept as they are
+		respErr := err
+		if err == nil {
+			respErr = NewResponseError(resp)
+		}
+		client.limiter.observe(request.Method, respErr)
+
+		// The token expired or was revoked. Send the request again with a new one, if there is one
+		if IsUnauthorized(respErr) && !reauthenticated {
+			reauthenticated = true
+			if newToken, refreshErr := auth.Refresh(request.Context(), token); refreshErr == nil {
+				drain(resp)
+				token = newToken
+				continue
 			}
 		}
 
-		// Unhandled status Codes. The only one we care about retrying for is TooManyRequests. Return an error from the status code
-		if !HasStatusCode(resp, http.StatusTooManyRequests) {
-			return resp, NewResponseError(resp)
+		// A request cancelled by its context isn't a network error worth retrying
+		retry := false
+		if err != nil {
+			retry = policy.RetryNetworkErrors && request.Context().Err() == nil
+		} else {
+			retry = policy.retryStatus(resp.StatusCode)
+		}
+		// Gave up on a retryable failure, because the attempts are used up
+		exhausted := retry
+		var wait time.Duration
+		if retry && attempt < policy.MaxAttempts {
+			wait = policy.delay(attempt, resp)
+		} else {
+			retry = false
+		}
+		if !retry {
+			counters.record(attempt-1, exhausted)
+			return resp, respErr
 		}
 
-		// We have a TooManyRequests status code. Sleep for the backoff duration and try again
-		time.Sleep(backoff)
+		if resp != nil {
+			drain(resp)
+		}
+		// Sleep for the backoff duration and try again, unless the request is cancelled in the meantime
+		select {
+		case <-request.Context().Done():
+			counters.record(attempt-1, false)
+			return nil, request.Context().Err()
+		case <-time.After(wait):
+		}
 	}
+}
 
-	// We have exhausted the backoff schedule. Return the last response & corresponding Error
-	return resp, NewResponseError(resp)
+// succeeded checks the status code of a response. The expectations are consistent across the clients
+func succeeded(method string, resp *http.Response) bool {
+	switch method {
+	case http.MethodPut:
+		// CreateOrUpdate
+		return HasStatusCode(resp, http.StatusOK, http.StatusCreated)
+	case http.MethodDelete:
+		return HasStatusCode(resp, http.StatusOK, http.StatusNoContent)
+	default:
+		// Get/List, and the List Paths POSTs
+		return HasStatusCode(resp, http.StatusOK)
+	}
 }
@@ -0,0 +1,132 @@
+package mkiosdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// newPagedServer returns a client whose assets collection lists pages, one page per skip token. The queries of the
+// requests are appended to queries
+func newPagedServer(t *testing.T, pages [][]string, queries *[]url.Values) *MkioClient {
+	return newTestClient(t, func(w http.ResponseWriter, req *http.Request) {
+		*queries = append(*queries, req.URL.Query())
+		page := 0
+		if token := req.URL.Query().Get("$skiptoken"); token != "" {
+			fmt.Sscanf(token, "page%d", &page)
+		}
+		names := []string{}
+		for _, v := range pages[page] {
+			names = append(names, fmt.Sprintf(`{"name":%q}`, v))
+		}
+		next := ""
+		if page+1 < len(pages) {
+			next = fmt.Sprintf(`,"@odata.nextLink":"http://%v%v?$skiptoken=page%d"`, req.Host, req.URL.Path, page+1)
+		}
+		fmt.Fprintf(w, `{"value":[%v]%v}`, strings.Join(names, ","), next)
+	}, nil)
+}
+
+func TestResourceClientList(t *testing.T) {
+	tests := []struct {
+		name    string
+		pages   [][]string
+		options *ListOptions
+		want    string
+	}{
+		{name: "single page", pages: [][]string{{"a1", "a2"}}, want: "a1,a2"},
+		{name: "empty", pages: [][]string{{}}, want: ""},
+		{name: "several pages", pages: [][]string{{"a1", "a2"}, {"a3"}, {"a4"}}, want: "a1,a2,a3,a4"},
+		{
+			name:    "options are sent with every page",
+			pages:   [][]string{{"a1"}, {"a2"}},
+			options: &ListOptions{Filter: to.Ptr("properties/created gt 2023-01-01"), Orderby: to.Ptr("properties/created"), Top: to.Ptr[int32](1)},
+			want:    "a1,a2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := []url.Values{}
+			client := newPagedServer(t, tt.pages, &queries)
+
+			list, err := NewResourceClient[testResource](client, "assets").List(context.Background(), tt.options)
+			if err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			names := []string{}
+			for _, v := range list {
+				names = append(names, v.Name)
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("List = %v, want %v", got, tt.want)
+			}
+			if len(queries) != len(tt.pages) {
+				t.Errorf("sent %d requests, want one per page", len(queries))
+			}
+			if tt.options != nil {
+				for i, q := range queries {
+					if q.Get("$filter") != *tt.options.Filter || q.Get("$orderby") != *tt.options.Orderby || q.Get("$top") != "1" {
+						t.Errorf("request %d has query %v, want the list options", i+1, q)
+					}
+				}
+			}
+		})
+	}
+}
+
+// Pages are only read when they are asked for, so exports can stop early
+func TestListPagerIsLazy(t *testing.T) {
+	queries := []url.Values{}
+	client := newPagedServer(t, [][]string{{"a1"}, {"a2"}, {"a3"}}, &queries)
+	assets := &AssetsClient{MkioClient: *client}
+	assets.resources = NewResourceClient[armmediaservices.Asset](&assets.MkioClient, "assets")
+
+	pager := assets.NewListPager(nil)
+	if len(queries) != 0 {
+		t.Fatalf("NewListPager sent %d requests, want none", len(queries))
+	}
+	page, err := pager.NextPage(context.Background())
+	if err != nil {
+		t.Fatalf("NextPage: %v", err)
+	}
+	if len(page.Value) != 1 || *page.Value[0].Name != "a1" || page.ODataNextLink == nil {
+		t.Errorf("first page %+v, want a1 and a next link", page.AssetCollection)
+	}
+	if len(queries) != 1 || !pager.More() {
+		t.Errorf("sent %d requests after the first page, want 1 and more pages", len(queries))
+	}
+
+	// Stopping early leaves the other pages alone
+	stop := fmt.Errorf("stop")
+	err = NewResourceClient[testResource](client, "assets").ListPages(context.Background(), nil, func([]*testResource) error { return stop })
+	if err != stop || len(queries) != 2 {
+		t.Errorf("ListPages = %v after %d requests, want stop after 2", err, len(queries))
+	}
+}
+
+func TestNextSkipToken(t *testing.T) {
+	tests := []struct {
+		nextLink string
+		want     string
+		wantErr  bool
+	}{
+		{nextLink: "https://api.mk.io/api/ams/sub/assets?$skiptoken=abc", want: "abc"},
+		{nextLink: "https://api.mk.io/api/ams/sub/assets?$top=10&skiptoken=a%20b", want: "a b"},
+		{nextLink: "https://api.mk.io/api/ams/sub/assets?$top=10", wantErr: true},
+		{nextLink: "://bad", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := nextSkipToken(tt.nextLink)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("nextSkipToken(%q) = %q, %v, want %q", tt.nextLink, got, err, tt.want)
+		}
+	}
+}
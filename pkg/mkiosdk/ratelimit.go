@@ -0,0 +1,184 @@
+package mkiosdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit is a token bucket: requests are sent at Rate per second on average, with bursts of up to Burst
+type RateLimit struct {
+	// Rate is in requests per second. 0 doesn't limit the requests
+	Rate  float64
+	Burst int
+}
+
+// RateLimits configure a RateLimiter
+type RateLimits struct {
+	Default RateLimit
+	// Methods overrides Default for some HTTP methods, e.g. to send fewer PUTs than GETs. Each method has a bucket of
+	// its own
+	Methods map[string]RateLimit
+	// Adaptive lowers the rate when mk.io throttles requests, and brings it back up gradually once it stops
+	Adaptive bool
+}
+
+// DefaultRateLimits limit the requests to an mk.io subscription to 20 per second, adapting to throttling
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		Default:  RateLimit{Rate: 20, Burst: 20},
+		Adaptive: true,
+	}
+}
+
+// Validate checks that the limits can be used
+func (l RateLimits) Validate() error {
+	check := func(name string, limit RateLimit) error {
+		if limit.Rate < 0 {
+			return fmt.Errorf("%v rate limit must not be negative, got %v", name, limit.Rate)
+		}
+		if limit.Rate > 0 && limit.Burst < 1 {
+			return fmt.Errorf("%v rate limit needs a burst of at least 1, got %d", name, limit.Burst)
+		}
+		return nil
+	}
+	if err := check("default", l.Default); err != nil {
+		return err
+	}
+	for method, limit := range l.Methods {
+		if err := check(method, limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Adaptive rate limiting lowers the rate by half when throttled, at most once per throttleInterval, as the workers
+// all get throttled at about the same time. It recovers by a tenth of the configured rate every recoverInterval
+// without throttling, and never goes below minRateFraction of the configured rate
+const (
+	throttleInterval = 1 * time.Second
+	recoverInterval  = 5 * time.Second
+	minRateFraction  = 0.05
+)
+
+// bucket is the token bucket of one HTTP method
+type bucket struct {
+	limiter *rate.Limiter
+	// configured is the rate the limiter was set up with. The limiter runs below it after throttling
+	configured rate.Limit
+	changed    time.Time
+}
+
+// RateLimiter limits the requests made to mk.io. A single RateLimiter is shared by the clients of a subscription,
+// so the limits hold whatever mix of resource types and workers is running. A nil *RateLimiter doesn't limit
+type RateLimiter struct {
+	mu       sync.Mutex
+	buckets  map[string]*bucket
+	fallback *bucket
+	adaptive bool
+}
+
+// NewRateLimiter creates a RateLimiter with the given limits
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	newBucket := func(limit RateLimit) *bucket {
+		r := rate.Limit(limit.Rate)
+		if limit.Rate == 0 {
+			r = rate.Inf
+		}
+		return &bucket{limiter: rate.NewLimiter(r, limit.Burst), configured: r}
+	}
+	l := &RateLimiter{
+		buckets:  map[string]*bucket{},
+		fallback: newBucket(limits.Default),
+		adaptive: limits.Adaptive,
+	}
+	for method, limit := range limits.Methods {
+		l.buckets[method] = newBucket(limit)
+	}
+	return l
+}
+
+// bucket returns the bucket of method
+func (l *RateLimiter) bucket(method string) *bucket {
+	if b, ok := l.buckets[method]; ok {
+		return b
+	}
+	return l.fallback
+}
+
+// Wait blocks until a request with method may be sent, or ctx is done
+func (l *RateLimiter) Wait(ctx context.Context, method string) error {
+	if l == nil {
+		return nil
+	}
+	return l.bucket(method).limiter.Wait(ctx)
+}
+
+// Throttled lowers the rate of method after mk.io returned 429, if the limiter is adaptive. Unlimited methods stay
+// unlimited
+func (l *RateLimiter) Throttled(method string) {
+	if l == nil || !l.adaptive {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(method)
+	now := time.Now()
+	if b.configured == rate.Inf || now.Sub(b.changed) < throttleInterval {
+		return
+	}
+	lowered := b.limiter.Limit() / 2
+	if lowered < b.configured*minRateFraction {
+		lowered = b.configured * minRateFraction
+	}
+	b.limiter.SetLimitAt(now, lowered)
+	b.changed = now
+}
+
+// Succeeded lets the rate of method recover after throttling, if the limiter is adaptive
+func (l *RateLimiter) Succeeded(method string) {
+	if l == nil || !l.adaptive {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(method)
+	now := time.Now()
+	current := b.limiter.Limit()
+	if current >= b.configured || now.Sub(b.changed) < recoverInterval {
+		return
+	}
+	raised := current + b.configured/10
+	if raised > b.configured {
+		raised = b.configured
+	}
+	b.limiter.SetLimitAt(now, raised)
+	b.changed = now
+}
+
+// Rate returns the current rate of method in requests per second, or 0 if it isn't limited
+func (l *RateLimiter) Rate(method string) float64 {
+	if l == nil {
+		return 0
+	}
+	limit := l.bucket(method).limiter.Limit()
+	if limit == rate.Inf {
+		return 0
+	}
+	return float64(limit)
+}
+
+// observe adjusts the rate to the outcome of a request, nil for a success. Other failures leave the rate alone
+func (l *RateLimiter) observe(method string, err error) {
+	if err == nil {
+		l.Succeeded(method)
+	} else if IsThrottled(err) {
+		l.Throttled(method)
+	}
+}
@@ -0,0 +1,137 @@
+package mkiosdk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limits  RateLimits
+		wantErr bool
+	}{
+		{name: "default", limits: DefaultRateLimits()},
+		{name: "unlimited", limits: RateLimits{}},
+		{name: "negative rate", limits: RateLimits{Default: RateLimit{Rate: -1, Burst: 1}}, wantErr: true},
+		{name: "no burst", limits: RateLimits{Default: RateLimit{Rate: 1}}, wantErr: true},
+		{name: "method without burst", limits: RateLimits{Default: RateLimit{Rate: 1, Burst: 1}, Methods: map[string]RateLimit{http.MethodPut: {Rate: 1}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.limits.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRateLimiterMethods(t *testing.T) {
+	l := NewRateLimiter(RateLimits{
+		Default: RateLimit{Rate: 20, Burst: 20},
+		Methods: map[string]RateLimit{http.MethodPut: {Rate: 5, Burst: 1}, http.MethodDelete: {}},
+	})
+	for method, want := range map[string]float64{http.MethodGet: 20, http.MethodPost: 20, http.MethodPut: 5, http.MethodDelete: 0} {
+		if got := l.Rate(method); got != want {
+			t.Errorf("Rate(%v) = %v, want %v", method, got, want)
+		}
+	}
+
+	// The PUT bucket holds a single request, then makes the next one wait
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, http.MethodPut); err != nil {
+		t.Fatalf("first PUT waited: %v", err)
+	}
+	if err := l.Wait(ctx, http.MethodPut); err == nil {
+		t.Errorf("second PUT didn't wait for its bucket")
+	}
+	// Other methods have buckets of their own
+	if err := l.Wait(context.Background(), http.MethodGet); err != nil {
+		t.Errorf("GET waited for the PUT bucket: %v", err)
+	}
+
+	var none *RateLimiter
+	if err := none.Wait(context.Background(), http.MethodGet); err != nil || none.Rate(http.MethodGet) != 0 {
+		t.Errorf("nil limiter limits requests")
+	}
+}
+
+func TestRateLimiterAdaptive(t *testing.T) {
+	l := NewRateLimiter(RateLimits{Default: RateLimit{Rate: 20, Burst: 20}, Methods: map[string]RateLimit{http.MethodDelete: {}}, Adaptive: true})
+	// age pretends the last change of the rate was d ago
+	age := func(d time.Duration) {
+		l.fallback.changed = time.Now().Add(-d)
+	}
+
+	l.Throttled(http.MethodGet)
+	if got := l.Rate(http.MethodGet); got != 10 {
+		t.Errorf("rate after throttling %v, want 10", got)
+	}
+	// The workers throttled at the same time only lower it once
+	l.Throttled(http.MethodGet)
+	if got := l.Rate(http.MethodGet); got != 10 {
+		t.Errorf("rate after throttling twice at once %v, want 10", got)
+	}
+	// Successes right after throttling don't raise it yet
+	l.Succeeded(http.MethodGet)
+	if got := l.Rate(http.MethodGet); got != 10 {
+		t.Errorf("rate after an early success %v, want 10", got)
+	}
+
+	for i := 0; i < 10; i++ {
+		age(throttleInterval)
+		l.Throttled(http.MethodGet)
+	}
+	if got := l.Rate(http.MethodGet); got != 20*minRateFraction {
+		t.Errorf("rate after throttling many times %v, want the minimum %v", got, 20*minRateFraction)
+	}
+
+	age(recoverInterval)
+	l.Succeeded(http.MethodGet)
+	if got := l.Rate(http.MethodGet); got != 3 {
+		t.Errorf("rate after recovering once %v, want 3", got)
+	}
+	for i := 0; i < 20; i++ {
+		age(recoverInterval)
+		l.Succeeded(http.MethodGet)
+	}
+	if got := l.Rate(http.MethodGet); got != 20 {
+		t.Errorf("rate after recovering %v, want the configured 20", got)
+	}
+
+	l.Throttled(http.MethodDelete)
+	if got := l.Rate(http.MethodDelete); got != 0 {
+		t.Errorf("unlimited method got a rate of %v after throttling", got)
+	}
+
+	fixed := NewRateLimiter(RateLimits{Default: RateLimit{Rate: 20, Burst: 20}})
+	fixed.Throttled(http.MethodGet)
+	if got := fixed.Rate(http.MethodGet); got != 20 {
+		t.Errorf("rate of a fixed limiter after throttling %v, want 20", got)
+	}
+}
+
+// Responses of mk.io drive the adaptive rate
+func TestRateLimiterObservesResponses(t *testing.T) {
+	limiter := NewRateLimiter(RateLimits{Default: RateLimit{Rate: 1000, Burst: 10}, Adaptive: true})
+	throttled := true
+	client := newTestClient(t, func(w http.ResponseWriter, req *http.Request) {
+		if throttled {
+			throttled = false
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}, &ClientOptions{RateLimiter: limiter})
+
+	if _, err := NewResourceClient[testResource](client, "assets").Get(context.Background(), "a1"); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got := limiter.Rate(http.MethodGet); got != 500 {
+		t.Errorf("rate after a throttled request %v, want 500", got)
+	}
+}
@@ -0,0 +1,239 @@
+package mkiosdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+)
+
+// ResourceClient makes the requests of one type of mk.io resource, found at
+// /api/ams/{subscriptionName}/{collection}/{name}. T is the resource, e.g. armmediaservices.Asset.
+// Don't use this type directly, use NewResourceClient() instead.
+type ResourceClient[T any] struct {
+	client *MkioClient
+	// collection are the path segments of the resources below the subscription, e.g. assets, or assets, the asset
+	// name and assetFilters
+	collection []string
+}
+
+// ListOptions contains the optional query parameters of ResourceClient.List
+type ListOptions struct {
+	// Filter is an OData filter, e.g. properties/created gt 2023-01-01
+	Filter *string
+	// Orderby is the property to sort by, e.g. properties/created
+	Orderby *string
+	// Top is the maximum number of resources per page
+	Top *int32
+}
+
+// NewResourceClient creates a ResourceClient for the resources at collection, using the host, token, retry policy
+// and rate limiter of client. The segments of collection are escaped.
+func NewResourceClient[T any](client *MkioClient, collection ...string) *ResourceClient[T] {
+	return &ResourceClient[T]{client: client, collection: collection}
+}
+
+// resourcePage is a page of a List response
+type resourcePage[T any] struct {
+	Value    []*T    `json:"value"`
+	NextLink *string `json:"@odata.nextLink"`
+}
+
+// Get returns the resource name
+// If the operation fails it returns an *ResponseError type.
+func (r *ResourceClient[T]) Get(ctx context.Context, name string) (T, error) {
+	var result T
+	err := r.do(ctx, http.MethodGet, name, "", nil, nil, &result)
+	return result, err
+}
+
+// CreateOrUpdate creates the resource name from parameters, or replaces it. Returns the resource as mk.io stored it.
+// parameters is usually a *T, but may be any type that marshals to the JSON of one.
+// If the operation fails it returns an *ResponseError type.
+func (r *ResourceClient[T]) CreateOrUpdate(ctx context.Context, name string, parameters interface{}) (T, error) {
+	var result T
+	body, err := json.Marshal(parameters)
+	if err != nil {
+		return result, err
+	}
+	err = r.do(ctx, http.MethodPut, name, "", nil, body, &result)
+	return result, err
+}
+
+// Delete deletes the resource name
+// If the operation fails it returns an *ResponseError type.
+func (r *ResourceClient[T]) Delete(ctx context.Context, name string) error {
+	return r.do(ctx, http.MethodDelete, name, "", nil, nil, nil)
+}
+
+// Action posts to an action of the resource name, e.g. listPaths, and decodes the response into result
+// If the operation fails it returns an *ResponseError type.
+func (r *ResourceClient[T]) Action(ctx context.Context, name string, action string, result interface{}) error {
+	return r.do(ctx, http.MethodPost, name, action, nil, nil, result)
+}
+
+// ListPages lists the resources of the collection, calling page with each page as it is read. Stops at the first
+// error returned by page. options may be nil.
+// If the operation fails it returns an *ResponseError type.
+func (r *ResourceClient[T]) ListPages(ctx context.Context, options *ListOptions, page func([]*T) error) error {
+	pager := newListPager(r, options, func(value []*T, nextLink *string) []*T {
+		return value
+	})
+	for pager.More() {
+		value, err := pager.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if err := page(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newListPager returns a pager over the pages of the collection, each converted to a response R by response. A page
+// is only requested when NextPage is called, and the filter, order and page size of options are sent with the skip
+// token of every page. options may be nil.
+func newListPager[T any, R any](r *ResourceClient[T], options *ListOptions, response func(value []*T, nextLink *string) R) *runtime.Pager[R] {
+	skipToken := ""
+	more := true
+	return runtime.NewPager(runtime.PagingHandler[R]{
+		More: func(R) bool {
+			return more
+		},
+		Fetcher: func(ctx context.Context, _ *R) (R, error) {
+			result := resourcePage[T]{}
+			err := r.do(ctx, http.MethodGet, "", "", listQuery(options, skipToken), nil, &result)
+			if err != nil {
+				var empty R
+				return empty, err
+			}
+
+			more = result.NextLink != nil && *result.NextLink != ""
+			if more {
+				skipToken, err = nextSkipToken(*result.NextLink)
+				if err != nil {
+					var empty R
+					return empty, err
+				}
+			}
+			return response(result.Value, result.NextLink), nil
+		},
+	})
+}
+
+// List returns all the resources of the collection. options may be nil.
+// If the operation fails it returns an *ResponseError type.
+func (r *ResourceClient[T]) List(ctx context.Context, options *ListOptions) ([]*T, error) {
+	results := []*T{}
+	err := r.ListPages(ctx, options, func(page []*T) error {
+		results = append(results, page...)
+		return nil
+	})
+	return results, err
+}
+
+// listQuery returns the query parameters of a List request. The skip token of the next page is sent with the same
+// filter, order and page size as the first
+func listQuery(options *ListOptions, skipToken string) url.Values {
+	query := url.Values{}
+	if options != nil {
+		if options.Filter != nil {
+			query.Set("$filter", *options.Filter)
+		}
+		if options.Orderby != nil {
+			query.Set("$orderby", *options.Orderby)
+		}
+		if options.Top != nil {
+			query.Set("$top", strconv.Itoa(int(*options.Top)))
+		}
+	}
+	if skipToken != "" {
+		query.Set("$skiptoken", skipToken)
+	}
+	return query
+}
+
+// nextSkipToken returns the skip token of the @odata.nextLink of a List response
+func nextSkipToken(nextLink string) (string, error) {
+	u, err := url.Parse(nextLink)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse next page link %q: %v", nextLink, err)
+	}
+	query := u.Query()
+	for _, key := range []string{"$skiptoken", "skiptoken"} {
+		if token := query.Get(key); token != "" {
+			return token, nil
+		}
+	}
+	return "", fmt.Errorf("next page link %q has no skip token", nextLink)
+}
+
+// path returns the URL of the resource name, or of the collection if name is empty, followed by action if set
+func (r *ResourceClient[T]) path(name string, action string) (string, error) {
+	if r.client.subscriptionName == "" {
+		return "", errors.New("parameter client.subscriptionName cannot be empty")
+	}
+	segments := []string{"api", "ams", url.PathEscape(r.client.subscriptionName)}
+	for _, v := range r.collection {
+		segments = append(segments, url.PathEscape(v))
+	}
+	if name != "" {
+		segments = append(segments, url.PathEscape(name))
+	}
+	if action != "" {
+		segments = append(segments, action)
+	}
+	return url.JoinPath(r.client.host, segments...)
+}
+
+// do sends a request with the retry policy of the client and decodes the response into result, if not nil
+func (r *ResourceClient[T]) do(ctx context.Context, method string, name string, action string, query url.Values, body []byte, result interface{}) error {
+	path, err := r.path(name, action)
+	if err != nil {
+		return err
+	}
+	if len(query) > 0 {
+		path = path + "?" + query.Encode()
+	}
+
+	var b *bytes.Reader
+	var rcBody io.ReadCloser
+	if body != nil {
+		b = bytes.NewReader(body)
+		rcBody = io.NopCloser(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, path, rcBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	request := &Request{Request: req}
+	if b != nil {
+		request.body = b
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	// Try to do request, handle retries if tooManyRequests
+	resp, err := r.client.DoRequestWithBackoff(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if result == nil || len(respBody) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, result)
+}
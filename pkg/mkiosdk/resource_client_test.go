@@ -0,0 +1,226 @@
+package mkiosdk
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
+)
+
+// testResource is the resource of the ResourceClient tests
+type testResource struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// newTestClient returns a client of subscription "sub" sending its requests to handler. Retries don't wait
+func newTestClient(t *testing.T, handler http.HandlerFunc, options *ClientOptions) *MkioClient {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	if options == nil {
+		options = &ClientOptions{}
+	}
+	if options.Retry == nil {
+		options.Retry = &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, RetryStatusCodes: []int{http.StatusTooManyRequests}}
+	}
+	client := newMkioClient("sub", "token", srv.URL, options)
+	return &client
+}
+
+func TestResourceClientRequests(t *testing.T) {
+	tests := []struct {
+		name       string
+		call       func(r *ResourceClient[testResource]) (testResource, error)
+		collection []string
+		method     string
+		path       string
+		body       string
+		status     int
+		response   string
+		want       testResource
+	}{
+		{
+			name:     "get",
+			call:     func(r *ResourceClient[testResource]) (testResource, error) { return r.Get(context.Background(), "a1") },
+			method:   http.MethodGet,
+			path:     "/api/ams/sub/assets/a1",
+			status:   http.StatusOK,
+			response: `{"name":"a1","value":"v"}`,
+			want:     testResource{Name: "a1", Value: "v"},
+		},
+		{
+			name: "names are escaped",
+			call: func(r *ResourceClient[testResource]) (testResource, error) {
+				return r.Get(context.Background(), "a b/c")
+			},
+			method:   http.MethodGet,
+			path:     "/api/ams/sub/assets/a%20b%2Fc",
+			status:   http.StatusOK,
+			response: `{"name":"a b/c"}`,
+			want:     testResource{Name: "a b/c"},
+		},
+		{
+			name:       "nested collection",
+			call:       func(r *ResourceClient[testResource]) (testResource, error) { return r.Get(context.Background(), "f1") },
+			collection: []string{"assets", "a1", "assetFilters"},
+			method:     http.MethodGet,
+			path:       "/api/ams/sub/assets/a1/assetFilters/f1",
+			status:     http.StatusOK,
+			response:   `{"name":"f1"}`,
+			want:       testResource{Name: "f1"},
+		},
+		{
+			name: "create",
+			call: func(r *ResourceClient[testResource]) (testResource, error) {
+				return r.CreateOrUpdate(context.Background(), "a1", &testResource{Name: "a1", Value: "new"})
+			},
+			method:   http.MethodPut,
+			path:     "/api/ams/sub/assets/a1",
+			body:     `{"name":"a1","value":"new"}`,
+			status:   http.StatusCreated,
+			response: `{"name":"a1","value":"stored"}`,
+			want:     testResource{Name: "a1", Value: "stored"},
+		},
+		{
+			name: "delete",
+			call: func(r *ResourceClient[testResource]) (testResource, error) {
+				return testResource{}, r.Delete(context.Background(), "a1")
+			},
+			method: http.MethodDelete,
+			path:   "/api/ams/sub/assets/a1",
+			status: http.StatusNoContent,
+		},
+		{
+			name: "action",
+			call: func(r *ResourceClient[testResource]) (testResource, error) {
+				result := testResource{}
+				err := r.Action(context.Background(), "l1", "listPaths", &result)
+				return result, err
+			},
+			method:   http.MethodPost,
+			path:     "/api/ams/sub/assets/l1/listPaths",
+			status:   http.StatusOK,
+			response: `{"name":"paths"}`,
+			want:     testResource{Name: "paths"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, req *http.Request) {
+				if req.Method != tt.method || req.URL.EscapedPath() != tt.path {
+					t.Errorf("request %v %v, want %v %v", req.Method, req.URL.EscapedPath(), tt.method, tt.path)
+				}
+				if token := req.Header.Get("x-mkio-token"); token != "token" {
+					t.Errorf("request token %q, want %q", token, "token")
+				}
+				body, _ := io.ReadAll(req.Body)
+				if string(body) != tt.body {
+					t.Errorf("request body %s, want %s", body, tt.body)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.response))
+			}, nil)
+			collection := tt.collection
+			if collection == nil {
+				collection = []string{"assets"}
+			}
+
+			got, err := tt.call(NewResourceClient[testResource](client, collection...))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceClientErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		responses []int
+		// requests is the number of requests sent, including retries
+		requests int
+		status   int
+	}{
+		{name: "not found", method: http.MethodGet, responses: []int{http.StatusNotFound}, requests: 1, status: http.StatusNotFound},
+		{name: "throttled then found", method: http.MethodGet, responses: []int{http.StatusTooManyRequests, http.StatusOK}, requests: 2},
+		{name: "throttled until the attempts run out", method: http.MethodGet, responses: []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests}, requests: 3, status: http.StatusTooManyRequests},
+		{name: "put needs 200 or 201", method: http.MethodPut, responses: []int{http.StatusAccepted}, requests: 1, status: http.StatusAccepted},
+		{name: "delete of a deleted resource", method: http.MethodDelete, responses: []int{http.StatusNoContent}, requests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			client := newTestClient(t, func(w http.ResponseWriter, req *http.Request) {
+				status := tt.responses[requests]
+				requests++
+				w.WriteHeader(status)
+				if status >= 400 {
+					_, _ = w.Write([]byte(`{"error":{"code":"Failed"}}`))
+				}
+			}, nil)
+			r := NewResourceClient[testResource](client, "assets")
+
+			var err error
+			switch tt.method {
+			case http.MethodGet:
+				_, err = r.Get(context.Background(), "a1")
+			case http.MethodPut:
+				_, err = r.CreateOrUpdate(context.Background(), "a1", &testResource{Name: "a1"})
+			case http.MethodDelete:
+				err = r.Delete(context.Background(), "a1")
+			}
+			if requests != tt.requests {
+				t.Errorf("sent %d requests, want %d", requests, tt.requests)
+			}
+			if got := StatusCode(err); got != tt.status {
+				t.Errorf("error %v has status %d, want %d", err, got, tt.status)
+			}
+			if tt.status >= 400 && ErrorCode(err) != "Failed" {
+				t.Errorf("error code %q, want %q", ErrorCode(err), "Failed")
+			}
+			stats := client.RetryStats()
+			if stats.Requests != 1 || stats.Retries != int64(tt.requests-1) {
+				t.Errorf("retry stats %+v, want 1 request and %d retries", stats, tt.requests-1)
+			}
+		})
+	}
+}
+
+// The clients of the resource types are built on ResourceClient
+func TestAssetsClient(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/api/profile":
+			_, _ = w.Write([]byte(`{}`))
+		case "/api/ams/sub/assets/a1":
+			_ = json.NewEncoder(w).Encode(armmediaservices.Asset{Name: to.Ptr("a1")})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}, nil)
+
+	assets, err := NewAssetsClient(context.Background(), "sub", "token", client.host, &ClientOptions{Retry: client.retry})
+	if err != nil {
+		t.Fatalf("NewAssetsClient: %v", err)
+	}
+	resp, err := assets.Get(context.Background(), "a1", nil)
+	if err != nil || resp.Name == nil || *resp.Name != "a1" {
+		t.Errorf("Get = %+v, %v, want asset a1", resp, err)
+	}
+	if _, err := assets.Get(context.Background(), "a2", nil); !IsNotFound(err) {
+		t.Errorf("Get of a missing asset = %v, want not found", err)
+	}
+}
@@ -0,0 +1,151 @@
+package mkiosdk
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy decides which failed requests are retried, how often and how long to wait in between
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a request is sent, including the first one
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry. It doubles on every retry, up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter takes up to this fraction off each wait at random, so workers that were throttled together don't all
+	// come back at once. 0 to wait exactly
+	Jitter float64
+	// RetryStatusCodes are the response status codes that are retried
+	RetryStatusCodes []int
+	// RetryNetworkErrors retries requests that got no response at all, e.g. a connection reset
+	RetryNetworkErrors bool
+}
+
+// DefaultRetryPolicy retries throttled requests and the gateway errors of a busy or restarting API for about two
+// minutes, which is longer than mk.io rate limits last
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:        8,
+		BaseDelay:          1 * time.Second,
+		MaxDelay:           45 * time.Second,
+		Jitter:             0.2,
+		RetryStatusCodes:   []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		RetryNetworkErrors: true,
+	}
+}
+
+// Validate checks that the policy can be used
+func (p *RetryPolicy) Validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("retry policy needs at least 1 attempt, got %d", p.MaxAttempts)
+	}
+	if p.BaseDelay <= 0 {
+		return fmt.Errorf("retry base delay must be positive, got %v", p.BaseDelay)
+	}
+	if p.MaxDelay < p.BaseDelay {
+		return fmt.Errorf("retry max delay %v is shorter than the base delay %v", p.MaxDelay, p.BaseDelay)
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("retry jitter must be between 0 and 1, got %v", p.Jitter)
+	}
+	return nil
+}
+
+// retryStatus returns true if responses with this status code are retried
+func (p *RetryPolicy) retryStatus(statusCode int) bool {
+	for _, v := range p.RetryStatusCodes {
+		if v == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the wait before retry number retry, counting from 1. A Retry-After header on resp takes precedence,
+// up to MaxDelay
+func (p *RetryPolicy) delay(retry int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			if after > p.MaxDelay {
+				return p.MaxDelay
+			}
+			return after
+		}
+	}
+
+	d := p.BaseDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// retryAfter parses a Retry-After header, which is either a number of seconds or an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// RetryStats counts the requests made by a client and how many of them had to be retried
+type RetryStats struct {
+	// Requests is the number of requests made, not counting retries
+	Requests int64
+	// Retried is the number of requests that were retried at least once, Retries the total number of retries
+	Retried int64
+	Retries int64
+	// Exhausted is the number of requests that still failed after the last attempt the policy allows
+	Exhausted int64
+}
+
+// retryCounters are the counters behind RetryStats. They are shared by the workers using a client
+type retryCounters struct {
+	requests  atomic.Int64
+	retried   atomic.Int64
+	retries   atomic.Int64
+	exhausted atomic.Int64
+}
+
+// record counts a request that was retried retries times
+func (c *retryCounters) record(retries int, exhausted bool) {
+	c.requests.Add(1)
+	if retries > 0 {
+		c.retried.Add(1)
+		c.retries.Add(int64(retries))
+	}
+	if exhausted {
+		c.exhausted.Add(1)
+	}
+}
+
+// drain reads and closes the body of a response that is about to be retried, so the connection can be reused
+func drain(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
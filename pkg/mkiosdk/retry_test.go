@@ -0,0 +1,80 @@
+package mkiosdk
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 8, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	withRetryAfter := func(value string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{value}}}
+	}
+
+	tests := []struct {
+		name  string
+		retry int
+		resp  *http.Response
+		want  time.Duration
+	}{
+		{name: "first retry", retry: 1, want: time.Second},
+		{name: "doubles", retry: 2, want: 2 * time.Second},
+		{name: "doubles again", retry: 4, want: 8 * time.Second},
+		{name: "capped at max delay", retry: 5, want: 10 * time.Second},
+		{name: "many retries", retry: 100, want: 10 * time.Second},
+		{name: "response without Retry-After", retry: 2, resp: &http.Response{Header: http.Header{}}, want: 2 * time.Second},
+		{name: "Retry-After seconds", retry: 1, resp: withRetryAfter("3"), want: 3 * time.Second},
+		{name: "Retry-After zero", retry: 3, resp: withRetryAfter("0"), want: 0},
+		{name: "Retry-After capped at max delay", retry: 1, resp: withRetryAfter("3600"), want: 10 * time.Second},
+		{name: "Retry-After in the past", retry: 1, resp: withRetryAfter("Mon, 02 Jan 2006 15:04:05 GMT"), want: 0},
+		{name: "invalid Retry-After", retry: 3, resp: withRetryAfter("soon"), want: 4 * time.Second},
+		{name: "negative Retry-After", retry: 3, resp: withRetryAfter("-1"), want: 4 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.delay(tt.retry, tt.resp); got != tt.want {
+				t.Errorf("delay(%d) = %v, want %v", tt.retry, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelayJitter(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 8, BaseDelay: time.Second, MaxDelay: 10 * time.Second, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		// Jitter only takes time off
+		if got := policy.delay(3, nil); got > 4*time.Second || got < 2*time.Second {
+			t.Fatalf("delay(3) = %v, want between 2s and 4s", got)
+		}
+	}
+
+	// The wait the server asked for is kept
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"3"}}}
+	if got := policy.delay(1, resp); got != 3*time.Second {
+		t.Errorf("delay with Retry-After = %v, want 3s", got)
+	}
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		wantErr bool
+	}{
+		{name: "default", policy: *DefaultRetryPolicy()},
+		{name: "no attempts", policy: RetryPolicy{BaseDelay: time.Second, MaxDelay: time.Second}, wantErr: true},
+		{name: "no base delay", policy: RetryPolicy{MaxAttempts: 1, MaxDelay: time.Second}, wantErr: true},
+		{name: "max delay under base delay", policy: RetryPolicy{MaxAttempts: 1, BaseDelay: 2 * time.Second, MaxDelay: time.Second}, wantErr: true},
+		{name: "jitter over 1", policy: RetryPolicy{MaxAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Second, Jitter: 1.5}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -18,7 +18,7 @@ type StorageAccountsClient struct {
 	host             string
 	customerId       string
 	subscriptionName string
-	token            string
+	auth             TokenProvider
 	hc               *http.Client
 }
 
@@ -30,16 +30,18 @@ type StorageAccountsClient struct {
 // options - pass nil to accept the default values.
 func NewStorageAccountsClient(customerId string, subscriptionName string, token string, apiEndpoint string, options *ClientOptions) (*StorageAccountsClient, error) {
 	if options == nil {
-		options = &ClientOptions{
-			host: apiEndpoint,
-		}
+		options = &ClientOptions{}
 	}
 	hc := &http.Client{}
+	var auth TokenProvider = StaticToken(token)
+	if options.TokenProvider != nil {
+		auth = options.TokenProvider
+	}
 	client := &StorageAccountsClient{
 		customerId:       customerId,
 		subscriptionName: subscriptionName,
-		host:             options.host,
-		token:            token,
+		host:             options.hostOr(apiEndpoint),
+		auth:             auth,
 		hc:               hc,
 	}
 	return client, nil
@@ -80,12 +82,16 @@ func (client *StorageAccountsClient) getCreateRequest(ctx context.Context, optio
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest(http.MethodGet, path, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := client.auth.Token(ctx)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
+	req.Header.Set("x-mkio-token", token)
 	return req, nil
 }
 
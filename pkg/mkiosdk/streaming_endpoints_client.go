@@ -1,16 +1,9 @@
 package mkiosdk
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
-	"net/http"
-	"net/url"
-	"strings"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
 )
 
@@ -18,6 +11,7 @@ import (
 // Don't use this type directly, use NewStreamingEndpointsClient() instead.
 type StreamingEndpointsClient struct {
 	MkioClient
+	resources *ResourceClient[armmediaservices.StreamingEndpoint]
 }
 
 // NewStreamingEndpointsClient creates a new instance of StreamingEndpointsClient with the specified values.
@@ -27,19 +21,10 @@ type StreamingEndpointsClient struct {
 // options - pass nil to accept the default values.
 func NewStreamingEndpointsClient(ctx context.Context, subscriptionName string, token string, apiEndpoint string, options *ClientOptions) (*StreamingEndpointsClient, error) {
 	if options == nil {
-		options = &ClientOptions{
-			host: apiEndpoint,
-		}
-	}
-	hc := &http.Client{}
-	client := &StreamingEndpointsClient{
-		MkioClient{
-			subscriptionName: subscriptionName,
-			host:             options.host,
-			token:            token,
-			hc:               hc,
-		},
+		options = &ClientOptions{}
 	}
+	client := &StreamingEndpointsClient{MkioClient: newMkioClient(subscriptionName, token, apiEndpoint, options)}
+	client.resources = NewResourceClient[armmediaservices.StreamingEndpoint](&client.MkioClient, "streamingEndpoints")
 	// Test that our token is valid
 	err := client.GetProfile(ctx)
 	if err != nil {
@@ -56,63 +41,11 @@ func NewStreamingEndpointsClient(ctx context.Context, subscriptionName string, t
 // parameters - The request parameters
 // options - StreamingEndpointClientCreateOrUpdateOptions contains the optional parameters for the StreamingEndpointsClient.CreateOrUpdate method.
 func (client *StreamingEndpointsClient) CreateOrUpdate(ctx context.Context, streamingEndpointName string, parameters armmediaservices.StreamingEndpoint, options *armmediaservices.StreamingEndpointsClientBeginCreateOptions) (armmediaservices.StreamingEndpointsClientCreateResponse, error) {
-	req, err := client.createOrUpdateCreateRequest(ctx, streamingEndpointName, parameters, options)
-	if err != nil {
-		return armmediaservices.StreamingEndpointsClientCreateResponse{}, fmt.Errorf("unable to generate Create/Update request: %v", err)
-	}
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
-	if err != nil {
-		// We hit some error we and failed retry loop. Return error
-		return armmediaservices.StreamingEndpointsClientCreateResponse{}, err
-
-	}
-	return client.createOrUpdateHandleResponse(resp)
-}
-
-// createOrUpdateCreateRequest creates the CreateOrUpdate request.
-func (client *StreamingEndpointsClient) createOrUpdateCreateRequest(ctx context.Context, streamingEndpointName string, parameters armmediaservices.StreamingEndpoint, options *armmediaservices.StreamingEndpointsClientBeginCreateOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/streamingEndpoints/{streamingEndpointName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{streamingEndpointName}", url.PathEscape(streamingEndpointName))
-	body, err := json.Marshal(parameters)
-	if err != nil {
-		return nil, err
-	}
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-
-	b := bytes.NewReader(body)
-	var rcBody io.ReadCloser
-	if body != nil {
-		rcBody = io.NopCloser(io.ReadSeeker(b))
-	}
-	req, err := http.NewRequest(http.MethodPut, path, rcBody)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{b, req}, nil
-}
-
-// createOrUpdateHandleResponse handles the CreateOrUpdate response.
-func (client *StreamingEndpointsClient) createOrUpdateHandleResponse(resp *http.Response) (armmediaservices.StreamingEndpointsClientCreateResponse, error) {
-	result := armmediaservices.StreamingEndpointsClientCreateResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	se, err := client.resources.CreateOrUpdate(ctx, streamingEndpointName, &parameters)
 	if err != nil {
 		return armmediaservices.StreamingEndpointsClientCreateResponse{}, err
 	}
-	if err := json.Unmarshal(body, &result.StreamingEndpoint); err != nil {
-		return armmediaservices.StreamingEndpointsClientCreateResponse{}, err
-	}
-	return result, nil
+	return armmediaservices.StreamingEndpointsClientCreateResponse{StreamingEndpoint: se}, nil
 }
 
 // Get - Get the details of a Streaming Endpoint in the Media Services account
@@ -120,146 +53,45 @@ func (client *StreamingEndpointsClient) createOrUpdateHandleResponse(resp *http.
 // streamingEndpointName - The StreamingEndpoint name.
 // options - StreamingEndpointsClientGetOptions contains the optional parameters for the StreamingEndpointsClient.Get method.
 func (client *StreamingEndpointsClient) Get(ctx context.Context, streamingEndpointName string, options *armmediaservices.StreamingEndpointsClientGetOptions) (armmediaservices.StreamingEndpointsClientGetResponse, error) {
-	req, err := client.getCreateRequest(ctx, streamingEndpointName, options)
-	if err != nil {
-		return armmediaservices.StreamingEndpointsClientGetResponse{}, err
-	}
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
-	if err != nil {
-		// We hit some error we and failed retry loop. Return error
-		return armmediaservices.StreamingEndpointsClientGetResponse{}, err
-	}
-	return client.getHandleResponse(resp)
-}
-
-// getCreateRequest creates the Get request.
-func (client *StreamingEndpointsClient) getCreateRequest(ctx context.Context, streamingEndpointName string, options *armmediaservices.StreamingEndpointsClientGetOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/streamingEndpoints/{streamingEndpointName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{streamingEndpointName}", url.PathEscape(streamingEndpointName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodGet, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{nil, req}, nil
-}
-
-// getHandleResponse handles the Get response.
-func (client *StreamingEndpointsClient) getHandleResponse(resp *http.Response) (armmediaservices.StreamingEndpointsClientGetResponse, error) {
-	result := armmediaservices.StreamingEndpointsClientGetResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	se, err := client.resources.Get(ctx, streamingEndpointName)
 	if err != nil {
 		return armmediaservices.StreamingEndpointsClientGetResponse{}, err
 	}
-	if err := json.Unmarshal(body, &result.StreamingEndpoint); err != nil {
-		return armmediaservices.StreamingEndpointsClientGetResponse{}, err
-	}
-	return result, nil
+	return armmediaservices.StreamingEndpointsClientGetResponse{StreamingEndpoint: se}, nil
 }
 
 // List - List the Streaming Endpoints in the Media Services account
 // If the operation fails it returns an *ResponseError type.
-// options - StreamingEndpointsClientGetOptions contains the optional parameters for the StreamingEndpointsClient.Get method.
+// options - StreamingEndpointsClientListOptions contains the optional parameters for the StreamingEndpointsClient.List method.
 func (client *StreamingEndpointsClient) List(ctx context.Context, options *armmediaservices.StreamingEndpointsClientListOptions) (armmediaservices.StreamingEndpointsClientListResponse, error) {
-	req, err := client.listCreateRequest(ctx, options)
-	if err != nil {
-		return armmediaservices.StreamingEndpointsClientListResponse{}, err
-	}
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
-	if err != nil {
-		// We hit some error we and failed retry loop. Return error
-		return armmediaservices.StreamingEndpointsClientListResponse{}, err
-	}
-	return client.listHandleResponse(resp)
-}
-
-// listCreateRequest creates the List request.
-func (client *StreamingEndpointsClient) listCreateRequest(ctx context.Context, options *armmediaservices.StreamingEndpointsClientListOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/streamingEndpoints"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionID cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodGet, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{nil, req}, nil
-}
-
-// listHandleResponse handles the List response.
-func (client *StreamingEndpointsClient) listHandleResponse(resp *http.Response) (armmediaservices.StreamingEndpointsClientListResponse, error) {
 	result := armmediaservices.StreamingEndpointsClientListResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return armmediaservices.StreamingEndpointsClientListResponse{}, err
-	}
-	if err := json.Unmarshal(body, &result.StreamingEndpointListResult); err != nil {
-		return armmediaservices.StreamingEndpointsClientListResponse{}, err
+	pager := client.NewListPager(options)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return result, err
+		}
+		result.StreamingEndpointListResult.Value = append(result.StreamingEndpointListResult.Value, page.Value...)
 	}
 	return result, nil
 }
 
+// NewListPager - Creates a pager to list the Streaming Endpoints in the Media Services account a page at a time
+// options - StreamingEndpointsClientListOptions contains the optional parameters for the StreamingEndpointsClient.NewListPager method.
+func (client *StreamingEndpointsClient) NewListPager(options *armmediaservices.StreamingEndpointsClientListOptions) *runtime.Pager[armmediaservices.StreamingEndpointsClientListResponse] {
+	return newListPager(client.resources, nil, func(value []*armmediaservices.StreamingEndpoint, nextLink *string) armmediaservices.StreamingEndpointsClientListResponse {
+		return armmediaservices.StreamingEndpointsClientListResponse{StreamingEndpointListResult: armmediaservices.StreamingEndpointListResult{Value: value, ODataNextLink: nextLink}}
+	})
+}
+
 // Delete - Deletes a Streaming Endpoint in the Media Services account
 // If the operation fails it returns an *ResponseError type.
 // streamingEndpointName - The StreamingEndpoint name.
 // options - StreamingEndpointsClientDeleteOptions contains the optional parameters for the StreamingEndpointsClient.Delete
 // method.
 func (client *StreamingEndpointsClient) Delete(ctx context.Context, streamingEndpointName string, options *armmediaservices.StreamingEndpointsClientBeginDeleteOptions) (armmediaservices.AssetsClientDeleteResponse, error) {
-	req, err := client.deleteCreateRequest(ctx, streamingEndpointName, options)
-	if err != nil {
-		return armmediaservices.AssetsClientDeleteResponse{}, err
-	}
-
-	// Try to do request, handle retries if tooManyRequests
-	_, err = client.DoRequestWithBackoff(req)
-	if err != nil {
-		// We hit some error we and failed retry loop. Return error
-		return armmediaservices.AssetsClientDeleteResponse{}, err
-	}
-
-	return armmediaservices.AssetsClientDeleteResponse{}, nil
-}
-
-// deleteCreateRequest creates the Delete request.
-func (client *StreamingEndpointsClient) deleteCreateRequest(ctx context.Context, streamingEndpointName string, options *armmediaservices.StreamingEndpointsClientBeginDeleteOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/streamingEndpoints/{streamingEndpointName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{streamingEndpointName}", url.PathEscape(streamingEndpointName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodDelete, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-
-	return &Request{nil, req}, nil
+	err := client.resources.Delete(ctx, streamingEndpointName)
+	return armmediaservices.AssetsClientDeleteResponse{}, err
 }
 
 // lookupStreamingEndpoints Get streaming endpoints from mk.io. Remove pagination
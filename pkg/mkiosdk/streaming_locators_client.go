@@ -1,16 +1,9 @@
 package mkiosdk
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"errors"
-	"fmt"
-	"io"
-	"net/http"
-	"net/url"
-	"strings"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/mediaservices/armmediaservices"
 )
@@ -19,6 +12,7 @@ import (
 // Don't use this type directly, use NewStreamingLocatorsClient() instead.
 type StreamingLocatorsClient struct {
 	MkioClient
+	resources *ResourceClient[armmediaservices.StreamingLocator]
 }
 
 // NewStreamingLocatorsClient creates a new instance of StreamingLocatorsClient with the specified values.
@@ -28,19 +22,10 @@ type StreamingLocatorsClient struct {
 // options - pass nil to accept the default values.
 func NewStreamingLocatorsClient(ctx context.Context, subscriptionName string, token string, apiEndpoint string, options *ClientOptions) (*StreamingLocatorsClient, error) {
 	if options == nil {
-		options = &ClientOptions{
-			host: apiEndpoint,
-		}
-	}
-	hc := &http.Client{}
-	client := &StreamingLocatorsClient{
-		MkioClient{
-			subscriptionName: subscriptionName,
-			host:             options.host,
-			token:            token,
-			hc:               hc,
-		},
+		options = &ClientOptions{}
 	}
+	client := &StreamingLocatorsClient{MkioClient: newMkioClient(subscriptionName, token, apiEndpoint, options)}
+	client.resources = NewResourceClient[armmediaservices.StreamingLocator](&client.MkioClient, "streamingLocators")
 	// Test that our token is valid
 	err := client.GetProfile(ctx)
 	if err != nil {
@@ -56,63 +41,11 @@ func NewStreamingLocatorsClient(ctx context.Context, subscriptionName string, to
 // parameters - The request parameters
 // options - StreamingLocatorsClientCreateOrUpdateOptions contains the optional parameters for the StreamingLocatorsClient.CreateOrUpdate method.
 func (client *StreamingLocatorsClient) CreateOrUpdate(ctx context.Context, streamingLocatorName string, parameters armmediaservices.StreamingLocator, options *armmediaservices.StreamingLocatorsClientCreateOptions) (armmediaservices.StreamingLocatorsClientCreateResponse, error) {
-	req, err := client.createOrUpdateCreateRequest(ctx, streamingLocatorName, parameters, options)
+	sl, err := client.resources.CreateOrUpdate(ctx, streamingLocatorName, &parameters)
 	if err != nil {
-		return armmediaservices.StreamingLocatorsClientCreateResponse{}, fmt.Errorf("unable to generate Create/Update request: %v", err)
-	}
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
-	if err != nil {
-		// We hit some error we and failed retry loop. Return error
 		return armmediaservices.StreamingLocatorsClientCreateResponse{}, err
 	}
-	return client.createOrUpdateHandleResponse(resp)
-}
-
-// createOrUpdateCreateRequest creates the CreateOrUpdate request.
-func (client *StreamingLocatorsClient) createOrUpdateCreateRequest(ctx context.Context, streamingLocatorName string, parameters armmediaservices.StreamingLocator, options *armmediaservices.StreamingLocatorsClientCreateOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/streamingLocators/{streamingLocatorName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{streamingLocatorName}", url.PathEscape(streamingLocatorName))
-	body, err := json.Marshal(parameters)
-	if err != nil {
-		return nil, err
-	}
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-
-	b := bytes.NewReader(body)
-	var rcBody io.ReadCloser
-	if body != nil {
-		rcBody = io.NopCloser(io.ReadSeeker(b))
-	}
-
-	req, err := http.NewRequest(http.MethodPut, path, rcBody)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{b, req}, nil
-}
-
-// createOrUpdateHandleResponse handles the CreateOrUpdate response.
-func (client *StreamingLocatorsClient) createOrUpdateHandleResponse(resp *http.Response) (armmediaservices.StreamingLocatorsClientCreateResponse, error) {
-	result := armmediaservices.StreamingLocatorsClientCreateResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return armmediaservices.StreamingLocatorsClientCreateResponse{}, err
-	}
-	if err := json.Unmarshal(body, &result.StreamingLocator); err != nil {
-		return armmediaservices.StreamingLocatorsClientCreateResponse{}, err
-	}
-	return result, nil
+	return armmediaservices.StreamingLocatorsClientCreateResponse{StreamingLocator: sl}, nil
 }
 
 // Delete - Deletes a Streaming Locator in the Media Services account
@@ -121,40 +54,8 @@ func (client *StreamingLocatorsClient) createOrUpdateHandleResponse(resp *http.R
 // options - StreamingLocatorsClientDeleteOptions contains the optional parameters for the StreamingLocatorsClient.Delete
 // method.
 func (client *StreamingLocatorsClient) Delete(ctx context.Context, streamingLocatorName string, options *armmediaservices.StreamingLocatorsClientDeleteOptions) (armmediaservices.AssetsClientDeleteResponse, error) {
-	req, err := client.deleteCreateRequest(ctx, streamingLocatorName, options)
-	if err != nil {
-		return armmediaservices.AssetsClientDeleteResponse{}, err
-	}
-	// Try to do request, handle retries if tooManyRequests
-	_, err = client.DoRequestWithBackoff(req)
-	if err != nil {
-		// We hit some error we and failed retry loop. Return error
-		return armmediaservices.AssetsClientDeleteResponse{}, err
-	}
-	return armmediaservices.AssetsClientDeleteResponse{}, nil
-}
-
-// deleteCreateRequest creates the Delete request.
-func (client *StreamingLocatorsClient) deleteCreateRequest(ctx context.Context, streamingLocatorName string, options *armmediaservices.StreamingLocatorsClientDeleteOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/streamingLocators/{streamingLocatorName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{streamingLocatorName}", url.PathEscape(streamingLocatorName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest(http.MethodDelete, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-
-	return &Request{nil, req}, nil
+	err := client.resources.Delete(ctx, streamingLocatorName)
+	return armmediaservices.AssetsClientDeleteResponse{}, err
 }
 
 // Get - Get the details of a Streaming Locator in the Media Services account
@@ -162,52 +63,11 @@ func (client *StreamingLocatorsClient) deleteCreateRequest(ctx context.Context,
 // streamingLocatorName - The StreamingLocator name.
 // options - StreamingLocatorsClientGetOptions contains the optional parameters for the StreamingLocatorsClient.Get method.
 func (client *StreamingLocatorsClient) Get(ctx context.Context, streamingLocatorName string, options *armmediaservices.StreamingLocatorsClientGetOptions) (armmediaservices.StreamingLocatorsClientGetResponse, error) {
-	req, err := client.getCreateRequest(ctx, streamingLocatorName, options)
-	if err != nil {
-		return armmediaservices.StreamingLocatorsClientGetResponse{}, err
-	}
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
-	if err != nil {
-		// We hit some error we and failed retry loop. Return error
-		return armmediaservices.StreamingLocatorsClientGetResponse{}, err
-	}
-	return client.getHandleResponse(resp)
-}
-
-// getCreateRequest creates the Get request.
-func (client *StreamingLocatorsClient) getCreateRequest(ctx context.Context, streamingLocatorName string, options *armmediaservices.StreamingLocatorsClientGetOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/streamingLocators/{streamingLocatorName}"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{streamingLocatorName}", url.PathEscape(streamingLocatorName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodGet, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-	return &Request{nil, req}, nil
-}
-
-// getHandleResponse handles the Get response.
-func (client *StreamingLocatorsClient) getHandleResponse(resp *http.Response) (armmediaservices.StreamingLocatorsClientGetResponse, error) {
-	result := armmediaservices.StreamingLocatorsClientGetResponse{}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	sl, err := client.resources.Get(ctx, streamingLocatorName)
 	if err != nil {
 		return armmediaservices.StreamingLocatorsClientGetResponse{}, err
 	}
-	if err := json.Unmarshal(body, &result.StreamingLocator); err != nil {
-		return armmediaservices.StreamingLocatorsClientGetResponse{}, err
-	}
-	return result, nil
+	return armmediaservices.StreamingLocatorsClientGetResponse{StreamingLocator: sl}, nil
 }
 
 // ListPaths - List Paths supported by this Streaming Locator
@@ -216,148 +76,57 @@ func (client *StreamingLocatorsClient) getHandleResponse(resp *http.Response) (a
 // options - StreamingLocatorsClientListPathsOptions contains the optional parameters for the StreamingLocatorsClient.ListPaths
 // method.
 func (client *StreamingLocatorsClient) ListPaths(ctx context.Context, streamingLocatorName string, options *armmediaservices.StreamingLocatorsClientListPathsOptions) (armmediaservices.StreamingLocatorsClientListPathsResponse, error) {
-	req, err := client.listPathsCreateRequest(ctx, streamingLocatorName, options)
-	if err != nil {
-		return armmediaservices.StreamingLocatorsClientListPathsResponse{}, err
-	}
-	// Try to do request, handle retries if tooManyRequests
-	resp, err := client.DoRequestWithBackoff(req)
-	if err != nil {
-		// We hit some error we and failed retry loop. Return error
-		return armmediaservices.StreamingLocatorsClientListPathsResponse{}, err
-	}
-
-	return client.listPathsHandleResponse(resp)
-}
-
-// listPathsCreateRequest creates the ListPaths request.
-func (client *StreamingLocatorsClient) listPathsCreateRequest(ctx context.Context, streamingLocatorName string, options *armmediaservices.StreamingLocatorsClientListPathsOptions) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/streamingLocators/{streamingLocatorName}/listPaths"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	urlPath = strings.ReplaceAll(urlPath, "{streamingLocatorName}", url.PathEscape(streamingLocatorName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-	req, err := http.NewRequest(http.MethodPost, path, nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-
-	return &Request{nil, req}, nil
-}
-
-// listPathsHandleResponse handles the ListPaths response.
-func (client *StreamingLocatorsClient) listPathsHandleResponse(resp *http.Response) (armmediaservices.StreamingLocatorsClientListPathsResponse, error) {
 	result := armmediaservices.StreamingLocatorsClientListPathsResponse{}
-
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	err := client.resources.Action(ctx, streamingLocatorName, "listPaths", &result.ListPathsResponse)
 	if err != nil {
 		return armmediaservices.StreamingLocatorsClientListPathsResponse{}, err
 	}
-	if err := json.Unmarshal(body, &result.ListPathsResponse); err != nil {
-		return armmediaservices.StreamingLocatorsClientListPathsResponse{}, err
-	}
 	return result, nil
 }
 
 // List - List Streaming Locators
 // If the operation fails it returns an *ResponseError type.
-// options - StreamingLocatorsClientListOptions contains the optional parameters for the StreamingLocatorsClient.List
-// method.
+// options - StreamingLocatorsClientListOptions contains the optional parameters for the StreamingLocatorsClient.List method.
 func (client *StreamingLocatorsClient) List(ctx context.Context, options *armmediaservices.StreamingLocatorsClientListOptions) (armmediaservices.StreamingLocatorsClientListResponse, error) {
-	skipToken := ""
 	result := armmediaservices.StreamingLocatorsClientListResponse{}
-	for {
-		req, err := client.listCreateRequest(ctx, options, skipToken)
-		if err != nil {
-			return result, err
-		}
-		// Try to do request, handle retries if tooManyRequests
-		resp, err := client.DoRequestWithBackoff(req)
-		if err != nil {
-			// We hit some error we and failed retry loop. Return error
-			return result, err
-		}
-
-		listResp, err := client.listHandleResponse(resp)
+	pager := client.NewListPager(options)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
 		if err != nil {
 			return result, err
 		}
-
-		// Append the current page to the result
-		result.StreamingLocatorCollection.Value = append(result.StreamingLocatorCollection.Value, listResp.StreamingLocatorCollection.Value...)
-
-		if listResp.StreamingLocatorCollection.ODataNextLink == nil {
-			// No more pages. Break the loop
-			break
-		} else {
-			// Mor Pages, Update SkipToken
-			skipToken = strings.Split(*listResp.StreamingLocatorCollection.ODataNextLink, "skiptoken=")[1]
-		}
+		result.StreamingLocatorCollection.Value = append(result.StreamingLocatorCollection.Value, page.Value...)
 	}
 	return result, nil
 }
 
-// listCreateRequest creates the ListPaths request.
-func (client *StreamingLocatorsClient) listCreateRequest(ctx context.Context, options *armmediaservices.StreamingLocatorsClientListOptions, skipToken string) (*Request, error) {
-	urlPath := "/api/ams/{subscriptionName}/streamingLocators"
-	if client.subscriptionName == "" {
-		return nil, errors.New("parameter client.subscriptionName cannot be empty")
-	}
-	urlPath = strings.ReplaceAll(urlPath, "{subscriptionName}", url.PathEscape(client.subscriptionName))
-	path, err := url.JoinPath(client.host, urlPath)
-	if err != nil {
-		return nil, err
-	}
-
-	// Apply filters to query
-	filter := ""
-	if skipToken != "" {
-		filter = `$skiptoken=` + skipToken + "&"
-	}
-	if options.Filter != nil {
-		filter = `$filter=` + *options.Filter
-	}
-	q, err := url.ParseQuery(filter)
-	if err == nil {
-		path = path + "?" + q.Encode()
-	}
-
-	req, err := http.NewRequest(http.MethodGet, path, nil)
-	if err != nil {
-		return nil, err
+// NewListPager - Creates a pager to list Streaming Locators a page at a time
+// options - StreamingLocatorsClientListOptions contains the optional parameters for the StreamingLocatorsClient.NewListPager method.
+func (client *StreamingLocatorsClient) NewListPager(options *armmediaservices.StreamingLocatorsClientListOptions) *runtime.Pager[armmediaservices.StreamingLocatorsClientListResponse] {
+	listOptions := &ListOptions{}
+	if options != nil {
+		listOptions = &ListOptions{Filter: options.Filter, Orderby: options.Orderby, Top: options.Top}
 	}
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("x-mkio-token", client.token)
-
-	return &Request{nil, req}, nil
+	return newListPager(client.resources, listOptions, func(value []*armmediaservices.StreamingLocator, nextLink *string) armmediaservices.StreamingLocatorsClientListResponse {
+		return armmediaservices.StreamingLocatorsClientListResponse{StreamingLocatorCollection: armmediaservices.StreamingLocatorCollection{Value: value, ODataNextLink: nextLink}}
+	})
 }
 
-// listHandleResponse handles the List response.
-func (client *StreamingLocatorsClient) listHandleResponse(resp *http.Response) (armmediaservices.StreamingLocatorsClientListResponse, error) {
-	result := armmediaservices.StreamingLocatorsClientListResponse{}
-
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+// lookupStreamingLocators Get streaming locators from mk.io. Remove pagination
+func (client *StreamingLocatorsClient) LookupStreamingLocators(ctx context.Context, before string, after string) ([]*armmediaservices.StreamingLocator, error) {
+	sl := []*armmediaservices.StreamingLocator{}
+	err := client.ListStreamingLocators(ctx, before, after, func(page []*armmediaservices.StreamingLocator) error {
+		sl = append(sl, page...)
+		return nil
+	})
 	if err != nil {
-		return armmediaservices.StreamingLocatorsClientListResponse{}, err
-	}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return armmediaservices.StreamingLocatorsClientListResponse{}, err
+		return nil, err
 	}
-	return result, nil
+	return sl, nil
 }
 
-// lookupStreamingLocators Get streaming locators from mk.io. Remove pagination
-func (client *StreamingLocatorsClient) LookupStreamingLocators(ctx context.Context, before string, after string) ([]*armmediaservices.StreamingLocator, error) {
-
+// ListStreamingLocators Get streaming locators from mk.io, passing each page to the page func as it is read
+func (client *StreamingLocatorsClient) ListStreamingLocators(ctx context.Context, before string, after string, page func([]*armmediaservices.StreamingLocator) error) error {
 	// Generate the filter
 	filter := generateFilter(before, after)
 
@@ -366,16 +135,17 @@ func (client *StreamingLocatorsClient) LookupStreamingLocators(ctx context.Conte
 	if filter != "" {
 		options.Filter = to.Ptr(filter)
 	}
+	pager := client.NewListPager(options)
 
-	req, err := client.List(ctx, options)
-	if err != nil {
-		return nil, err
+	// Unlike in Azure, we don't need additional calls to get content keys. Hand each page over before reading the next
+	for pager.More() {
+		nextResult, err := pager.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if err := page(nextResult.Value); err != nil {
+			return err
+		}
 	}
-
-	sl := []*armmediaservices.StreamingLocator{}
-
-	// Unlike in Azure, we don't need additional calls to get content keys
-	sl = append(sl, req.StreamingLocatorCollection.Value...)
-
-	return sl, nil
+	return nil
 }